@@ -1,7 +1,10 @@
 package jgoweb
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"github.com/gocraft/web"
 	"github.com/jschneider98/jgoweb/util"
 	"time"
@@ -125,11 +128,7 @@ func (a *Account) Save() error {
 		return err
 	}
 
-	if !a.Id.Valid {
-		return a.Insert()
-	} else {
-		return a.Update()
-	}
+	return SaveInTransaction(a.Ctx, &a.Id.Valid, a.Insert, a.Update)
 }
 
 // Insert a new record
@@ -357,7 +356,7 @@ func GetAllAccounts(ctx ContextInterface) ([]Account, error) {
 		From("public.accounts").
 		OrderBy("domain")
 
-	_, err := stmt.Load(&a)
+	_, err := stmt.LoadContext(ctx.GetGoContext(), &a)
 
 	if err != nil {
 		return nil, err
@@ -372,32 +371,83 @@ func GetAllAccounts(ctx ContextInterface) ([]Account, error) {
 
 // Get account data for all DBs
 func ClusterGetAccounts(ctx ContextInterface) (map[string][]Account, error) {
-	var err error
 	accounts := make(map[string][]Account)
 
-	for dbName, dbConn := range ctx.GetDb().GetConns() {
-		curCtx := NewContext(ctx.GetDb())
-		curCtx.SetDbSession(dbConn.NewSession(nil))
+	ce := NewClusterExecutor(ctx.GetDb())
+	ce.UseReplicas = true
 
-		accounts[dbName], err = GetAllAccounts(curCtx)
+	results := ce.Run(context.Background(), func(goCtx context.Context, shardCtx ContextInterface) (interface{}, error) {
+		return GetAllAccounts(shardCtx)
+	})
 
-		if err != nil {
-			return nil, err
-		}
+	err := results.Err()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, result := range results {
+		accounts[result.ShardName] = result.Value.([]Account)
 	}
 
 	return accounts, nil
 }
 
-//
+// Accounts from every DB in one list, ordered by domain. limit = 0 returns all accounts.
+func ClusterGetAllAccounts(ctx ContextInterface, limit int) ([]Account, error) {
+	accounts := make([]Account, 0)
+
+	ce := NewClusterExecutor(ctx.GetDb())
+	ce.UseReplicas = true
+
+	results := ce.Run(context.Background(), func(goCtx context.Context, shardCtx ContextInterface) (interface{}, error) {
+		return GetAllAccounts(shardCtx)
+	})
+
+	err := results.Err()
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = results.MergeSorted(&accounts, func(a interface{}, b interface{}) bool {
+		accountA := a.(Account)
+		accountB := b.(Account)
+
+		return accountA.GetDomain() < accountB.GetDomain()
+	}, limit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return accounts, nil
+}
+
+// Create an account on shardName. If shardName is blank the shard is picked by the shard placement strategy.
 func CreateAccount(ctx ContextInterface, shardName string, domain string) error {
-	dbConn, err := ctx.GetDb().GetConnByName(shardName)
+	var shard *Shard
+	var err error
+
+	if shardName == "" {
+		shard, err = FetchBestShard(ctx)
+	} else {
+		shard, err = FetchShardByName(ctx, shardName)
+	}
 
 	if err != nil {
 		return err
 	}
 
-	shard, err := FetchShardByName(ctx, shardName)
+	if shard == nil && shardName == "" {
+		return errors.New("No shard is accepting accounts")
+	}
+
+	if shard == nil {
+		return errors.New(fmt.Sprintf("Shard %s does not exist", shardName))
+	}
+
+	dbConn, err := ctx.GetDb().GetConnByName(shard.GetName())
 
 	if err != nil {
 		return err
@@ -414,19 +464,44 @@ func CreateAccount(ctx ContextInterface, shardName string, domain string) error
 
 	account.SetDomain(domain)
 
-	err = account.Save()
+	ct := NewClusterTransaction(ctx.GetDb())
+	shardCtxs := make(map[string]ContextInterface)
 
-	if err != nil {
-		return err
-	}
+	// The account id comes from the insert, so shard maps are saved once every shard's transaction is open
+	return ct.Run(func(dbName string, shardCtx ContextInterface) error {
+		shardCtxs[dbName] = shardCtx
 
-	err = ClusterAddShardMap(ctx, shard.GetId(), domain, account.GetId())
+		if dbName == shard.GetName() {
+			account.Ctx = shardCtx
+			err := account.Save()
 
-	if err != nil {
-		return err
-	}
+			if err != nil {
+				return err
+			}
 
-	return nil
+			ct.NotifyShardRouteChange(account.GetId())
+		}
+
+		if dbName != ct.ShardNames[len(ct.ShardNames)-1] {
+			return nil
+		}
+
+		for _, mapShardName := range ct.ShardNames {
+			shardMap, err := CreateShardMap(shardCtxs[mapShardName], shard.GetId(), domain, account.GetId())
+
+			if err != nil {
+				return errors.New(mapShardName + ": " + err.Error())
+			}
+
+			err = shardMap.Save()
+
+			if err != nil {
+				return errors.New(mapShardName + ": " + err.Error())
+			}
+		}
+
+		return nil
+	})
 }
 
 //
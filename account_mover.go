@@ -0,0 +1,476 @@
+package jgoweb
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jschneider98/jgoweb/util"
+	"strings"
+	"time"
+)
+
+// Table holding account data
+type AccountMoverTable struct {
+	Name          string
+	AccountColumn string
+	KeyColumn     string
+}
+
+// Row count and checksum of an account's rows in a table
+type AccountMoverChecksum struct {
+	Count    int64          `db:"count"`
+	Checksum sql.NullString `db:"checksum"`
+}
+
+// Moves an account's data between shards.
+//
+// 1. Copy rows (in table order, in batches) to the target shard while the account stays online.
+// 2. Read-only window: take the account's write lock on the source shard, re-copy tables that changed during
+// step 1, verify counts/checksums, flip the shard map cluster-wide and purge the source rows. TenantContext
+// transactions for the account wait for the window to close and then fail (the account is gone from the source).
+// Other accounts on the shard aren't affected. Writes that don't go through a TenantContext transaction
+// aren't held off.
+//
+// Tables must include every table that references them by foreign key, otherwise purging the source
+// would drop (or cascade delete) rows that were never copied. Move checks this before copying.
+type AccountMover struct {
+	Ctx       ContextInterface
+	Tables    []AccountMoverTable
+	BatchSize uint64
+}
+
+// Tables are listed in dependency order (parents first)
+func NewAccountMover(ctx ContextInterface) *AccountMover {
+	am := &AccountMover{Ctx: ctx}
+	am.BatchSize = 1000
+
+	am.AddTable("public.accounts", "id", "id")
+	am.AddTable("public.users", "account_id", "id")
+	am.AddTable("queue.jobs", "account_id", "id")
+
+	return am
+}
+
+//
+func (am *AccountMover) AddTable(name string, accountColumn string, keyColumn string) *AccountMover {
+	am.Tables = append(am.Tables, AccountMoverTable{name, accountColumn, keyColumn})
+
+	return am
+}
+
+//
+func (am *AccountMover) SetTables(tables []AccountMoverTable) *AccountMover {
+	am.Tables = tables
+
+	return am
+}
+
+// Move an account to targetShard
+func (am *AccountMover) Move(accountId string, targetShard string) error {
+	defer util.DebugTimeTrack(time.Now(), fmt.Sprintf("Move account %s to %s", accountId, targetShard))
+
+	shard, err := GetShardByAccountId(am.Ctx, accountId)
+
+	if err != nil {
+		return err
+	}
+
+	if shard.GetName() == targetShard {
+		return errors.New(fmt.Sprintf("Account %s is already on shard %s", accountId, targetShard))
+	}
+
+	target, err := FetchShardByName(am.Ctx, targetShard)
+
+	if err != nil {
+		return err
+	}
+
+	if target == nil || target.DeletedAt.Valid {
+		return errors.New(fmt.Sprintf("Invalid target shard: %s", targetShard))
+	}
+
+	srcCtx, err := shard.NewWebContext()
+
+	if err != nil {
+		return err
+	}
+
+	err = am.CheckTables(srcCtx)
+
+	if err != nil {
+		return err
+	}
+
+	dstCtx, err := target.NewWebContext()
+
+	if err != nil {
+		return err
+	}
+
+	// Leftovers from a failed move
+	err = am.PurgeAll(dstCtx, accountId)
+
+	if err != nil {
+		return err
+	}
+
+	util.Debugf("Copying account %s from %s to %s\n", accountId, shard.GetName(), targetShard)
+
+	for _, table := range am.Tables {
+		_, err = am.CopyTable(srcCtx, dstCtx, table, accountId)
+
+		if err != nil {
+			am.PurgeAll(dstCtx, accountId)
+			return err
+		}
+	}
+
+	err = am.Cutover(srcCtx, dstCtx, target, accountId)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Read-only window. Re-sync, verify, flip the shard map and purge the source.
+func (am *AccountMover) Cutover(srcCtx ContextInterface, dstCtx ContextInterface, target *Shard, accountId string) error {
+	_, err := srcCtx.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	err = am.LockAll(srcCtx, accountId)
+
+	if err != nil {
+		srcCtx.Rollback()
+		am.PurgeAll(dstCtx, accountId)
+		return err
+	}
+
+	err = am.SyncAll(srcCtx, dstCtx, accountId)
+
+	if err != nil {
+		srcCtx.Rollback()
+		am.PurgeAll(dstCtx, accountId)
+		return err
+	}
+
+	err = am.FlipShardMap(accountId, target.GetId())
+
+	if err != nil {
+		srcCtx.Rollback()
+		am.PurgeAll(dstCtx, accountId)
+		return err
+	}
+
+	// Shard map now points at the target. Failures from here on leave a stale copy on the source.
+	err = am.PurgeAll(srcCtx, accountId)
+
+	if err != nil {
+		srcCtx.Rollback()
+		return errors.New(fmt.Sprintf("Account %s moved, but purging the source failed: %s", accountId, err))
+	}
+
+	err = srcCtx.Commit()
+
+	if err != nil {
+		return errors.New(fmt.Sprintf("Account %s moved, but purging the source failed: %s", accountId, err))
+	}
+
+	return nil
+}
+
+// Lock key that TenantContext transactions take (shared) before writing an account's rows
+func AccountWriteLockKey(accountId string) string {
+	return "jgoweb.account_write:" + accountId
+}
+
+// Block the account's writes (including inserts of new rows) until the source tx ends. Reads aren't blocked.
+// Row locks aren't enough: they don't stop inserts, which would be purged without being copied.
+func (am *AccountMover) LockAll(ctx ContextInterface, accountId string) error {
+	return ctx.AdvisoryXactLock(AccountWriteLockKey(accountId))
+}
+
+// Error if a table references one of the mover's tables by foreign key but isn't in Tables
+func (am *AccountMover) CheckTables(ctx ContextInterface) error {
+	var names []string
+	var missing []string
+
+	for _, table := range am.Tables {
+		names = append(names, table.Name)
+	}
+
+	query := `
+	SELECT DISTINCT c.conrelid::regclass::text
+	FROM pg_constraint c
+	WHERE c.contype = 'f'
+		AND c.confrelid = ANY(?::regclass[])
+		AND NOT c.conrelid = ANY(?::regclass[])
+	ORDER BY 1`
+
+	list := "{" + strings.Join(names, ",") + "}"
+
+	_, err := ctx.SelectBySql(query, list, list).Load(&missing)
+
+	if err != nil {
+		return err
+	}
+
+	if len(missing) > 0 {
+		return errors.New(fmt.Sprintf("Tables reference account tables but aren't moved: %s", strings.Join(missing, ", ")))
+	}
+
+	return nil
+}
+
+// Re-copy tables that changed during the initial copy, then verify everything.
+// Tables after a changed one may reference it, so they're re-copied too: purged children first (purging a
+// parent first would violate (or cascade through) foreign keys), then copied parents first.
+func (am *AccountMover) SyncAll(srcCtx ContextInterface, dstCtx ContextInterface, accountId string) error {
+	first := len(am.Tables)
+
+	for i, table := range am.Tables {
+		match, err := am.Verify(srcCtx, dstCtx, table, accountId)
+
+		if err != nil {
+			return err
+		}
+
+		if !match {
+			util.Debugf("%s changed during copy. Re-copying it and the tables after it.\n", table.Name)
+			first = i
+			break
+		}
+	}
+
+	for i := len(am.Tables) - 1; i >= first; i-- {
+		err := am.PurgeTable(dstCtx, am.Tables[i], accountId)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, table := range am.Tables[first:] {
+		_, err := am.CopyTable(srcCtx, dstCtx, table, accountId)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, table := range am.Tables {
+		match, err := am.Verify(srcCtx, dstCtx, table, accountId)
+
+		if err != nil {
+			return err
+		}
+
+		if !match {
+			return errors.New(fmt.Sprintf("%s: row count/checksum mismatch after copy", table.Name))
+		}
+	}
+
+	return nil
+}
+
+// Copy the account's rows for one table in batches. Rows go through JSON so any column type copies as is.
+func (am *AccountMover) CopyTable(srcCtx ContextInterface, dstCtx ContextInterface, table AccountMoverTable, accountId string) (uint64, error) {
+	var total uint64
+	var lastKey string
+
+	type batchRow struct {
+		Key  string `db:"key"`
+		Data string `db:"data"`
+	}
+
+	for {
+		var rows []batchRow
+
+		query := fmt.Sprintf(`
+	SELECT t.%s::text AS key, row_to_json(t)::text AS data
+	FROM %s t
+	WHERE t.%s = ?`, table.KeyColumn, table.Name, table.AccountColumn)
+
+		params := []interface{}{accountId}
+
+		if total > 0 {
+			query += fmt.Sprintf(" AND t.%s > ?", table.KeyColumn)
+			params = append(params, lastKey)
+		}
+
+		query += fmt.Sprintf(" ORDER BY t.%s LIMIT %d", table.KeyColumn, am.BatchSize)
+
+		_, err := srcCtx.SelectBySql(query, params...).Load(&rows)
+
+		if err != nil {
+			return total, errors.New(table.Name + ": " + err.Error())
+		}
+
+		if len(rows) == 0 {
+			break
+		}
+
+		data := make([]string, 0)
+
+		for _, row := range rows {
+			data = append(data, row.Data)
+		}
+
+		insert := fmt.Sprintf("INSERT INTO %s SELECT * FROM json_populate_recordset(NULL::%s, ?::json)", table.Name, table.Name)
+
+		_, err = dstCtx.InsertBySql(insert, "["+strings.Join(data, ",")+"]").Exec()
+
+		if err != nil {
+			return total, errors.New(table.Name + ": " + err.Error())
+		}
+
+		total += uint64(len(rows))
+		lastKey = rows[len(rows)-1].Key
+
+		if uint64(len(rows)) < am.BatchSize {
+			break
+		}
+	}
+
+	err := am.SyncSequence(dstCtx, table)
+
+	if err != nil {
+		return total, err
+	}
+
+	util.Debugf("%s: copied %d rows\n", table.Name, total)
+
+	return total, nil
+}
+
+// Keep a serial key's sequence ahead of copied rows
+func (am *AccountMover) SyncSequence(ctx ContextInterface, table AccountMoverTable) error {
+	var seq []sql.NullString
+	var val []string
+
+	_, err := ctx.SelectBySql("SELECT pg_get_serial_sequence(?, ?)", table.Name, table.KeyColumn).Load(&seq)
+
+	if err != nil {
+		return errors.New(table.Name + ": " + err.Error())
+	}
+
+	if len(seq) == 0 || !seq[0].Valid {
+		return nil
+	}
+
+	query := fmt.Sprintf("SELECT setval(?, GREATEST(max(%s), 1))::text FROM %s", table.KeyColumn, table.Name)
+
+	_, err = ctx.SelectBySql(query, seq[0].String).Load(&val)
+
+	if err != nil {
+		return errors.New(table.Name + ": " + err.Error())
+	}
+
+	return nil
+}
+
+// Delete the account's rows in reverse table order
+func (am *AccountMover) PurgeAll(ctx ContextInterface, accountId string) error {
+
+	for i := len(am.Tables) - 1; i >= 0; i-- {
+		err := am.PurgeTable(ctx, am.Tables[i], accountId)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete the account's rows in batches
+func (am *AccountMover) PurgeTable(ctx ContextInterface, table AccountMoverTable, accountId string) error {
+	query := fmt.Sprintf(`
+	DELETE FROM %s
+	WHERE %s IN (SELECT %s FROM %s WHERE %s = ? LIMIT %d)`,
+		table.Name, table.KeyColumn, table.KeyColumn, table.Name, table.AccountColumn, am.BatchSize)
+
+	for {
+		result, err := ctx.UpdateBySql(query, accountId).Exec()
+
+		if err != nil {
+			return errors.New(table.Name + ": " + err.Error())
+		}
+
+		count, err := result.RowsAffected()
+
+		if err != nil {
+			return errors.New(table.Name + ": " + err.Error())
+		}
+
+		if count < int64(am.BatchSize) {
+			return nil
+		}
+	}
+}
+
+// Compare row counts and checksums
+func (am *AccountMover) Verify(srcCtx ContextInterface, dstCtx ContextInterface, table AccountMoverTable, accountId string) (bool, error) {
+	src, err := am.GetChecksum(srcCtx, table, accountId)
+
+	if err != nil {
+		return false, err
+	}
+
+	dst, err := am.GetChecksum(dstCtx, table, accountId)
+
+	if err != nil {
+		return false, err
+	}
+
+	return src.Count == dst.Count && src.Checksum == dst.Checksum, nil
+}
+
+//
+func (am *AccountMover) GetChecksum(ctx ContextInterface, table AccountMoverTable, accountId string) (*AccountMoverChecksum, error) {
+	var checksum []AccountMoverChecksum
+
+	query := fmt.Sprintf(`
+	SELECT
+		count(*) AS count,
+		md5(string_agg(row_to_json(t)::text, '' ORDER BY t.%s)) AS checksum
+	FROM %s t
+	WHERE t.%s = ?`, table.KeyColumn, table.Name, table.AccountColumn)
+
+	_, err := ctx.SelectBySql(query, accountId).Load(&checksum)
+
+	if err != nil {
+		return nil, errors.New(table.Name + ": " + err.Error())
+	}
+
+	if len(checksum) == 0 {
+		return &AccountMoverChecksum{}, nil
+	}
+
+	return &checksum[0], nil
+}
+
+// Point the account's shard map rows at the new shard on every DB
+func (am *AccountMover) FlipShardMap(accountId string, shardId string) error {
+	ct := NewClusterTransaction(am.Ctx.GetDb())
+	ct.NotifyShardRouteChange(accountId)
+
+	return ct.Run(func(shardName string, ctx ContextInterface) error {
+		_, err := ctx.Update("system.shard_map").
+			Set("shard_id", shardId).
+			Set("updated_at", time.Now().Format(time.RFC3339)).
+			Where("account_id = ?", accountId).
+			Exec()
+
+		return err
+	})
+}
+
+// Move an account to targetShard using the default table list
+func MoveAccount(ctx ContextInterface, accountId string, targetShard string) error {
+	return NewAccountMover(ctx).Move(accountId, targetShard)
+}
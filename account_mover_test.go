@@ -0,0 +1,270 @@
+// +build integration
+
+package jgoweb
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+//
+func TestAccountMoverSameShard(t *testing.T) {
+	InitMockShard()
+
+	err := MoveAccount(MockCtx, MockUser.GetAccountId(), MockShard.GetName())
+
+	if err == nil {
+		t.Errorf("ERROR: Moving an account to its current shard should fail.")
+	}
+}
+
+//
+func TestAccountMoverInvalidShard(t *testing.T) {
+	InitMockShard()
+
+	err := MoveAccount(MockCtx, MockUser.GetAccountId(), "invalid_shard_name")
+
+	if err == nil {
+		t.Errorf("ERROR: Moving an account to an invalid shard should fail.")
+	}
+}
+
+//
+func TestAccountMoverGetChecksum(t *testing.T) {
+	InitMockShard()
+
+	am := NewAccountMover(MockCtx)
+
+	for _, table := range am.Tables {
+		checksum, err := am.GetChecksum(MockCtx, table, MockUser.GetAccountId())
+
+		if err != nil {
+			t.Errorf("ERROR: %v", err)
+			continue
+		}
+
+		match, err := am.Verify(MockCtx, MockCtx, table, MockUser.GetAccountId())
+
+		if err != nil {
+			t.Errorf("ERROR: %v", err)
+			continue
+		}
+
+		if !match {
+			t.Errorf("ERROR: %s: checksum should match itself (%+v)", table.Name, checksum)
+		}
+	}
+}
+
+// Needs a second shard
+func TestAccountMoverMove(t *testing.T) {
+	var target *Shard
+	var err error
+
+	InitMockShard()
+
+	for shardName := range MockCtx.GetDb().GetConns() {
+		if shardName == MockShard.GetName() {
+			continue
+		}
+
+		target, err = FetchShardByName(MockCtx, shardName)
+
+		if err != nil {
+			t.Errorf("ERROR: %v", err)
+			return
+		}
+
+		if target != nil {
+			break
+		}
+	}
+
+	if target == nil {
+		t.Skip("Moving an account needs a second shard.")
+	}
+
+	suffix := time.Now().UnixNano()
+	domain := fmt.Sprintf("move_test_%d.example.com", suffix)
+	email := fmt.Sprintf("move_test_%d@example.com", suffix)
+
+	err = CreateAccount(MockCtx, MockShard.GetName(), domain)
+
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+		return
+	}
+
+	srcCtx, err := MockShard.NewWebContext()
+
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+		return
+	}
+
+	dstCtx, err := target.NewWebContext()
+
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+		return
+	}
+
+	account, err := FetchAccountByDomain(srcCtx, domain)
+
+	if err != nil || account == nil {
+		t.Errorf("ERROR: Test account wasn't created: %v", err)
+		return
+	}
+
+	accountId := account.GetId()
+	am := NewAccountMover(MockCtx)
+
+	defer func() {
+		am.PurgeAll(srcCtx, accountId)
+		am.PurgeAll(dstCtx, accountId)
+
+		NewClusterTransaction(MockCtx.GetDb()).Run(func(shardName string, ctx ContextInterface) error {
+			_, err := ctx.DeleteFrom("system.shard_map").Where("account_id = ?", accountId).Exec()
+
+			return err
+		})
+	}()
+
+	_, err = CreateUser(MockCtx, accountId, email, "Move", "Test", MockUser.GetRoleId(), "test-password")
+
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+		return
+	}
+
+	err = am.Move(accountId, target.GetName())
+
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+		return
+	}
+
+	// copied
+	account, err = FetchAccountById(dstCtx, accountId)
+
+	if err != nil || account == nil {
+		t.Errorf("ERROR: Account wasn't copied to %s: %v", target.GetName(), err)
+	}
+
+	user, err := FetchUserByEmail(dstCtx, email)
+
+	if err != nil || user == nil || user.GetAccountId() != accountId {
+		t.Errorf("ERROR: User wasn't copied to %s: %v", target.GetName(), err)
+	}
+
+	// purged
+	account, err = FetchAccountById(srcCtx, accountId)
+
+	if err != nil || account != nil {
+		t.Errorf("ERROR: Account wasn't purged from %s: %v", MockShard.GetName(), err)
+	}
+
+	user, err = FetchUserByEmail(srcCtx, email)
+
+	if err != nil || user != nil {
+		t.Errorf("ERROR: User wasn't purged from %s: %v", MockShard.GetName(), err)
+	}
+
+	// shard map flipped everywhere
+	results := NewClusterExecutor(MockCtx.GetDb()).Run(context.Background(), func(goCtx context.Context, ctx ContextInterface) (interface{}, error) {
+		var shardIds []string
+
+		_, err := ctx.Select("DISTINCT shard_id").
+			From("system.shard_map").
+			Where("account_id = ?", accountId).
+			LoadContext(goCtx, &shardIds)
+
+		return shardIds, err
+	})
+
+	if results.Err() != nil {
+		t.Errorf("ERROR: %v", results.Err())
+		return
+	}
+
+	for _, result := range results {
+		shardIds := result.Value.([]string)
+
+		if len(shardIds) != 1 || shardIds[0] != target.GetId() {
+			t.Errorf("ERROR: %s: Expected shard_map to point at %s. Got: %v", result.ShardName, target.GetId(), shardIds)
+		}
+	}
+}
+
+// Tenant transactions for the account wait for the cutover window
+func TestAccountMoverLockAll(t *testing.T) {
+	InitMockAccount()
+	InitMockShard()
+
+	srcCtx, err := MockShard.NewWebContext()
+
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+		return
+	}
+
+	_, err = srcCtx.Begin()
+
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+		return
+	}
+
+	err = NewAccountMover(srcCtx).LockAll(srcCtx, MockAccount.GetId())
+
+	if err != nil {
+		srcCtx.Rollback()
+		t.Errorf("ERROR: %v", err)
+		return
+	}
+
+	ctx, err := MockShard.NewWebContext()
+
+	if err != nil {
+		srcCtx.Rollback()
+		t.Errorf("ERROR: %v", err)
+		return
+	}
+
+	tc, err := NewTenantContext(ctx, MockAccount.GetId(), "")
+
+	if err != nil {
+		srcCtx.Rollback()
+		t.Errorf("ERROR: %v", err)
+		return
+	}
+
+	done := make(chan error, 1)
+
+	go func() {
+		_, err := tc.Begin()
+		done <- err
+	}()
+
+	select {
+	case err = <-done:
+		t.Errorf("ERROR: Tenant transaction should wait for the cutover lock. Got: %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	srcCtx.Rollback()
+
+	select {
+	case err = <-done:
+		if err != nil {
+			t.Errorf("ERROR: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("ERROR: Tenant transaction still waiting after the cutover lock was released.")
+		return
+	}
+
+	tc.Rollback()
+}
@@ -3,10 +3,12 @@
 package jgoweb
 
 import (
+	"fmt"
 	"github.com/gocraft/web"
 	"net/http"
 	"strings"
 	"testing"
+	"time"
 )
 
 //
@@ -373,3 +375,81 @@ func TestClusterGetAccounts(t *testing.T) {
 		t.Errorf("\nERROR: %v\n", err)
 	}
 }
+
+//
+func TestClusterGetAllAccounts(t *testing.T) {
+	InitMockCtx()
+
+	_, err := ClusterGetAllAccounts(MockCtx, 10)
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+	}
+}
+
+//
+func TestCreateAccount(t *testing.T) {
+	InitMockCtx()
+	InitMockShard()
+
+	domain := fmt.Sprintf("create_account_%d.example.com", time.Now().UnixNano())
+
+	err := CreateAccount(MockCtx, "", domain)
+
+	if err != nil && err.Error() != "No shard is accepting accounts" {
+		t.Errorf("\nERROR: Unexpected placement error: %v\n", err)
+	}
+
+	domain = fmt.Sprintf("create_account_%d.example.com", time.Now().UnixNano())
+
+	err = CreateAccount(MockCtx, MockShard.GetName(), domain)
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+		return
+	}
+
+	srcCtx, err := MockShard.NewWebContext()
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+		return
+	}
+
+	account, err := FetchAccountByDomain(srcCtx, domain)
+
+	if err != nil || account == nil {
+		t.Errorf("\nERROR: Account wasn't created: %v\n", err)
+		return
+	}
+
+	// every shard routes the account
+	for shardName := range MockCtx.Db.GetConns() {
+		dbSess, err := MockCtx.Db.GetSessionByName(shardName)
+
+		if err != nil {
+			t.Errorf("\nERROR: %v\n", err)
+			return
+		}
+
+		ctx := NewContext(MockCtx.Db)
+		ctx.SetDbSession(dbSess)
+
+		shardMap, err := FetchShardMapByDomainAccountId(ctx, domain, account.GetId())
+
+		if err != nil || shardMap == nil || shardMap.GetShardId() != MockShard.GetId() {
+			t.Errorf("\nERROR: %s: Missing shard map: %v Error: %v\n", shardName, shardMap, err)
+		}
+	}
+}
+
+//
+func TestCreateAccountUnknownShard(t *testing.T) {
+	InitMockCtx()
+
+	err := CreateAccount(MockCtx, "no_such_shard", "no_such_shard.example.com")
+
+	if err == nil || !strings.Contains(err.Error(), "no_such_shard") {
+		t.Errorf("\nERROR: Expected unknown shard error. Got: %v\n", err)
+	}
+}
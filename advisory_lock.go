@@ -0,0 +1,166 @@
+package jgoweb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// How often TryAdvisoryLock/TryAdvisoryXactLock retry until the timeout
+var AdvisoryLockPollInterval = 100 * time.Millisecond
+
+// Session scoped advisory lock. Holds a dedicated connection until Unlock.
+// If the connection drops Postgres releases the lock (see Check).
+type AdvisoryLock struct {
+	Key  string
+	Id   int64
+	conn *sql.Conn
+	mu   sync.Mutex
+}
+
+// Lock keys are hashed to Postgres' bigint lock ids, so every instance maps a key to the same lock
+func AdvisoryLockId(key string) int64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(key))
+
+	return int64(hash.Sum64())
+}
+
+// Retry fn until it acquires the lock or the timeout expires. timeout <= 0 = try once.
+func pollAdvisoryLock(timeout time.Duration, fn func() (bool, error)) (bool, error) {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		acquired, err := fn()
+
+		if err != nil || acquired {
+			return acquired, err
+		}
+
+		if !time.Now().Before(deadline) {
+			return false, nil
+		}
+
+		time.Sleep(AdvisoryLockPollInterval)
+	}
+}
+
+//
+func (ctx *WebContext) getAdvisoryLockConn() (*sql.Conn, error) {
+
+	if ctx.DbSess == nil {
+		return nil, errors.New("Cannot lock. No DB session set in context.")
+	}
+
+	return ctx.DbSess.Connection.DB.Conn(context.Background())
+}
+
+// Wait for a session scoped lock
+func (ctx *WebContext) AdvisoryLock(key string) (*AdvisoryLock, error) {
+	lock := &AdvisoryLock{Key: key, Id: AdvisoryLockId(key)}
+	conn, err := ctx.getAdvisoryLockConn()
+
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = conn.ExecContext(context.Background(), "SELECT pg_advisory_lock($1)", lock.Id)
+
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	lock.conn = conn
+
+	return lock, nil
+}
+
+// Session scoped lock. Returns nil (and no error) if the lock is still held by someone else after timeout.
+func (ctx *WebContext) TryAdvisoryLock(key string, timeout time.Duration) (*AdvisoryLock, error) {
+	lock := &AdvisoryLock{Key: key, Id: AdvisoryLockId(key)}
+	conn, err := ctx.getAdvisoryLockConn()
+
+	if err != nil {
+		return nil, err
+	}
+
+	acquired, err := pollAdvisoryLock(timeout, func() (bool, error) {
+		var acquired bool
+		err := conn.QueryRowContext(context.Background(), "SELECT pg_try_advisory_lock($1)", lock.Id).Scan(&acquired)
+
+		return acquired, err
+	})
+
+	if err != nil || !acquired {
+		conn.Close()
+		return nil, err
+	}
+
+	lock.conn = conn
+
+	return lock, nil
+}
+
+// Wait for a lock that's released when the context's transaction ends
+func (ctx *WebContext) AdvisoryXactLock(key string) error {
+
+	if ctx.Tx == nil {
+		return errors.New("Cannot lock. No transaction set in context.")
+	}
+
+	_, err := ctx.Tx.Exec("SELECT pg_advisory_xact_lock($1)", AdvisoryLockId(key))
+
+	return err
+}
+
+// Transaction scoped lock. false if the lock is still held by someone else after timeout.
+func (ctx *WebContext) TryAdvisoryXactLock(key string, timeout time.Duration) (bool, error) {
+
+	if ctx.Tx == nil {
+		return false, errors.New("Cannot lock. No transaction set in context.")
+	}
+
+	return pollAdvisoryLock(timeout, func() (bool, error) {
+		var acquired bool
+		err := ctx.Tx.QueryRow("SELECT pg_try_advisory_xact_lock($1)", AdvisoryLockId(key)).Scan(&acquired)
+
+		return acquired, err
+	})
+}
+
+// ******
+
+// Error if the lock's connection is gone (and with it, the lock)
+func (al *AdvisoryLock) Check() error {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	if al.conn == nil {
+		return errors.New("Advisory lock " + al.Key + " has been released.")
+	}
+
+	_, err := al.conn.ExecContext(context.Background(), "SELECT 1")
+
+	return err
+}
+
+// Release the lock and its connection. Safe to call more than once.
+func (al *AdvisoryLock) Unlock() error {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	if al.conn == nil {
+		return nil
+	}
+
+	_, err := al.conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", al.Id)
+
+	al.conn.Close()
+	al.conn = nil
+
+	return err
+}
@@ -0,0 +1,119 @@
+// +build integration
+
+package jgoweb
+
+import (
+	"context"
+	"github.com/gocraft/dbr"
+	"testing"
+	"time"
+)
+
+//
+func TestTryAdvisoryLock(t *testing.T) {
+	InitMockCtx()
+
+	lock, err := MockCtx.TryAdvisoryLock("jgoweb.test_lock", 0)
+
+	if err != nil || lock == nil {
+		t.Errorf("\nERROR: Failed to lock. Error: %v\n", err)
+		return
+	}
+
+	other, err := MockCtx.TryAdvisoryLock("jgoweb.test_lock", 50*time.Millisecond)
+
+	if err != nil || other != nil {
+		t.Errorf("\nERROR: Lock should already be held. Error: %v\n", err)
+	}
+
+	err = lock.Unlock()
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+	}
+
+	other, err = MockCtx.TryAdvisoryLock("jgoweb.test_lock", 0)
+
+	if err != nil || other == nil {
+		t.Errorf("\nERROR: Failed to lock after unlock. Error: %v\n", err)
+		return
+	}
+
+	other.Unlock()
+}
+
+//
+func TestTryAdvisoryXactLock(t *testing.T) {
+	InitMockCtx()
+
+	err := MockCtx.WithTransaction(func(tx *dbr.Tx) error {
+		acquired, err := MockCtx.TryAdvisoryXactLock("jgoweb.test_xact_lock", 0)
+
+		if err != nil || !acquired {
+			t.Errorf("\nERROR: Failed to lock. Error: %v\n", err)
+		}
+
+		// session lock on another connection can't get it
+		lock, err := MockCtx.TryAdvisoryLock("jgoweb.test_xact_lock", 0)
+
+		if err != nil || lock != nil {
+			t.Errorf("\nERROR: Lock should be held by the transaction. Error: %v\n", err)
+		}
+
+		if lock != nil {
+			lock.Unlock()
+		}
+
+		return nil
+	}, nil)
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+	}
+}
+
+//
+func TestLeaderElector(t *testing.T) {
+	InitMockCtx()
+
+	elected := make(chan string, 2)
+
+	first := NewLeaderElector(MockCtx, "jgoweb.test_leader")
+	first.OnElected = func(goCtx context.Context) { elected <- "first" }
+
+	second := NewLeaderElector(MockCtx, "jgoweb.test_leader")
+	second.OnElected = func(goCtx context.Context) { elected <- "second" }
+
+	err := first.Elect()
+
+	if err != nil || !first.IsLeader() {
+		t.Errorf("\nERROR: First elector should lead. Error: %v\n", err)
+	}
+
+	err = second.Elect()
+
+	if err != nil || second.IsLeader() {
+		t.Errorf("\nERROR: Second elector should not lead. Error: %v\n", err)
+	}
+
+	first.Stop()
+
+	err = second.Elect()
+
+	if err != nil || !second.IsLeader() {
+		t.Errorf("\nERROR: Second elector should take over. Error: %v\n", err)
+	}
+
+	second.Stop()
+
+	for _, expected := range []string{"first", "second"} {
+		select {
+		case name := <-elected:
+			if name != expected {
+				t.Errorf("\nERROR: Expected %s to be elected. Got: %s\n", expected, name)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("\nERROR: OnElected not called for %s\n", expected)
+		}
+	}
+}
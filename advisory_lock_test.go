@@ -0,0 +1,82 @@
+// +build unit
+
+package jgoweb
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+//
+func TestAdvisoryLockId(t *testing.T) {
+
+	if AdvisoryLockId("jgoweb.test") != AdvisoryLockId("jgoweb.test") {
+		t.Errorf("\nERROR: Lock ids should be deterministic\n")
+	}
+
+	if AdvisoryLockId("jgoweb.test") == AdvisoryLockId("jgoweb.other") {
+		t.Errorf("\nERROR: Lock ids should differ by key\n")
+	}
+}
+
+//
+func TestPollAdvisoryLock(t *testing.T) {
+	interval := AdvisoryLockPollInterval
+	AdvisoryLockPollInterval = time.Millisecond
+	defer func() { AdvisoryLockPollInterval = interval }()
+
+	tries := 0
+
+	acquired, err := pollAdvisoryLock(0, func() (bool, error) {
+		tries++
+		return false, nil
+	})
+
+	if acquired || err != nil || tries != 1 {
+		t.Errorf("\nERROR: Expected a single failed try. Tries: %d Acquired: %v Error: %v\n", tries, acquired, err)
+	}
+
+	tries = 0
+
+	acquired, err = pollAdvisoryLock(time.Second, func() (bool, error) {
+		tries++
+		return tries == 3, nil
+	})
+
+	if !acquired || err != nil || tries != 3 {
+		t.Errorf("\nERROR: Expected lock on third try. Tries: %d Acquired: %v Error: %v\n", tries, acquired, err)
+	}
+
+	_, err = pollAdvisoryLock(time.Second, func() (bool, error) {
+		return false, errors.New("connection lost")
+	})
+
+	if err == nil {
+		t.Errorf("\nERROR: Expected error\n")
+	}
+}
+
+//
+func TestAdvisoryLockNoSession(t *testing.T) {
+	ctx := &WebContext{}
+
+	lock, err := ctx.TryAdvisoryLock("jgoweb.test", 0)
+
+	if err == nil || lock != nil {
+		t.Errorf("\nERROR: Expected error without a DB session\n")
+	}
+
+	_, err = ctx.TryAdvisoryXactLock("jgoweb.test", 0)
+
+	if err == nil {
+		t.Errorf("\nERROR: Expected error without a transaction\n")
+	}
+
+	// released locks are safe to unlock again
+	err = (&AdvisoryLock{Key: "jgoweb.test"}).Unlock()
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+	}
+}
@@ -0,0 +1,248 @@
+package jgoweb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	jgoWebDb "github.com/jschneider98/jgoweb/db"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Function run against a single shard. shardCtx has a DB session bound to the shard.
+type ClusterFunc func(goCtx context.Context, shardCtx ContextInterface) (interface{}, error)
+
+// ClusterFunc that's told which shard it's running against
+type ClusterShardFunc func(goCtx context.Context, shardName string, shardCtx ContextInterface) (interface{}, error)
+
+// Result of a ClusterFunc for one shard
+type ClusterResult struct {
+	ShardName string
+	Value     interface{}
+	Err       error
+	Duration  time.Duration
+}
+
+// Per shard results (sorted by shard name)
+type ClusterResults []ClusterResult
+
+// Runs a function against every shard (or a subset of shards) in parallel
+type ClusterExecutor struct {
+	Db             *jgoWebDb.Collection
+	ShardNames     []string
+	MaxConcurrency int
+	Timeout        time.Duration
+	UseReplicas    bool
+}
+
+//
+func NewClusterExecutor(db *jgoWebDb.Collection) *ClusterExecutor {
+	ce := &ClusterExecutor{Db: db}
+
+	// 0 = one goroutine per shard
+	ce.MaxConcurrency = 0
+
+	// 0 = no per shard timeout
+	ce.Timeout = 0
+
+	return ce
+}
+
+// Limit execution to a subset of shards
+func (ce *ClusterExecutor) SetShardNames(shardNames ...string) *ClusterExecutor {
+	ce.ShardNames = shardNames
+
+	return ce
+}
+
+//
+func (ce *ClusterExecutor) GetShardNames() []string {
+	shardNames := make([]string, 0)
+
+	if len(ce.ShardNames) > 0 {
+		shardNames = append(shardNames, ce.ShardNames...)
+	} else {
+		for shardName := range ce.Db.GetConns() {
+			shardNames = append(shardNames, shardName)
+		}
+	}
+
+	sort.Strings(shardNames)
+
+	return shardNames
+}
+
+// Run fn against each shard. Errors are reported per shard. Use ClusterResults.Err() for a combined error.
+func (ce *ClusterExecutor) Run(goCtx context.Context, fn ClusterFunc) ClusterResults {
+	return ce.RunWithShardName(goCtx, func(goCtx context.Context, shardName string, shardCtx ContextInterface) (interface{}, error) {
+		return fn(goCtx, shardCtx)
+	})
+}
+
+// Run, passing each shard's name to fn
+func (ce *ClusterExecutor) RunWithShardName(goCtx context.Context, fn ClusterShardFunc) ClusterResults {
+	shardNames := ce.GetShardNames()
+	results := make(ClusterResults, len(shardNames))
+
+	limit := ce.MaxConcurrency
+
+	if limit <= 0 || limit > len(shardNames) {
+		limit = len(shardNames)
+	}
+
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+
+	for key, shardName := range shardNames {
+		wg.Add(1)
+
+		go func(key int, shardName string) {
+			defer wg.Done()
+
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-goCtx.Done():
+				results[key] = ClusterResult{ShardName: shardName, Err: goCtx.Err()}
+				return
+			}
+
+			results[key] = ce.RunShard(goCtx, shardName, func(goCtx context.Context, shardCtx ContextInterface) (interface{}, error) {
+				return fn(goCtx, shardName, shardCtx)
+			})
+		}(key, shardName)
+	}
+
+	wg.Wait()
+
+	return results
+}
+
+// Run fn against a single shard, enforcing the per shard timeout. fn runs on the calling goroutine, so it must use
+// goCtx for queries (e.g., LoadContext, ExecContext) to be cancelled. shardCtx's transactions are bound to goCtx.
+func (ce *ClusterExecutor) RunShard(goCtx context.Context, shardName string, fn ClusterFunc) ClusterResult {
+	start := time.Now()
+	result := ClusterResult{ShardName: shardName}
+
+	if ce.Timeout > 0 {
+		var cancel context.CancelFunc
+		goCtx, cancel = context.WithTimeout(goCtx, ce.Timeout)
+		defer cancel()
+	}
+
+	dbSess, err := ce.Db.GetSessionByName(shardName)
+
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	if ce.Timeout > 0 && (dbSess.Timeout == 0 || dbSess.Timeout > ce.Timeout) {
+		dbSess.Timeout = ce.Timeout
+	}
+
+	shardCtx := NewContext(ce.Db)
+	shardCtx.SetDbSession(dbSess)
+	shardCtx.SetGoContext(goCtx)
+
+	// read only cluster queries (reports etc) can run against replicas
+	shardCtx.UseReplicas = ce.UseReplicas
+
+	result.Value, result.Err = fn(goCtx, shardCtx)
+
+	// report the timeout/cancel rather than the query error it caused
+	if goCtx.Err() != nil {
+		result.Err = goCtx.Err()
+	}
+
+	result.Duration = time.Since(start)
+
+	return result
+}
+
+// ******
+
+// Combined error for all shards (nil if every shard succeeded)
+func (cr ClusterResults) Err() error {
+	var msg string
+
+	for _, result := range cr {
+		if result.Err != nil {
+			msg += result.ShardName + ": " + result.Err.Error() + "\n"
+		}
+	}
+
+	if msg != "" {
+		return errors.New(msg)
+	}
+
+	return nil
+}
+
+// Results keyed by shard name
+func (cr ClusterResults) Values() map[string]interface{} {
+	values := make(map[string]interface{})
+
+	for _, result := range cr {
+		values[result.ShardName] = result.Value
+	}
+
+	return values
+}
+
+// Append every shard's slice result to dest (a pointer to a slice), in shard name order.
+// Shards that errored are skipped.
+func (cr ClusterResults) MergeInto(dest interface{}) error {
+	destVal := reflect.ValueOf(dest)
+
+	if destVal.Kind() != reflect.Ptr || destVal.Elem().Kind() != reflect.Slice {
+		return errors.New("ClusterResults.MergeInto: dest must be a pointer to a slice")
+	}
+
+	sliceVal := destVal.Elem()
+
+	for _, result := range cr {
+		if result.Err != nil || result.Value == nil {
+			continue
+		}
+
+		val := reflect.ValueOf(result.Value)
+
+		if val.Kind() != reflect.Slice {
+			return errors.New(fmt.Sprintf("ClusterResults.MergeInto: %s: result is not a slice", result.ShardName))
+		}
+
+		if !val.Type().AssignableTo(sliceVal.Type()) {
+			return errors.New(fmt.Sprintf("ClusterResults.MergeInto: %s: can't merge %v into %v", result.ShardName, val.Type(), sliceVal.Type()))
+		}
+
+		sliceVal = reflect.AppendSlice(sliceVal, val)
+	}
+
+	destVal.Elem().Set(sliceVal)
+
+	return nil
+}
+
+// Merge, sort (less compares two elements of the slice) and trim to limit (0 = no limit)
+func (cr ClusterResults) MergeSorted(dest interface{}, less func(a interface{}, b interface{}) bool, limit int) error {
+	err := cr.MergeInto(dest)
+
+	if err != nil {
+		return err
+	}
+
+	sliceVal := reflect.ValueOf(dest).Elem()
+
+	sort.SliceStable(sliceVal.Interface(), func(i, j int) bool {
+		return less(sliceVal.Index(i).Interface(), sliceVal.Index(j).Interface())
+	})
+
+	if limit > 0 && sliceVal.Len() > limit {
+		sliceVal.Set(sliceVal.Slice(0, limit))
+	}
+
+	return nil
+}
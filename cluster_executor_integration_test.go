@@ -0,0 +1,101 @@
+// +build integration
+
+package jgoweb
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+//
+func TestClusterExecutorRun(t *testing.T) {
+	InitMockCtx()
+
+	ce := NewClusterExecutor(MockCtx.GetDb())
+	ce.MaxConcurrency = 2
+
+	results := ce.Run(context.Background(), func(goCtx context.Context, shardCtx ContextInterface) (interface{}, error) {
+		return GetAllShards(shardCtx)
+	})
+
+	if results.Err() != nil {
+		t.Errorf("ERROR: %v", results.Err())
+	}
+
+	if len(results) != len(MockCtx.GetDb().GetConns()) {
+		t.Errorf("ERROR: Expected a result per shard. Got: %v", len(results))
+	}
+}
+
+//
+func TestClusterExecutorRunWithShardName(t *testing.T) {
+	InitMockCtx()
+
+	ce := NewClusterExecutor(MockCtx.GetDb())
+
+	results := ce.RunWithShardName(context.Background(), func(goCtx context.Context, shardName string, shardCtx ContextInterface) (interface{}, error) {
+		return shardName, nil
+	})
+
+	if results.Err() != nil {
+		t.Errorf("ERROR: %v", results.Err())
+	}
+
+	for _, result := range results {
+		if result.Value != result.ShardName {
+			t.Errorf("ERROR: Expected shard name %s. Got: %v", result.ShardName, result.Value)
+		}
+	}
+}
+
+//
+func TestClusterExecutorTimeout(t *testing.T) {
+	InitMockCtx()
+
+	ce := NewClusterExecutor(MockCtx.GetDb()).SetShardNames(appConfig.Integration.ShardName)
+	ce.Timeout = 10 * time.Millisecond
+
+	results := ce.Run(context.Background(), func(goCtx context.Context, shardCtx ContextInterface) (interface{}, error) {
+		time.Sleep(100 * time.Millisecond)
+		return nil, nil
+	})
+
+	if len(results) != 1 {
+		t.Errorf("ERROR: Expected 1 result. Got: %v", len(results))
+		return
+	}
+
+	if results[0].Err != context.DeadlineExceeded {
+		t.Errorf("ERROR: Expected deadline exceeded. Got: %v", results[0].Err)
+	}
+}
+
+// Queries using goCtx (and shardCtx's go context) are cancelled, so nothing keeps running after the timeout
+func TestClusterExecutorTimeoutCancelsQuery(t *testing.T) {
+	InitMockCtx()
+
+	ce := NewClusterExecutor(MockCtx.GetDb()).SetShardNames(appConfig.Integration.ShardName)
+	ce.Timeout = 50 * time.Millisecond
+	start := time.Now()
+
+	results := ce.Run(context.Background(), func(goCtx context.Context, shardCtx ContextInterface) (interface{}, error) {
+		var slept []string
+
+		if shardCtx.GetGoContext() != goCtx {
+			t.Errorf("ERROR: shardCtx should be bound to goCtx")
+		}
+
+		_, err := shardCtx.SelectBySql("SELECT pg_sleep(5)::text").LoadContext(shardCtx.GetGoContext(), &slept)
+
+		return nil, err
+	})
+
+	if time.Since(start) > 2*time.Second {
+		t.Errorf("ERROR: Query wasn't cancelled. Took: %v", time.Since(start))
+	}
+
+	if len(results) != 1 || results[0].Err != context.DeadlineExceeded {
+		t.Errorf("ERROR: Expected deadline exceeded. Got: %v", results)
+	}
+}
@@ -0,0 +1,67 @@
+// +build unit
+
+package jgoweb
+
+import (
+	"errors"
+	"testing"
+)
+
+//
+func TestClusterResultsErr(t *testing.T) {
+	results := ClusterResults{
+		ClusterResult{ShardName: "a"},
+		ClusterResult{ShardName: "b"},
+	}
+
+	if results.Err() != nil {
+		t.Errorf("ERROR: Expected nil error. Got: %v", results.Err())
+	}
+
+	results[1].Err = errors.New("test")
+
+	if results.Err() == nil || results.Err().Error() != "b: test\n" {
+		t.Errorf("ERROR: Unexpected combined error: %v", results.Err())
+	}
+}
+
+//
+func TestClusterResultsMergeSorted(t *testing.T) {
+	var merged []int
+
+	results := ClusterResults{
+		ClusterResult{ShardName: "a", Value: []int{5, 1, 3}},
+		ClusterResult{ShardName: "b", Value: []int{4, 2}},
+		ClusterResult{ShardName: "c", Err: errors.New("skipped")},
+	}
+
+	err := results.MergeSorted(&merged, func(a interface{}, b interface{}) bool {
+		return a.(int) < b.(int)
+	}, 3)
+
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+		return
+	}
+
+	expected := []int{1, 2, 3}
+
+	if len(merged) != len(expected) {
+		t.Errorf("ERROR: Expected %v. Got: %v", expected, merged)
+		return
+	}
+
+	for key := range expected {
+		if merged[key] != expected[key] {
+			t.Errorf("ERROR: Expected %v. Got: %v", expected, merged)
+		}
+	}
+
+	var bad []string
+
+	err = results.MergeInto(&bad)
+
+	if err == nil {
+		t.Errorf("ERROR: Merging mismatched types should fail.")
+	}
+}
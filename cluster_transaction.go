@@ -0,0 +1,400 @@
+package jgoweb
+
+import (
+	"errors"
+	"fmt"
+	jgoWebDb "github.com/jschneider98/jgoweb/db"
+	"github.com/jschneider98/jgoweb/util"
+	"github.com/lib/pq"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Prefix for prepared transaction ids created by ClusterTransaction
+const ClusterTransactionGidPrefix = "jgoweb"
+
+// Applies a change to every DB in the collection using Postgres two-phase commit.
+// Either every DB commits the change or none do. Requires max_prepared_transactions > 0.
+//
+// The first shard (by name) acts as coordinator. It's prepared last and committed first, so its commit
+// is the commit decision. Every prepared transaction id (gid) records the coordinator's shard name and
+// transaction id, which lets RecoverClusterTransactions resolve leftovers after a crash.
+type ClusterTransaction struct {
+	Db          *jgoWebDb.Collection
+	ShardNames  []string
+	Coordinator string
+	CoordXid    string
+	ctxs        map[string]*WebContext
+	prepared    []string
+	onCommit    []func() error
+}
+
+//
+func NewClusterTransaction(db *jgoWebDb.Collection) *ClusterTransaction {
+	ct := &ClusterTransaction{Db: db}
+	ct.ctxs = make(map[string]*WebContext)
+	ct.prepared = make([]string, 0)
+
+	for shardName := range db.GetConns() {
+		ct.ShardNames = append(ct.ShardNames, shardName)
+	}
+
+	sort.Strings(ct.ShardNames)
+
+	if len(ct.ShardNames) > 0 {
+		ct.Coordinator = ct.ShardNames[0]
+	}
+
+	return ct
+}
+
+// Run fn inside a transaction on every DB, then commit all of them atomically
+func (ct *ClusterTransaction) Run(fn func(shardName string, ctx ContextInterface) error) error {
+	var err error
+
+	if len(ct.ShardNames) == 0 {
+		return errors.New("Empty DB Config")
+	}
+
+	err = ct.apply(fn)
+
+	if err != nil {
+		ct.rollbackAll()
+		return err
+	}
+
+	err = ct.prepareAll()
+
+	if err != nil {
+		ct.rollbackAll()
+		return err
+	}
+
+	err = ct.commitAll()
+
+	if err != nil {
+		return err
+	}
+
+	ct.runOnCommit()
+
+	return nil
+}
+
+// Run fn after every DB has committed. For things that can't be prepared (e.g., NOTIFY).
+// Errors are logged, not returned: the change is already committed.
+func (ct *ClusterTransaction) OnCommit(fn func() error) {
+	ct.onCommit = append(ct.onCommit, fn)
+}
+
+//
+func (ct *ClusterTransaction) runOnCommit() {
+
+	for _, fn := range ct.onCommit {
+		err := fn()
+
+		if err != nil {
+			log.Printf("ERROR: %s %s", util.WhereAmI(), err)
+		}
+	}
+}
+
+// Begin a transaction on each DB and apply fn
+func (ct *ClusterTransaction) apply(fn func(shardName string, ctx ContextInterface) error) error {
+
+	for _, shardName := range ct.ShardNames {
+		dbSess, err := ct.Db.GetSessionByName(shardName)
+
+		if err != nil {
+			return errors.New(shardName + ": " + err.Error())
+		}
+
+		curCtx := NewContext(ct.Db)
+		curCtx.SetDbSession(dbSess)
+
+		_, err = curCtx.Begin()
+
+		if err != nil {
+			return errors.New(shardName + ": " + err.Error())
+		}
+
+		ct.ctxs[shardName] = curCtx
+
+		err = fn(shardName, curCtx)
+
+		if err != nil {
+			return errors.New(shardName + ": " + err.Error())
+		}
+	}
+
+	// Force the coordinator to get a transaction id so participants can reference it
+	_, err := ct.ctxs[ct.Coordinator].SelectBySql("SELECT txid_current()").Load(&ct.CoordXid)
+
+	if err != nil {
+		return errors.New(ct.Coordinator + ": " + err.Error())
+	}
+
+	return nil
+}
+
+// Phase 1. Coordinator is prepared last.
+func (ct *ClusterTransaction) prepareAll() error {
+	shardNames := make([]string, 0)
+	shardNames = append(shardNames, ct.ShardNames[1:]...)
+	shardNames = append(shardNames, ct.Coordinator)
+
+	for _, shardName := range shardNames {
+		curCtx := ct.ctxs[shardName]
+
+		_, err := curCtx.Tx.Exec("PREPARE TRANSACTION " + pq.QuoteLiteral(ct.GetGid(shardName)))
+
+		// PREPARE TRANSACTION detaches the transaction from the connection, so the driver's tx can't
+		// be committed or rolled back. Rolling it back only releases it (the driver discards the connection).
+		curCtx.Tx.Rollback()
+		curCtx.Tx = nil
+
+		if err != nil {
+			return errors.New(shardName + ": " + err.Error())
+		}
+
+		ct.prepared = append(ct.prepared, shardName)
+	}
+
+	return nil
+}
+
+// Phase 2. Coordinator is committed first (i.e., the commit decision).
+func (ct *ClusterTransaction) commitAll() error {
+	var msg string
+
+	err := ct.finishPrepared(ct.Coordinator, "COMMIT PREPARED")
+
+	if err != nil {
+		ct.rollbackAll()
+		return errors.New(ct.Coordinator + ": " + err.Error())
+	}
+
+	for _, shardName := range ct.ShardNames[1:] {
+		err = ct.finishPrepared(shardName, "COMMIT PREPARED")
+
+		// Decision is made. RecoverClusterTransactions will commit anything left behind.
+		if err != nil {
+			msg += shardName + ": " + err.Error() + "\n"
+		}
+	}
+
+	if msg != "" {
+		return errors.New(msg)
+	}
+
+	return nil
+}
+
+// Roll back open and prepared transactions
+func (ct *ClusterTransaction) rollbackAll() {
+
+	for _, curCtx := range ct.ctxs {
+		if curCtx.Tx != nil {
+			curCtx.Rollback()
+		}
+	}
+
+	for _, shardName := range ct.prepared {
+		ct.finishPrepared(shardName, "ROLLBACK PREPARED")
+	}
+
+	ct.prepared = make([]string, 0)
+}
+
+//
+func (ct *ClusterTransaction) finishPrepared(shardName string, command string) error {
+	dbSess, err := ct.Db.GetSessionByName(shardName)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = dbSess.Exec(command + " " + pq.QuoteLiteral(ct.GetGid(shardName)))
+
+	return err
+}
+
+// jgoweb:<coordinator shard>:<coordinator txid>:<shard>
+func (ct *ClusterTransaction) GetGid(shardName string) string {
+	return strings.Join([]string{ClusterTransactionGidPrefix, ct.Coordinator, ct.CoordXid, shardName}, ":")
+}
+
+// ******
+
+// Prepared transaction info
+type ClusterPreparedTransaction struct {
+	Gid         string `db:"gid"`
+	Coordinator string `db:"-"`
+	CoordXid    string `db:"-"`
+	ShardName   string `db:"-"`
+}
+
+// Parse the gid
+func (cpt *ClusterPreparedTransaction) ParseGid() bool {
+	parts := strings.SplitN(cpt.Gid, ":", 4)
+
+	if len(parts) != 4 || parts[0] != ClusterTransactionGidPrefix {
+		return false
+	}
+
+	cpt.Coordinator = parts[1]
+	cpt.CoordXid = parts[2]
+	cpt.ShardName = parts[3]
+
+	return true
+}
+
+// Resolve leftover cluster transactions at startup
+func InitClusterTransactionRecovery() {
+	err := RecoverClusterTransactions(GetDbCollection(), time.Minute)
+
+	if err != nil {
+		log.Printf("ERROR: %s %s", util.WhereAmI(), err)
+	}
+}
+
+// Resolve prepared transactions left behind by ClusterTransaction (e.g., after a crash). Only transactions
+// prepared more than minAge ago are touched so in flight cluster transactions aren't disturbed.
+// Coordinator transactions that are still prepared never reached a commit decision and are rolled back.
+// Participants follow the coordinator's outcome.
+func RecoverClusterTransactions(db *jgoWebDb.Collection, minAge time.Duration) error {
+	var msg string
+	pending := make(map[string][]ClusterPreparedTransaction)
+
+	for shardName := range db.GetConns() {
+		xacts, err := GetClusterPreparedTransactions(db, shardName, minAge)
+
+		if err != nil {
+			msg += shardName + ": " + err.Error() + "\n"
+			continue
+		}
+
+		pending[shardName] = xacts
+	}
+
+	// Coordinators first. No decision was made, so presume abort.
+	for shardName, xacts := range pending {
+		for _, xact := range xacts {
+			if xact.ShardName != xact.Coordinator {
+				continue
+			}
+
+			err := resolvePreparedTransaction(db, shardName, xact.Gid, "ROLLBACK PREPARED")
+
+			if err != nil {
+				msg += shardName + ": " + err.Error() + "\n"
+			}
+		}
+	}
+
+	for shardName, xacts := range pending {
+		for _, xact := range xacts {
+			if xact.ShardName == xact.Coordinator {
+				continue
+			}
+
+			status, err := GetCoordinatorStatus(db, xact.Coordinator, xact.CoordXid)
+
+			if err != nil {
+				msg += shardName + ": " + err.Error() + "\n"
+				continue
+			}
+
+			switch status {
+			case "committed":
+				err = resolvePreparedTransaction(db, shardName, xact.Gid, "COMMIT PREPARED")
+			case "aborted":
+				err = resolvePreparedTransaction(db, shardName, xact.Gid, "ROLLBACK PREPARED")
+			default:
+				// Still in progress (or unknown). Leave it for the next run.
+				continue
+			}
+
+			if err != nil {
+				msg += shardName + ": " + err.Error() + "\n"
+			}
+		}
+	}
+
+	if msg != "" {
+		return errors.New(msg)
+	}
+
+	return nil
+}
+
+// ClusterTransaction prepared transactions in the shard's database
+func GetClusterPreparedTransactions(db *jgoWebDb.Collection, shardName string, minAge time.Duration) ([]ClusterPreparedTransaction, error) {
+	var xacts []ClusterPreparedTransaction
+	results := make([]ClusterPreparedTransaction, 0)
+
+	dbSess, err := db.GetSessionByName(shardName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	stmt := dbSess.Select("gid").
+		From("pg_catalog.pg_prepared_xacts").
+		Where("database = current_database()").
+		Where("gid LIKE ?", ClusterTransactionGidPrefix+":%").
+		Where("prepared < now() - ?::interval", fmt.Sprintf("%d milliseconds", minAge.Nanoseconds()/int64(time.Millisecond))).
+		OrderBy("prepared")
+
+	_, err = stmt.Load(&xacts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, xact := range xacts {
+		if xact.ParseGid() {
+			results = append(results, xact)
+		}
+	}
+
+	return results, nil
+}
+
+// committed, aborted, in progress or "" (unknown)
+func GetCoordinatorStatus(db *jgoWebDb.Collection, coordinator string, coordXid string) (string, error) {
+	var status []string
+
+	dbSess, err := db.GetSessionByName(coordinator)
+
+	if err != nil {
+		return "", err
+	}
+
+	_, err = dbSess.SelectBySql("SELECT COALESCE(txid_status(?::bigint), '')", coordXid).Load(&status)
+
+	if err != nil {
+		return "", err
+	}
+
+	if len(status) == 0 {
+		return "", nil
+	}
+
+	return status[0], nil
+}
+
+//
+func resolvePreparedTransaction(db *jgoWebDb.Collection, shardName string, gid string, command string) error {
+	dbSess, err := db.GetSessionByName(shardName)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = dbSess.Exec(command + " " + pq.QuoteLiteral(gid))
+
+	return err
+}
@@ -0,0 +1,43 @@
+// +build integration
+
+package jgoweb
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+//
+func TestClusterTransactionRun(t *testing.T) {
+	InitMockCtx()
+
+	err := NewClusterTransaction(MockCtx.GetDb()).Run(func(shardName string, ctx ContextInterface) error {
+		_, err := GetAllShards(ctx)
+
+		return err
+	})
+
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+	}
+
+	err = NewClusterTransaction(MockCtx.GetDb()).Run(func(shardName string, ctx ContextInterface) error {
+		return errors.New("test")
+	})
+
+	if err == nil {
+		t.Errorf("ERROR: Failed cluster transaction should return an error.")
+	}
+}
+
+//
+func TestRecoverClusterTransactions(t *testing.T) {
+	InitMockCtx()
+
+	err := RecoverClusterTransactions(MockCtx.GetDb(), time.Minute)
+
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+	}
+}
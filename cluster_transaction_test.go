@@ -0,0 +1,28 @@
+// +build unit
+
+package jgoweb
+
+import (
+	"testing"
+)
+
+//
+func TestClusterPreparedTransactionParseGid(t *testing.T) {
+	ct := &ClusterTransaction{Coordinator: "uxt_0000", CoordXid: "1234"}
+	xact := ClusterPreparedTransaction{Gid: ct.GetGid("uxt:0001")}
+
+	if !xact.ParseGid() {
+		t.Errorf("ERROR: Failed to parse gid: %s", xact.Gid)
+		return
+	}
+
+	if xact.Coordinator != "uxt_0000" || xact.CoordXid != "1234" || xact.ShardName != "uxt:0001" {
+		t.Errorf("ERROR: Unexpected parse result: %+v", xact)
+	}
+
+	xact = ClusterPreparedTransaction{Gid: "other:uxt_0000:1234:uxt_0001"}
+
+	if xact.ParseGid() {
+		t.Errorf("ERROR: Gid without the jgoweb prefix should not parse.")
+	}
+}
@@ -0,0 +1,200 @@
+// Generate models, tests, controllers and views from DB tables.
+//
+//	jgoweb-gen [-config path] [-force] [-dry-run] [-only kinds] <schema.table | schema>
+//
+// Files go in the config's generator dirs. Files that were edited by hand are skipped unless -force is set.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/jschneider98/jgoweb"
+	"github.com/jschneider98/jgoweb/config"
+	"github.com/jschneider98/jgoweb/db/psql"
+	"github.com/jschneider98/jgoweb/generator"
+	"io"
+	"os"
+	"strings"
+)
+
+// Output and options
+type Gen struct {
+	Out        io.Writer
+	Force      bool
+	DryRun     bool
+	Kinds      []string
+	TrimSuffix string
+	Opts       config.GeneratorOptions
+}
+
+//
+func main() {
+	gen := &Gen{Out: os.Stdout}
+
+	err := gen.Run(os.Args[1:])
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+//
+func (gen *Gen) Run(args []string) error {
+	fs := flag.NewFlagSet("jgoweb-gen", flag.ContinueOnError)
+	fs.SetOutput(gen.Out)
+
+	configPath := fs.String("config", jgoweb.GetConfigPath(), "Config file (ignored when "+jgoweb.GetConfigEnvVar()+" is set)")
+	only := fs.String("only", "", "Comma separated kinds: "+strings.Join(generator.GeneratedFileKinds, ", ")+" (default: all)")
+	trimSuffix := fs.String("trim-suffix", "", "Suffix trimmed from table names (default: generator.trimSuffix in the config)")
+	fs.BoolVar(&gen.Force, "force", false, "Overwrite files that were edited by hand")
+	fs.BoolVar(&gen.DryRun, "dry-run", false, "Print diffs instead of writing files")
+
+	err := fs.Parse(args)
+
+	if err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("Expected one schema.table or schema")
+	}
+
+	for _, kind := range strings.Split(*only, ",") {
+		if kind = strings.TrimSpace(kind); kind != "" {
+			gen.Kinds = append(gen.Kinds, kind)
+		}
+	}
+
+	jgoweb.SetConfigPath(*configPath)
+
+	ctx, err := gen.Context()
+
+	if err != nil {
+		return err
+	}
+
+	gen.Opts = jgoweb.GetAppConfig().Generator
+	gen.TrimSuffix = gen.Opts.TrimSuffix
+
+	if *trimSuffix != "" {
+		gen.TrimSuffix = *trimSuffix
+	}
+
+	schema, tables, err := GetTables(ctx, fs.Arg(0))
+
+	if err != nil {
+		return err
+	}
+
+	var failed []string
+
+	for _, table := range tables {
+		err = gen.Generate(ctx, schema, table)
+
+		if err != nil {
+			fmt.Fprintf(gen.Out, "ERROR: %v\n", err)
+			failed = append(failed, schema+"."+table)
+		}
+	}
+
+	if len(failed) > 0 {
+		return errors.New(fmt.Sprintf("Failed: %s", strings.Join(failed, ", ")))
+	}
+
+	return nil
+}
+
+// Context with a session on a random shard.
+// The jgoweb init functions panic on config/DB errors, so those are returned as errors.
+func (gen *Gen) Context() (ctx *jgoweb.WebContext, err error) {
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.New(fmt.Sprint(r))
+		}
+	}()
+
+	ctx = jgoweb.NewContext(jgoweb.GetDbCollection())
+	ctx.InitDbSession()
+
+	return ctx, nil
+}
+
+// "schema.table" = one table, "schema" = every table in the schema
+func GetTables(ctx jgoweb.ContextInterface, target string) (string, []string, error) {
+	parts := strings.SplitN(target, ".", 2)
+
+	if len(parts) == 2 {
+		return parts[0], []string{parts[1]}, nil
+	}
+
+	tables, err := psql.GetTableNames(ctx, target)
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	if len(tables) == 0 {
+		return "", nil, errors.New(fmt.Sprintf("No tables in schema %s", target))
+	}
+
+	return target, tables, nil
+}
+
+// Write (or diff) one table's files. Edited files are reported and skipped without -force.
+func (gen *Gen) Generate(ctx jgoweb.ContextInterface, schema string, table string) error {
+	mg, err := generator.NewModelGenerator(ctx, schema, table, gen.TrimSuffix)
+
+	if err != nil {
+		return err
+	}
+
+	if len(mg.Fields) == 0 {
+		return errors.New(fmt.Sprintf("Table %s.%s does not exist", schema, table))
+	}
+
+	mg.ModelsPackage = gen.Opts.ModelsPackage
+
+	files, err := mg.GetFiles(gen.Opts, gen.Kinds...)
+
+	if err != nil {
+		return err
+	}
+
+	var skipped []string
+
+	for _, file := range files {
+		if gen.DryRun {
+			diff, err := file.Diff()
+
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprint(gen.Out, diff)
+			continue
+		}
+
+		status, err := file.Write(gen.Force)
+
+		if status == generator.GeneratedFileEdited && !gen.Force {
+			skipped = append(skipped, file.Path)
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(gen.Out, "%s: %s\n", file.Path, status)
+	}
+
+	if len(skipped) > 0 {
+		return errors.New(fmt.Sprintf("Edited by hand (use -force to overwrite): %s", strings.Join(skipped, ", ")))
+	}
+
+	return nil
+}
@@ -0,0 +1,36 @@
+// +build unit
+
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+//
+func TestGenRun(t *testing.T) {
+	var out bytes.Buffer
+	gen := &Gen{Out: &out}
+
+	// arg errors come before connecting
+	err := gen.Run([]string{"-dry-run"})
+
+	if err == nil || err.Error() != "Expected one schema.table or schema" {
+		t.Errorf("\nERROR: Expected missing target error. Got: %v\n", err)
+	}
+
+	err = gen.Run([]string{"-bogus", "public.widgets"})
+
+	if err == nil {
+		t.Errorf("\nERROR: Expected flag error\n")
+	}
+}
+
+//
+func TestGetTables(t *testing.T) {
+	schema, tables, err := GetTables(nil, "public.widgets")
+
+	if err != nil || schema != "public" || len(tables) != 1 || tables[0] != "widgets" {
+		t.Errorf("\nERROR: Unexpected result: %s %v %v\n", schema, tables, err)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"github.com/jschneider98/jgoweb"
+)
+
+// Without -shard the shard placement strategy picks the shard
+func accountsCreate(cli *Cli, args []string) error {
+	fs := cli.NewFlagSet("accounts create")
+	domain := fs.String("domain", "", "Account domain")
+	shardName := fs.String("shard", "", "Shard name (optional)")
+
+	err := fs.Parse(args)
+
+	if err == nil {
+		err = RequireFlags(fs, "domain")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	ctx, err := cli.Context()
+
+	if err != nil {
+		return err
+	}
+
+	err = jgoweb.CreateAccount(ctx, *shardName, *domain)
+
+	if err != nil {
+		return err
+	}
+
+	return cli.Message("Account %s created", *domain)
+}
+
+//
+func accountsMove(cli *Cli, args []string) error {
+	fs := cli.NewFlagSet("accounts move")
+	accountId := fs.String("account", "", "Account id")
+	shardName := fs.String("shard", "", "Target shard name")
+
+	err := fs.Parse(args)
+
+	if err == nil {
+		err = RequireFlags(fs, "account", "shard")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	ctx, err := cli.Context()
+
+	if err != nil {
+		return err
+	}
+
+	err = jgoweb.MoveAccount(ctx, *accountId, *shardName)
+
+	if err != nil {
+		return err
+	}
+
+	return cli.Message("Account %s moved to %s", *accountId, *shardName)
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jschneider98/jgoweb"
+)
+
+//
+type JobRow struct {
+	Id          string `json:"id"`
+	ShardName   string `json:"shardName"`
+	AccountId   string `json:"accountId"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	State       string `json:"state"`
+	Status      string `json:"status"`
+	QueuedAt    string `json:"queuedAt"`
+	StartedAt   string `json:"startedAt"`
+	EndedAt     string `json:"endedAt"`
+	Error       string `json:"error"`
+}
+
+//
+func NewJobRow(shardName string, job *jgoweb.QueueJob) JobRow {
+	return JobRow{
+		Id:          job.GetId(),
+		ShardName:   shardName,
+		AccountId:   job.GetAccountId(),
+		Name:        job.GetName(),
+		Description: job.GetDescription(),
+		State:       job.GetState(),
+		Status:      job.GetStatus(),
+		QueuedAt:    job.GetQueuedAt(),
+		StartedAt:   job.GetStartedAt(),
+		EndedAt:     job.GetEndedAt(),
+		Error:       job.GetError(),
+	}
+}
+
+// Newest first across the selected shards
+func jobsList(cli *Cli, args []string) error {
+	fs := cli.NewFlagSet("jobs list")
+	state := fs.String("state", "", "queued, running, failed or done (default: all)")
+	shardNames := fs.String("shard", "", "Shards (comma separated, default: all)")
+	limit := fs.Uint64("limit", 50, "Max jobs")
+
+	err := fs.Parse(args)
+
+	if err != nil {
+		return err
+	}
+
+	ctx, err := cli.Context()
+
+	if err != nil {
+		return err
+	}
+
+	ce := jgoweb.NewClusterExecutor(ctx.GetDb()).SetShardNames(SplitList(*shardNames)...)
+
+	results := ce.Run(context.Background(), func(goCtx context.Context, shardCtx jgoweb.ContextInterface) (interface{}, error) {
+		jobs, err := jgoweb.GetQueueJobsByState(shardCtx, *state, *limit)
+
+		if err != nil {
+			return nil, err
+		}
+
+		shardName, _ := shardCtx.GetDb().GetConnName(shardCtx.GetDbSession().Connection)
+		rows := make([]JobRow, 0)
+
+		for key := range jobs {
+			rows = append(rows, NewJobRow(shardName, &jobs[key]))
+		}
+
+		return rows, nil
+	})
+
+	err = results.Err()
+
+	if err != nil {
+		return err
+	}
+
+	data := make([]JobRow, 0)
+
+	err = results.MergeSorted(&data, func(a interface{}, b interface{}) bool {
+		return a.(JobRow).QueuedAt > b.(JobRow).QueuedAt
+	}, int(*limit))
+
+	if err != nil {
+		return err
+	}
+
+	var rows [][]string
+
+	for _, row := range data {
+		rows = append(rows, []string{row.Id, row.ShardName, row.Name, row.State, row.QueuedAt, row.Error})
+	}
+
+	return cli.Print(data, []string{"ID", "SHARD", "NAME", "STATE", "QUEUED AT", "ERROR"}, rows)
+}
+
+//
+func jobsShow(cli *Cli, args []string) error {
+	job, shardName, err := fetchJob(cli, "jobs show", args)
+
+	if err != nil {
+		return err
+	}
+
+	row := NewJobRow(shardName, job)
+	rows := [][]string{
+		{"Id", row.Id},
+		{"Shard", row.ShardName},
+		{"Account", row.AccountId},
+		{"Name", row.Name},
+		{"Description", row.Description},
+		{"State", row.State},
+		{"Status", row.Status},
+		{"Queued At", row.QueuedAt},
+		{"Started At", row.StartedAt},
+		{"Ended At", row.EndedAt},
+		{"Error", row.Error},
+		{"Data", job.GetData()},
+	}
+
+	return cli.Print(row, nil, rows)
+}
+
+// Running jobs can't be retried (they'd run twice)
+func jobsRetry(cli *Cli, args []string) error {
+	job, _, err := fetchJob(cli, "jobs retry", args)
+
+	if err != nil {
+		return err
+	}
+
+	if job.GetState() == jgoweb.QueueJobRunning {
+		return errors.New(fmt.Sprintf("Job %s is running", job.GetId()))
+	}
+
+	err = job.Retry()
+
+	if err != nil {
+		return err
+	}
+
+	return cli.Message("Job %s queued", job.GetId())
+}
+
+// Job (and its shard) by -id
+func fetchJob(cli *Cli, cmd string, args []string) (*jgoweb.QueueJob, string, error) {
+	fs := cli.NewFlagSet(cmd)
+	id := fs.String("id", "", "Job id")
+
+	err := fs.Parse(args)
+
+	if err == nil {
+		err = RequireFlags(fs, "id")
+	}
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	ctx, err := cli.Context()
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	job, err := jgoweb.ClusterFetchQueueJobById(ctx, *id)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	if job == nil {
+		return nil, "", errors.New(fmt.Sprintf("Job %s does not exist", *id))
+	}
+
+	shardName, _ := ctx.GetDb().GetConnName(job.Ctx.GetDbSession().Connection)
+
+	return job, shardName, nil
+}
@@ -0,0 +1,215 @@
+// Cluster administration (shards, accounts, DB updates, jobs and users).
+//
+//	jgoweb [-config path] [-json] <command> <subcommand> [flags]
+//
+// The config is read from JGO_CONFIG, or the config file (default: ./config/config.json).
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/jschneider98/jgoweb"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+//
+type Command struct {
+	Usage string
+	Run   func(cli *Cli, args []string) error
+}
+
+// Commands by "command subcommand"
+var Commands = map[string]Command{
+	"shards list":       {"List shards", shardsList},
+	"shards add":        {"Add a shard on every DB (-name, -dsn)", shardsAdd},
+	"shards delete":     {"Soft delete a shard on every DB (-name)", shardsDelete},
+	"shards undelete":   {"Undelete a shard on every DB (-name)", shardsUndelete},
+	"accounts create":   {"Create an account (-domain, -shard)", accountsCreate},
+	"accounts move":     {"Move an account to another shard (-account, -shard)", accountsMove},
+	"migrations run":    {"Apply DB updates (-dir, -dry-run, -parallel, -include, -exclude, -canary, ...)", migrationsRun},
+	"migrations status": {"Show DB update status per shard (-dir, -include, -exclude)", migrationsStatus},
+	"migrations plan":   {"Show what migrations run would execute (-dir, -include, -exclude)", migrationsPlan},
+	"jobs list":         {"List jobs (-state, -shard, -limit)", jobsList},
+	"jobs show":         {"Show a job (-id)", jobsShow},
+	"jobs retry":        {"Queue a job again (-id)", jobsRetry},
+	"users create":      {"Create a user (-account, -email, -first, -last, -role, -password)", usersCreate},
+}
+
+// Output and the (lazily connected) cluster context
+type Cli struct {
+	Out  io.Writer
+	Json bool
+	ctx  *jgoweb.WebContext
+}
+
+//
+func main() {
+	cli := &Cli{Out: os.Stdout}
+
+	err := cli.Run(os.Args[1:])
+
+	if err != nil {
+		if cli.Json {
+			fmt.Fprintf(os.Stderr, "{\"error\": %s}\n", strconv.Quote(err.Error()))
+		} else {
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		}
+
+		os.Exit(1)
+	}
+}
+
+// Parse the global flags and run the command
+func (cli *Cli) Run(args []string) error {
+	fs := flag.NewFlagSet("jgoweb", flag.ContinueOnError)
+	fs.SetOutput(cli.Out)
+	fs.Usage = func() { cli.Usage() }
+
+	configPath := fs.String("config", jgoweb.GetConfigPath(), "Config file (ignored when "+jgoweb.GetConfigEnvVar()+" is set)")
+	fs.BoolVar(&cli.Json, "json", false, "JSON output")
+
+	err := fs.Parse(args)
+
+	if err != nil {
+		return err
+	}
+
+	args = fs.Args()
+
+	if len(args) < 2 {
+		cli.Usage()
+		return errors.New("Missing command")
+	}
+
+	cmd, ok := Commands[args[0]+" "+args[1]]
+
+	if !ok {
+		cli.Usage()
+		return errors.New(fmt.Sprintf("Unknown command: %s %s", args[0], args[1]))
+	}
+
+	jgoweb.SetConfigPath(*configPath)
+
+	return cmd.Run(cli, args[2:])
+}
+
+//
+func (cli *Cli) Usage() {
+	var names []string
+
+	for name := range Commands {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	fmt.Fprintln(cli.Out, "Usage: jgoweb [-config path] [-json] <command> <subcommand> [flags]\n\nCommands:")
+	w := tabwriter.NewWriter(cli.Out, 0, 4, 2, ' ', 0)
+
+	for _, name := range names {
+		fmt.Fprintf(w, "  %s\t%s\n", name, Commands[name].Usage)
+	}
+
+	w.Flush()
+}
+
+// Context with a session on a random shard (system tables are on every shard).
+// The jgoweb init functions panic on config/DB errors, so those are returned as errors.
+func (cli *Cli) Context() (ctx *jgoweb.WebContext, err error) {
+
+	if cli.ctx != nil {
+		return cli.ctx, nil
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.New(fmt.Sprint(r))
+		}
+	}()
+
+	ctx = jgoweb.NewContext(jgoweb.GetDbCollection())
+	ctx.InitDbSession()
+	cli.ctx = ctx
+
+	return ctx, nil
+}
+
+// Print data as JSON, or header and rows as a table
+func (cli *Cli) Print(data interface{}, header []string, rows [][]string) error {
+
+	if cli.Json {
+		out, err := json.MarshalIndent(data, "", "\t")
+
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintln(cli.Out, string(out))
+
+		return nil
+	}
+
+	w := tabwriter.NewWriter(cli.Out, 0, 4, 2, ' ', 0)
+
+	if len(header) > 0 {
+		fmt.Fprintln(w, strings.Join(header, "\t"))
+	}
+
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+
+	return w.Flush()
+}
+
+// Print a result message ({"message": "..."} in JSON mode)
+func (cli *Cli) Message(format string, a ...interface{}) error {
+	msg := fmt.Sprintf(format, a...)
+
+	return cli.Print(map[string]string{"message": msg}, nil, [][]string{{msg}})
+}
+
+// Flags for a subcommand. Errors are returned (not printed and exited on).
+func (cli *Cli) NewFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(cli.Out)
+
+	return fs
+}
+
+// Error for missing required flags
+func RequireFlags(fs *flag.FlagSet, names ...string) error {
+	var missing []string
+
+	for _, name := range names {
+		if f := fs.Lookup(name); f == nil || f.Value.String() == "" {
+			missing = append(missing, "-"+name)
+		}
+	}
+
+	if len(missing) > 0 {
+		return errors.New(fmt.Sprintf("%s: missing %s", fs.Name(), strings.Join(missing, ", ")))
+	}
+
+	return nil
+}
+
+// Comma separated flag value (i.e., "shard_1,shard_*")
+func SplitList(val string) []string {
+	var list []string
+
+	for _, item := range strings.Split(val, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+
+	return list
+}
@@ -0,0 +1,75 @@
+// +build unit
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+//
+func TestCliRun(t *testing.T) {
+	var out bytes.Buffer
+	cli := &Cli{Out: &out}
+
+	err := cli.Run([]string{"shards"})
+
+	if err == nil || !strings.Contains(out.String(), "migrations status") {
+		t.Errorf("\nERROR: Expected usage. Got: %v\n%s\n", err, out.String())
+	}
+
+	err = cli.Run([]string{"-json", "shards", "bogus"})
+
+	if err == nil || err.Error() != "Unknown command: shards bogus" || !cli.Json {
+		t.Errorf("\nERROR: Expected unknown command. Got: %v\n", err)
+	}
+
+	// flag errors come before connecting
+	err = cli.Run([]string{"shards", "add"})
+
+	if err == nil || err.Error() != "shards add: missing -name" {
+		t.Errorf("\nERROR: Expected missing flag. Got: %v\n", err)
+	}
+}
+
+//
+func TestCliPrint(t *testing.T) {
+	var out bytes.Buffer
+	cli := &Cli{Out: &out}
+	data := []UserRow{{"1", "2", "a@example.com"}}
+
+	cli.Print(data, []string{"ID", "ACCOUNT", "EMAIL"}, [][]string{{"1", "2", "a@example.com"}})
+
+	if out.String() != "ID  ACCOUNT  EMAIL\n1   2        a@example.com\n" {
+		t.Errorf("\nERROR: Unexpected table:\n%s\n", out.String())
+	}
+
+	out.Reset()
+	cli.Json = true
+	cli.Print(data, nil, nil)
+
+	if !strings.Contains(out.String(), `"email": "a@example.com"`) {
+		t.Errorf("\nERROR: Unexpected JSON:\n%s\n", out.String())
+	}
+
+	out.Reset()
+	cli.Message("Shard %s added", "shard_1")
+
+	if !strings.Contains(out.String(), `"message": "Shard shard_1 added"`) {
+		t.Errorf("\nERROR: Unexpected message:\n%s\n", out.String())
+	}
+}
+
+//
+func TestSplitList(t *testing.T) {
+	result := strings.Join(SplitList(" shard_1, shard_*,,"), "|")
+
+	if result != "shard_1|shard_*" {
+		t.Errorf("\nERROR: Unexpected list: %s\n", result)
+	}
+
+	if len(SplitList("")) != 0 {
+		t.Errorf("\nERROR: Expected empty list\n")
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"flag"
+	"github.com/jschneider98/jgoweb"
+	"strings"
+)
+
+// Flags shared by the migrations subcommands
+type updaterFlags struct {
+	Dir     *string
+	Include *string
+	Exclude *string
+}
+
+//
+func addUpdaterFlags(fs *flag.FlagSet) *updaterFlags {
+	uf := &updaterFlags{}
+	uf.Dir = fs.String("dir", "", "Directory of versioned .sql updates (optional)")
+	uf.Include = fs.String("include", "", "Shards to include (comma separated patterns, i.e., shard_*)")
+	uf.Exclude = fs.String("exclude", "", "Shards to exclude (comma separated patterns)")
+
+	return uf
+}
+
+// jgoweb's own updates, plus the SQL updates in -dir
+func (uf *updaterFlags) NewUpdater(cli *Cli, dryRun bool) (*jgoweb.SystemDbUpdater, error) {
+	ctx, err := cli.Context()
+
+	if err != nil {
+		return nil, err
+	}
+
+	updates := jgoweb.GetJgowebDbUpdates()
+
+	if *uf.Dir != "" {
+		sqlUpdates, err := jgoweb.LoadSqlDbUpdatesFromDir(*uf.Dir)
+
+		if err != nil {
+			return nil, err
+		}
+
+		updates, err = jgoweb.MergeSystemDbUpdates(updates, sqlUpdates)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sdu := jgoweb.NewSystemDbUpdater(ctx.GetDb(), updates, dryRun)
+	sdu.IncludeShards = SplitList(*uf.Include)
+	sdu.ExcludeShards = SplitList(*uf.Exclude)
+
+	return sdu, nil
+}
+
+//
+func migrationsRun(cli *Cli, args []string) error {
+	fs := cli.NewFlagSet("migrations run")
+	uf := addUpdaterFlags(fs)
+	dryRun := fs.Bool("dry-run", false, "Run the updates and roll them back")
+	parallel := fs.Int("parallel", 0, "Max shards updated at once (0 = all)")
+	canary := fs.Bool("canary", false, "Update and verify one shard before the rest")
+	canaryShard := fs.String("canary-shard", "", "Canary shard (default: the first selected shard)")
+	continueOnError := fs.Bool("continue-on-error", false, "Keep updating other shards after a shard fails")
+	version := fs.String("version", "", "Release recorded with each applied update")
+
+	err := fs.Parse(args)
+
+	if err != nil {
+		return err
+	}
+
+	sdu, err := uf.NewUpdater(cli, *dryRun)
+
+	if err != nil {
+		return err
+	}
+
+	sdu.MaxParallelShards = *parallel
+	sdu.Canary = *canary
+	sdu.CanaryShard = *canaryShard
+	sdu.ContinueOnError = *continueOnError
+	sdu.Version = *version
+
+	summary, err := sdu.RunCluster()
+
+	if err != nil {
+		return err
+	}
+
+	var rows [][]string
+
+	for _, result := range summary {
+		rows = append(rows, []string{result.ShardName, result.Status, strings.Join(result.Applied, ", "), result.Error})
+	}
+
+	err = cli.Print(summary, []string{"SHARD", "STATUS", "APPLIED", "ERROR"}, rows)
+
+	if err != nil {
+		return err
+	}
+
+	return summary.Err()
+}
+
+//
+func migrationsStatus(cli *Cli, args []string) error {
+	fs := cli.NewFlagSet("migrations status")
+	uf := addUpdaterFlags(fs)
+
+	err := fs.Parse(args)
+
+	if err != nil {
+		return err
+	}
+
+	sdu, err := uf.NewUpdater(cli, true)
+
+	if err != nil {
+		return err
+	}
+
+	report, err := sdu.Status()
+
+	if err != nil {
+		return err
+	}
+
+	if cli.Json {
+		return cli.Print(report, nil, nil)
+	}
+
+	_, err = cli.Out.Write([]byte(report.Table()))
+
+	return err
+}
+
+//
+func migrationsPlan(cli *Cli, args []string) error {
+	fs := cli.NewFlagSet("migrations plan")
+	uf := addUpdaterFlags(fs)
+
+	err := fs.Parse(args)
+
+	if err != nil {
+		return err
+	}
+
+	sdu, err := uf.NewUpdater(cli, true)
+
+	if err != nil {
+		return err
+	}
+
+	plan, err := sdu.Plan()
+
+	if err != nil {
+		return err
+	}
+
+	if cli.Json {
+		return cli.Print(plan, nil, nil)
+	}
+
+	_, err = cli.Out.Write([]byte(plan.Table()))
+
+	return err
+}
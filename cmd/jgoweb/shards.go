@@ -0,0 +1,122 @@
+package main
+
+import (
+	"github.com/jschneider98/jgoweb"
+)
+
+//
+type ShardRow struct {
+	Id                string `json:"id"`
+	Name              string `json:"name"`
+	AccountCount      string `json:"accountCount"`
+	Weight            string `json:"weight"`
+	CapacityLimit     string `json:"capacityLimit"`
+	AcceptingAccounts string `json:"acceptingAccounts"`
+	DeletedAt         string `json:"deletedAt"`
+}
+
+//
+func shardsList(cli *Cli, args []string) error {
+	fs := cli.NewFlagSet("shards list")
+
+	err := fs.Parse(args)
+
+	if err != nil {
+		return err
+	}
+
+	ctx, err := cli.Context()
+
+	if err != nil {
+		return err
+	}
+
+	shards, err := jgoweb.GetAllShards(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	data := make([]ShardRow, 0)
+	var rows [][]string
+
+	for _, shard := range shards {
+		row := ShardRow{shard.GetId(), shard.GetName(), shard.GetAccountCount(), shard.GetWeight(), shard.GetCapacityLimit(), shard.GetAcceptingAccounts(), shard.GetDeletedAt()}
+
+		data = append(data, row)
+		rows = append(rows, []string{row.Name, row.Id, row.AccountCount, row.Weight, row.CapacityLimit, row.AcceptingAccounts, row.DeletedAt})
+	}
+
+	return cli.Print(data, []string{"NAME", "ID", "ACCOUNTS", "WEIGHT", "CAPACITY", "ACCEPTING", "DELETED AT"}, rows)
+}
+
+// A DSN lets nodes using the "shards" reload source connect without a restart
+func shardsAdd(cli *Cli, args []string) error {
+	fs := cli.NewFlagSet("shards add")
+	name := fs.String("name", "", "Shard name")
+	dsn := fs.String("dsn", "", "Shard DSN (optional)")
+
+	err := fs.Parse(args)
+
+	if err == nil {
+		err = RequireFlags(fs, "name")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	ctx, err := cli.Context()
+
+	if err != nil {
+		return err
+	}
+
+	err = jgoweb.ClusterAddShardWithDsn(ctx, *name, *dsn)
+
+	if err != nil {
+		return err
+	}
+
+	return cli.Message("Shard %s added", *name)
+}
+
+//
+func shardsDelete(cli *Cli, args []string) error {
+	return shardsSetDeleted(cli, "shards delete", args, jgoweb.ClusterDeleteShard, "deleted")
+}
+
+//
+func shardsUndelete(cli *Cli, args []string) error {
+	return shardsSetDeleted(cli, "shards undelete", args, jgoweb.ClusterUndeleteShard, "undeleted")
+}
+
+//
+func shardsSetDeleted(cli *Cli, cmd string, args []string, fn func(ctx jgoweb.ContextInterface, shardName string) error, done string) error {
+	fs := cli.NewFlagSet(cmd)
+	name := fs.String("name", "", "Shard name")
+
+	err := fs.Parse(args)
+
+	if err == nil {
+		err = RequireFlags(fs, "name")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	ctx, err := cli.Context()
+
+	if err != nil {
+		return err
+	}
+
+	err = fn(ctx, *name)
+
+	if err != nil {
+		return err
+	}
+
+	return cli.Message("Shard %s %s", *name, done)
+}
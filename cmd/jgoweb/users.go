@@ -0,0 +1,53 @@
+package main
+
+import (
+	"github.com/jschneider98/jgoweb"
+	"os"
+)
+
+// Env var for the password, so it doesn't end up in shell history
+const UserPasswordEnvVar = "JGO_USER_PASSWORD"
+
+//
+type UserRow struct {
+	Id        string `json:"id"`
+	AccountId string `json:"accountId"`
+	Email     string `json:"email"`
+}
+
+//
+func usersCreate(cli *Cli, args []string) error {
+	fs := cli.NewFlagSet("users create")
+	accountId := fs.String("account", "", "Account id")
+	email := fs.String("email", "", "Email")
+	firstName := fs.String("first", "", "First name")
+	lastName := fs.String("last", "", "Last name")
+	roleId := fs.String("role", "", "Role id")
+	password := fs.String("password", os.Getenv(UserPasswordEnvVar), "Password (default: $"+UserPasswordEnvVar+")")
+
+	err := fs.Parse(args)
+
+	if err == nil {
+		err = RequireFlags(fs, "account", "email", "first", "last", "role", "password")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	ctx, err := cli.Context()
+
+	if err != nil {
+		return err
+	}
+
+	user, err := jgoweb.CreateUser(ctx, *accountId, *email, *firstName, *lastName, *roleId, *password)
+
+	if err != nil {
+		return err
+	}
+
+	row := UserRow{user.GetId(), user.GetAccountId(), user.GetEmail()}
+
+	return cli.Print(row, []string{"ID", "ACCOUNT", "EMAIL"}, [][]string{{row.Id, row.AccountId, row.Email}})
+}
@@ -19,6 +19,12 @@ type Config struct {
 	GoogleOauth2Creds GoogleOauth2Credentials `json:"googleOauth2Credentials"`
 	Integration       IntegrationOptions      `json:"integration"`
 	Autocert          AutocertOptions         `json:"autocert"`
+	ShardRouteCache   ShardRouteCacheOptions  `json:"shardRouteCache"`
+	Tenant            TenantOptions           `json:"tenant"`
+	DbHealth          DbHealthOptions         `json:"dbHealth"`
+	DbReload          DbReloadOptions         `json:"dbReload"`
+	QueryLog          QueryLogOptions         `json:"queryLog"`
+	Generator         GeneratorOptions        `json:"generator"`
 	CustomRaw         []string                `json:"custom"`
 	Custom            url.Values              `json:"-"`
 	AutocertCache     autocert.Cache          `json:"-"`
@@ -41,12 +47,62 @@ type ServerOptions struct {
 
 // DB Connection Strings
 type DbConnOptions struct {
-	ShardName        string `json:"shardName"`
-	Dsn              string `json:"dsn"`
-	MaxOpenConns     int    `json:"maxOpenConns"`
-	MaxIdleConns     int    `json:"maxIdleConns"`
-	ConnMaxLifetime  int    `json:"connMaxLifetime"`
-	StatementTimeout int    `json:"statementTimeout"`
+	ShardName        string   `json:"shardName"`
+	Dsn              string   `json:"dsn"`
+	MaxOpenConns     int      `json:"maxOpenConns"`
+	MaxIdleConns     int      `json:"maxIdleConns"`
+	ConnMaxLifetime  int      `json:"connMaxLifetime"`
+	StatementTimeout int      `json:"statementTimeout"`
+	Replicas         []string `json:"replicas"`
+	MaxReplicaLag    int      `json:"maxReplicaLag"`
+}
+
+// DB health checks. Mode: "degrade" (start with some shards down) or "failfast". Interval in seconds, timeout in ms.
+type DbHealthOptions struct {
+	Mode     string `json:"mode"`
+	Interval int    `json:"interval"`
+	Timeout  int    `json:"timeout"`
+}
+
+// Runtime DB connection reloads. Source: "file" (config file), "shards" (system.shards dsn column) or "" (off).
+// Interval and drainTimeout in seconds.
+type DbReloadOptions struct {
+	Source       string `json:"source"`
+	Interval     int    `json:"interval"`
+	DrainTimeout int    `json:"drainTimeout"`
+}
+
+// Query instrumentation. slowThreshold in ms. explain = EXPLAIN slow selects (dev mode only).
+type QueryLogOptions struct {
+	SlowThreshold int  `json:"slowThreshold"`
+	Explain       bool `json:"explain"`
+}
+
+// Code generator (jgoweb-gen) output. Dirs are relative to the working directory.
+// modelsPackage is the import path of modelDir (used by controllers).
+type GeneratorOptions struct {
+	ModelDir      string `json:"modelDir"`
+	ControllerDir string `json:"controllerDir"`
+	ViewDir       string `json:"viewDir"`
+	ModelsPackage string `json:"modelsPackage"`
+	TrimSuffix    string `json:"trimSuffix"`
+}
+
+// Account to shard routing cache. Invalidations are sent and received on shardName, which has to be the
+// same DB on every node (default: the first DB in dbConns).
+type ShardRouteCacheOptions struct {
+	Enabled   bool   `json:"enabled"`
+	Ttl       int    `json:"ttl"`
+	MaxSize   int    `json:"maxSize"`
+	ShardName string `json:"shardName"`
+}
+
+// Tenant resolution. Resolvers are tried in order: domain, subdomain, token, session
+type TenantOptions struct {
+	Resolvers   []string `json:"resolvers"`
+	BaseDomain  string   `json:"baseDomain"`
+	TokenHeader string   `json:"tokenHeader"`
+	RlsVariable string   `json:"rlsVariable"`
 }
 
 // Google Oauth2 Credentials
@@ -178,6 +234,63 @@ func (c *Config) EnsureBasicOptions() {
 		c.Server.HandlerTimeout = 10
 	}
 
+	if c.ShardRouteCache.Ttl == 0 {
+		c.ShardRouteCache.Ttl = 300
+	}
+
+	if c.ShardRouteCache.MaxSize == 0 {
+		c.ShardRouteCache.MaxSize = 10000
+	}
+
+	// Config file order, not the (runtime reloadable) connection list, so every node agrees
+	if c.ShardRouteCache.ShardName == "" && len(c.DbConns) > 0 {
+		c.ShardRouteCache.ShardName = c.DbConns[0].ShardName
+	}
+
+	if c.DbHealth.Mode == "" {
+		c.DbHealth.Mode = "degrade"
+	}
+
+	if c.DbHealth.Interval == 0 {
+		c.DbHealth.Interval = 10
+	}
+
+	if c.DbHealth.Timeout == 0 {
+		c.DbHealth.Timeout = 2000
+	}
+
+	if c.DbReload.Interval == 0 {
+		c.DbReload.Interval = 30
+	}
+
+	if c.DbReload.DrainTimeout == 0 {
+		c.DbReload.DrainTimeout = 30
+	}
+
+	if c.QueryLog.SlowThreshold == 0 {
+		c.QueryLog.SlowThreshold = 500
+	}
+
+	if c.Generator.ModelDir == "" {
+		c.Generator.ModelDir = "models"
+	}
+
+	if c.Generator.ControllerDir == "" {
+		c.Generator.ControllerDir = "web"
+	}
+
+	if c.Generator.ViewDir == "" {
+		c.Generator.ViewDir = "templates"
+	}
+
+	if len(c.Tenant.Resolvers) == 0 {
+		c.Tenant.Resolvers = []string{"domain", "subdomain", "token", "session"}
+	}
+
+	if c.Tenant.TokenHeader == "" {
+		c.Tenant.TokenHeader = "X-Api-Token"
+	}
+
 	// Default acme URL
 	if c.Autocert.DirectoryURL == "" {
 		c.Autocert.DirectoryURL = "https://acme-v01.api.letsencrypt.org/directory"
@@ -1,12 +1,14 @@
 package jgoweb
 
 import (
+	"context"
 	"database/sql"
 	"github.com/gocraft/dbr"
 	"github.com/gocraft/web"
 	jgoWebDb "github.com/jschneider98/jgoweb/db"
 	"gopkg.in/go-playground/validator.v9"
 	"html/template"
+	"time"
 )
 
 type ContextInterface interface {
@@ -24,6 +26,11 @@ type ContextInterface interface {
 	OptionalRollback(tx *dbr.Tx) error
 	OptionalCommit(tx *dbr.Tx) error
 	FinishTransaction() error
+	WithTransaction(fn func(tx *dbr.Tx) error, opts *TxOptions) error
+	AdvisoryLock(key string) (*AdvisoryLock, error)
+	TryAdvisoryLock(key string, timeout time.Duration) (*AdvisoryLock, error)
+	AdvisoryXactLock(key string) error
+	TryAdvisoryXactLock(key string, timeout time.Duration) (bool, error)
 	DeleteFrom(table string) *dbr.DeleteStmt
 	SetUser(user *User)
 	SessionGetString(key string) (string, error)
@@ -31,6 +38,7 @@ type ContextInterface interface {
 	GetDb() *jgoWebDb.Collection
 	GetDbSession() *dbr.Session
 	SetDbSession(dbSess *dbr.Session)
+	GetGoContext() context.Context
 	GetValidator() *validator.Validate
 	GetTemplate(filename string) (*template.Template, error)
 }
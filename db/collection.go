@@ -9,6 +9,7 @@ import (
 	"math/rand"
 	"os"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -18,67 +19,106 @@ type CollectionInterface interface {
 }
 
 type Collection struct {
-	Config    []config.DbConnOptions
-	ConfigMap map[string]int
-	Conns     map[string]*dbr.Connection
+	replicaNext uint64 // first for 64-bit atomic alignment
+	Config      []config.DbConnOptions
+	ConfigMap   map[string]int
+	Conns       map[string]*dbr.Connection
+	Replicas    map[string][]*Replica
+	health      healthState
+	mu          sync.RWMutex
+	reloadMu    sync.Mutex
 }
 
+// Optional instrumentation for each shard's pools (primary + replicas). nil = no events.
+var NewEventReceiver func(shardName string) dbr.EventReceiver
+
 // Retrieve db obj
 var NewDb = func(dbConns []config.DbConnOptions) (*Collection, error) {
 	conns := make(map[string]*dbr.Connection)
+	replicas := make(map[string][]*Replica)
 	configMap := make(map[string]int)
-	var maxOpenConns int
-	var maxIdleConns int
-	var connMaxLifetime int
 
 	for index, connInfo := range dbConns {
-		var dsn string
-		dsnParts := strings.Split(connInfo.Dsn, ":")
+		conn, shardReplicas, err := openConns(connInfo)
 
-		if len(dsnParts) == 2 && dsnParts[0] == "env" {
-			dsn = os.Getenv(dsnParts[1])
-		} else {
-			dsn = connInfo.Dsn
+		if err != nil {
+			return nil, err
 		}
 
-		conn, err := dbr.Open("postgres", dsn, nil)
+		conns[connInfo.ShardName] = conn
+		replicas[connInfo.ShardName] = shardReplicas
+		configMap[connInfo.ShardName] = index
+	}
 
-		// defaults
-		maxOpenConns = 100
-		maxIdleConns = 25
-		connMaxLifetime = 30
+	db := &Collection{Conns: conns, Replicas: replicas, Config: append([]config.DbConnOptions{}, dbConns...), ConfigMap: configMap}
 
-		if connInfo.MaxOpenConns > 0 {
-			maxOpenConns = connInfo.MaxOpenConns
-		}
+	return db, nil
+}
 
-		if connInfo.MaxIdleConns > 0 {
-			maxIdleConns = connInfo.MaxIdleConns
-		}
+// Open a connection pool using the shard's pool settings
+func OpenConn(dsn string, connInfo config.DbConnOptions) (*dbr.Connection, error) {
+	// defaults
+	maxOpenConns := 100
+	maxIdleConns := 25
+	connMaxLifetime := 30
 
-		if connInfo.ConnMaxLifetime > 0 {
-			connMaxLifetime = connInfo.ConnMaxLifetime
-		}
+	var receiver dbr.EventReceiver
 
-		conn.SetMaxOpenConns(maxOpenConns)
-		conn.SetMaxIdleConns(maxIdleConns)
-		conn.SetConnMaxLifetime(time.Duration(connMaxLifetime) * time.Minute)
+	if NewEventReceiver != nil {
+		receiver = NewEventReceiver(connInfo.ShardName)
+	}
 
-		if err != nil {
-			return nil, err
-		}
+	conn, err := dbr.Open("postgres", ResolveDsn(dsn), receiver)
 
-		conns[connInfo.ShardName] = conn
-		configMap[connInfo.ShardName] = index
+	if err != nil {
+		return nil, err
 	}
 
-	db := &Collection{Conns: conns, Config: dbConns, ConfigMap: configMap}
+	if connInfo.MaxOpenConns > 0 {
+		maxOpenConns = connInfo.MaxOpenConns
+	}
 
-	return db, nil
+	if connInfo.MaxIdleConns > 0 {
+		maxIdleConns = connInfo.MaxIdleConns
+	}
+
+	if connInfo.ConnMaxLifetime > 0 {
+		connMaxLifetime = connInfo.ConnMaxLifetime
+	}
+
+	conn.SetMaxOpenConns(maxOpenConns)
+	conn.SetMaxIdleConns(maxIdleConns)
+	conn.SetConnMaxLifetime(time.Duration(connMaxLifetime) * time.Minute)
+
+	return conn, nil
+}
+
+// DSNs can be read from the environment (i.e., "env:MY_DSN_VAR")
+func ResolveDsn(dsn string) string {
+	dsnParts := strings.Split(dsn, ":")
+
+	if len(dsnParts) == 2 && dsnParts[0] == "env" {
+		return os.Getenv(dsnParts[1])
+	}
+
+	return dsn
+}
+
+// get Db DSN by name
+func (db *Collection) GetDsnByName(name string) (string, error) {
+	config, err := db.GetConfigByName(name)
+
+	if err != nil {
+		return "", err
+	}
+
+	return ResolveDsn(config.Dsn), nil
 }
 
 // get Db connection by name
 func (db *Collection) GetConnByName(name string) (*dbr.Connection, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
 
 	if conn, ok := db.Conns[name]; ok {
 		return conn, nil
@@ -92,6 +132,9 @@ func (db *Collection) GetConnByName(name string) (*dbr.Connection, error) {
 func (db *Collection) GetConfigByName(name string) (config.DbConnOptions, error) {
 	var empty config.DbConnOptions
 
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
 	if index, ok := db.ConfigMap[name]; ok {
 		return db.Config[index], nil
 	}
@@ -100,23 +143,52 @@ func (db *Collection) GetConfigByName(name string) (config.DbConnOptions, error)
 	return empty, err
 }
 
-// get random DB conn
+// get random DB conn (skips connections that failed their last health check)
 func (db *Collection) GetRandomConn() (*dbr.Connection, error) {
 
-	if len(db.Config) == 0 {
+	if len(db.GetNames()) == 0 {
 		err := errors.New("Empty DB Config")
 		return nil, err
 	}
 
+	names := db.GetHealthyNames()
+
+	if len(names) == 0 {
+		err := errors.New("No healthy DB connections")
+		return nil, err
+	}
+
 	rand.Seed(time.Now().UnixNano())
-	connInfo := db.Config[rand.Intn(len(db.Config))]
 
-	return db.GetConnByName(connInfo.ShardName)
+	return db.GetConnByName(names[rand.Intn(len(names))])
 }
 
-//
+// Copy of the primary connections by shard name
 func (db *Collection) GetConns() map[string]*dbr.Connection {
-	return db.Conns
+	conns := make(map[string]*dbr.Connection)
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	for name, conn := range db.Conns {
+		conns[name] = conn
+	}
+
+	return conns
+}
+
+// Shard names in config order
+func (db *Collection) GetNames() []string {
+	var names []string
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	for _, connInfo := range db.Config {
+		names = append(names, connInfo.ShardName)
+	}
+
+	return names
 }
 
 // get Db session by name
@@ -0,0 +1,223 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Health of a single connection pool
+type ConnHealth struct {
+	Name      string
+	Healthy   bool
+	Err       error
+	Latency   time.Duration
+	Failures  int
+	CheckedAt time.Time
+}
+
+// Tracks connection health for a Collection. Connections are healthy until a check says otherwise.
+type healthState struct {
+	conns map[string]ConnHealth
+	stop  chan struct{}
+	mu    sync.RWMutex
+}
+
+// Ping a primary connection
+func (db *Collection) Ping(name string, timeout time.Duration) error {
+	conn, err := db.GetConnByName(name)
+
+	if err != nil {
+		return err
+	}
+
+	goCtx := context.Background()
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		goCtx, cancel = context.WithTimeout(goCtx, timeout)
+		defer cancel()
+	}
+
+	return conn.PingContext(goCtx)
+}
+
+// Ping every primary and replica and record the results
+func (db *Collection) CheckHealth(timeout time.Duration) map[string]ConnHealth {
+	var wg sync.WaitGroup
+
+	for name := range db.GetConns() {
+		wg.Add(1)
+
+		go func(name string) {
+			defer wg.Done()
+
+			start := time.Now()
+			err := db.Ping(name, timeout)
+			db.setHealth(name, err, time.Since(start))
+		}(name)
+	}
+
+	for name := range db.GetConns() {
+		for _, replica := range db.GetReplicasByName(name) {
+			wg.Add(1)
+
+			go func(replica *Replica) {
+				defer wg.Done()
+
+				replica.CheckHealth(timeout)
+			}(replica)
+		}
+	}
+
+	wg.Wait()
+
+	return db.GetHealth()
+}
+
+// Startup connectivity check. failFast = error if any connection is down, otherwise only if all are down.
+func (db *Collection) CheckStartup(failFast bool, timeout time.Duration) error {
+	var msg string
+
+	health := db.CheckHealth(timeout)
+	healthy := 0
+
+	for _, name := range sortedHealthNames(health) {
+		if health[name].Healthy {
+			healthy++
+			continue
+		}
+
+		msg += name + ": " + health[name].Err.Error() + "\n"
+	}
+
+	if msg == "" {
+		return nil
+	}
+
+	if failFast || healthy == 0 {
+		return errors.New(fmt.Sprintf("DB connectivity check failed:\n%s", msg))
+	}
+
+	return nil
+}
+
+// Background health checks. fn (optional) is called with the results of each round.
+func (db *Collection) StartHealthChecks(interval time.Duration, timeout time.Duration, fn func(map[string]ConnHealth)) {
+	db.StopHealthChecks()
+
+	stop := make(chan struct{})
+
+	db.health.mu.Lock()
+	db.health.stop = stop
+	db.health.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				health := db.CheckHealth(timeout)
+
+				if fn != nil {
+					fn(health)
+				}
+			}
+		}
+	}()
+}
+
+//
+func (db *Collection) StopHealthChecks() {
+	db.health.mu.Lock()
+	defer db.health.mu.Unlock()
+
+	if db.health.stop != nil {
+		close(db.health.stop)
+		db.health.stop = nil
+	}
+}
+
+// Unknown connections are assumed healthy
+func (db *Collection) IsHealthy(name string) bool {
+	db.health.mu.RLock()
+	defer db.health.mu.RUnlock()
+
+	health, ok := db.health.conns[name]
+
+	return !ok || health.Healthy
+}
+
+// Copy of the current health status by shard name
+func (db *Collection) GetHealth() map[string]ConnHealth {
+	health := make(map[string]ConnHealth)
+
+	db.health.mu.RLock()
+	defer db.health.mu.RUnlock()
+
+	for name, connHealth := range db.health.conns {
+		health[name] = connHealth
+	}
+
+	return health
+}
+
+// Shard names that aren't known to be down (sorted)
+func (db *Collection) GetHealthyNames() []string {
+	var names []string
+
+	for _, name := range db.GetNames() {
+		if db.IsHealthy(name) {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+//
+func (db *Collection) setHealth(name string, err error, latency time.Duration) {
+	db.health.mu.Lock()
+	defer db.health.mu.Unlock()
+
+	if db.health.conns == nil {
+		db.health.conns = make(map[string]ConnHealth)
+	}
+
+	health := db.health.conns[name]
+	health.Name = name
+	health.Healthy = err == nil
+	health.Err = err
+	health.Latency = latency
+	health.CheckedAt = time.Now()
+
+	if err != nil {
+		health.Failures++
+	} else {
+		health.Failures = 0
+	}
+
+	db.health.conns[name] = health
+}
+
+//
+func sortedHealthNames(health map[string]ConnHealth) []string {
+	var names []string
+
+	for name := range health {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
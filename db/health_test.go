@@ -0,0 +1,116 @@
+// +build unit
+
+package db
+
+import (
+	"github.com/jschneider98/jgoweb/config"
+	"testing"
+	"time"
+)
+
+// Nothing listens on port 1, so pings fail fast
+func getTestHealthDb(t *testing.T) *Collection {
+	dbConns := []config.DbConnOptions{
+		config.DbConnOptions{ShardName: "shard_1", Dsn: "postgres://127.0.0.1:1/test?sslmode=disable&connect_timeout=1"},
+		config.DbConnOptions{ShardName: "shard_2", Dsn: "postgres://127.0.0.1:1/test?sslmode=disable&connect_timeout=1"},
+	}
+
+	db, err := NewDb(dbConns)
+
+	if err != nil {
+		t.Fatalf("\nERROR: %v\n", err)
+	}
+
+	return db
+}
+
+//
+func TestCheckHealth(t *testing.T) {
+	db := getTestHealthDb(t)
+
+	// unchecked connections are assumed healthy
+	if !db.IsHealthy("shard_1") || len(db.GetHealthyNames()) != 2 {
+		t.Errorf("\nERROR: Unchecked connections should be healthy.\n")
+	}
+
+	health := db.CheckHealth(time.Second)
+
+	if len(health) != 2 || health["shard_1"].Healthy || health["shard_1"].Failures != 1 {
+		t.Errorf("\nERROR: Unexpected health: %+v\n", health)
+	}
+
+	_, err := db.GetRandomConn()
+
+	if err == nil {
+		t.Errorf("\nERROR: GetRandomConn should fail with no healthy connections.\n")
+	}
+
+	// simulate recovery of one shard
+	db.setHealth("shard_2", nil, time.Millisecond)
+
+	for i := 0; i < 10; i++ {
+		conn, err := db.GetRandomConn()
+
+		if err != nil {
+			t.Errorf("\nERROR: %v\n", err)
+			return
+		}
+
+		if conn != db.Conns["shard_2"] {
+			t.Errorf("\nERROR: GetRandomConn should skip unhealthy connections.\n")
+			return
+		}
+	}
+}
+
+//
+func TestCheckStartup(t *testing.T) {
+	db := getTestHealthDb(t)
+
+	err := db.CheckStartup(false, time.Second)
+
+	if err == nil {
+		t.Errorf("\nERROR: Degrade mode should fail if every connection is down.\n")
+	}
+
+	err = db.CheckStartup(true, time.Second)
+
+	if err == nil {
+		t.Errorf("\nERROR: Fail fast mode should fail.\n")
+	}
+}
+
+//
+func TestReplicaHealth(t *testing.T) {
+	dbConns := []config.DbConnOptions{
+		config.DbConnOptions{
+			ShardName: "shard_1",
+			Dsn:       "postgres://127.0.0.1:1/test?sslmode=disable",
+			Replicas:  []string{"postgres://127.0.0.1:1/test?sslmode=disable&connect_timeout=1"},
+		},
+	}
+
+	db, err := NewDb(dbConns)
+
+	if err != nil {
+		t.Fatalf("\nERROR: %v\n", err)
+	}
+
+	replica := db.GetReplicasByName("shard_1")[0]
+	replica.CheckHealth(time.Second)
+
+	if replica.IsCurrent() {
+		t.Errorf("\nERROR: Unhealthy replica should not be used.\n")
+	}
+
+	sess, err := db.GetReplicaSessionByName("shard_1")
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+		return
+	}
+
+	if sess.Connection != db.Conns["shard_1"] {
+		t.Errorf("\nERROR: Should fall back to the primary.\n")
+	}
+}
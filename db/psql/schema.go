@@ -0,0 +1,464 @@
+package psql
+
+import (
+	"fmt"
+	"github.com/gocraft/dbr"
+	"github.com/jschneider98/jgoweb"
+	"sort"
+	"strings"
+)
+
+// Structure of one DB (or a subset of its schemas). Keys are schema qualified names.
+// Objects that belong to extensions aren't included.
+type Schema struct {
+	Names     []string
+	Tables    map[string]*Table
+	Sequences map[string]Sequence
+	Functions map[string]Function
+}
+
+//
+type Table struct {
+	SchemaName  string
+	Name        string
+	Columns     []Column
+	Indexes     map[string]Index
+	Constraints map[string]Constraint
+}
+
+// Identity: "a" = GENERATED ALWAYS, "d" = GENERATED BY DEFAULT, "" = not an identity column
+type Column struct {
+	SchemaName string         `db:"schema_name"`
+	TableName  string         `db:"table_name"`
+	Name       string         `db:"column_name"`
+	DataType   string         `db:"data_type"`
+	Default    dbr.NullString `db:"column_default"`
+	NotNull    bool           `db:"not_null"`
+	Identity   string         `db:"identity"`
+}
+
+// Indexes that back a constraint (i.e., primary keys) are part of the constraint
+type Index struct {
+	SchemaName string `db:"schema_name"`
+	TableName  string `db:"table_name"`
+	Name       string `db:"name"`
+	Definition string `db:"definition"`
+}
+
+// Type: p = primary key, u = unique, f = foreign key, c = check, x = exclusion
+type Constraint struct {
+	SchemaName string `db:"schema_name"`
+	TableName  string `db:"table_name"`
+	Name       string `db:"name"`
+	Type       string `db:"type"`
+	Definition string `db:"definition"`
+}
+
+// Sequences owned by identity columns are part of the column. Owner* is set for sequences owned by (but not
+// generated for) a column, i.e., serial columns. Dropping the column drops the sequence.
+type Sequence struct {
+	SchemaName  string `db:"schema_name"`
+	Name        string `db:"name"`
+	DataType    string `db:"data_type"`
+	Start       int64  `db:"start_value"`
+	Increment   int64  `db:"increment_by"`
+	Min         int64  `db:"min_value"`
+	Max         int64  `db:"max_value"`
+	Cycle       bool   `db:"cycle"`
+	OwnerSchema string `db:"owner_schema"`
+	OwnerTable  string `db:"owner_table"`
+	OwnerColumn string `db:"owner_column"`
+}
+
+// Definition is the complete CREATE OR REPLACE statement
+type Function struct {
+	SchemaName string `db:"schema_name"`
+	Name       string `db:"name"`
+	Arguments  string `db:"arguments"`
+	Definition string `db:"definition"`
+}
+
+// Introspect the given schemas (default: every schema except the system ones)
+func GetSchema(ctx jgoweb.ContextInterface, schemas ...string) (*Schema, error) {
+	var err error
+
+	s := &Schema{Tables: make(map[string]*Table), Sequences: make(map[string]Sequence), Functions: make(map[string]Function)}
+
+	s.Names, err = GetSchemaNames(ctx, schemas...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	columns, err := GetColumns(ctx, schemas...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, column := range columns {
+		table := s.getTable(column.SchemaName, column.TableName)
+		table.Columns = append(table.Columns, column)
+	}
+
+	indexes, err := GetIndexes(ctx, schemas...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, index := range indexes {
+		s.getTable(index.SchemaName, index.TableName).Indexes[QuoteIdent(index.SchemaName, index.Name)] = index
+	}
+
+	constraints, err := GetConstraints(ctx, schemas...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, constraint := range constraints {
+		s.getTable(constraint.SchemaName, constraint.TableName).Constraints[constraint.Name] = constraint
+	}
+
+	sequences, err := GetSequences(ctx, schemas...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, sequence := range sequences {
+		s.Sequences[QuoteIdent(sequence.SchemaName, sequence.Name)] = sequence
+	}
+
+	functions, err := GetFunctions(ctx, schemas...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, function := range functions {
+		s.Functions[function.GetSignature()] = function
+	}
+
+	return s, nil
+}
+
+//
+func (s *Schema) getTable(schemaName string, tableName string) *Table {
+	key := QuoteIdent(schemaName, tableName)
+
+	if _, ok := s.Tables[key]; !ok {
+		s.Tables[key] = &Table{SchemaName: schemaName, Name: tableName, Indexes: make(map[string]Index), Constraints: make(map[string]Constraint)}
+	}
+
+	return s.Tables[key]
+}
+
+//
+func GetSchemaNames(ctx jgoweb.ContextInterface, schemas ...string) ([]string, error) {
+	var names []string
+
+	where, values := getSchemaFilter("n.nspname", schemas)
+
+	_, err := ctx.SelectBySql(`
+	SELECT n.nspname
+	FROM pg_catalog.pg_namespace n
+	WHERE `+where+`
+		AND `+getNotExtensionFilter("n.oid")+`
+	ORDER BY n.nspname`, values...).Load(&names)
+
+	return names, err
+}
+
+// Ordinary tables in a schema (partitions are skipped)
+func GetTableNames(ctx jgoweb.ContextInterface, schema string) ([]string, error) {
+	var names []string
+
+	_, err := ctx.SelectBySql(`
+	SELECT c.relname
+	FROM pg_catalog.pg_class c
+	JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+	WHERE c.relkind = 'r'
+		AND NOT c.relispartition
+		AND n.nspname = ?
+		AND `+getNotExtensionFilter("c.oid")+`
+	ORDER BY c.relname`, schema).Load(&names)
+
+	return names, err
+}
+
+// Columns of ordinary tables (partitions are skipped)
+func GetColumns(ctx jgoweb.ContextInterface, schemas ...string) ([]Column, error) {
+	var columns []Column
+
+	where, values := getSchemaFilter("n.nspname", schemas)
+
+	_, err := ctx.SelectBySql(`
+	SELECT n.nspname AS schema_name,
+		c.relname AS table_name,
+		a.attname AS column_name,
+		pg_catalog.format_type(a.atttypid, a.atttypmod) AS data_type,
+		pg_catalog.pg_get_expr(d.adbin, d.adrelid) AS column_default,
+		a.attnotnull AS not_null,
+		a.attidentity::text AS identity
+	FROM pg_catalog.pg_class c
+	JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+	JOIN pg_catalog.pg_attribute a ON a.attrelid = c.oid AND a.attnum > 0 AND NOT a.attisdropped
+	LEFT JOIN pg_catalog.pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+	WHERE c.relkind = 'r'
+		AND NOT c.relispartition
+		AND `+where+`
+		AND `+getNotExtensionFilter("c.oid")+`
+	ORDER BY n.nspname, c.relname, a.attnum`, values...).Load(&columns)
+
+	return columns, err
+}
+
+//
+func GetIndexes(ctx jgoweb.ContextInterface, schemas ...string) ([]Index, error) {
+	var indexes []Index
+
+	where, values := getSchemaFilter("n.nspname", schemas)
+
+	_, err := ctx.SelectBySql(`
+	SELECT n.nspname AS schema_name,
+		t.relname AS table_name,
+		i.relname AS name,
+		pg_catalog.pg_get_indexdef(i.oid) AS definition
+	FROM pg_catalog.pg_index x
+	JOIN pg_catalog.pg_class i ON i.oid = x.indexrelid
+	JOIN pg_catalog.pg_class t ON t.oid = x.indrelid
+	JOIN pg_catalog.pg_namespace n ON n.oid = t.relnamespace
+	WHERE t.relkind = 'r'
+		AND NOT t.relispartition
+		AND NOT EXISTS (SELECT 1 FROM pg_catalog.pg_constraint con WHERE con.conindid = i.oid AND con.contype IN ('p', 'u', 'x'))
+		AND `+where+`
+		AND `+getNotExtensionFilter("t.oid")+`
+	ORDER BY n.nspname, i.relname`, values...).Load(&indexes)
+
+	return indexes, err
+}
+
+// Table constraints (NOT NULL is part of the column)
+func GetConstraints(ctx jgoweb.ContextInterface, schemas ...string) ([]Constraint, error) {
+	var constraints []Constraint
+
+	where, values := getSchemaFilter("n.nspname", schemas)
+
+	_, err := ctx.SelectBySql(`
+	SELECT n.nspname AS schema_name,
+		c.relname AS table_name,
+		con.conname AS name,
+		con.contype::text AS type,
+		pg_catalog.pg_get_constraintdef(con.oid) AS definition
+	FROM pg_catalog.pg_constraint con
+	JOIN pg_catalog.pg_class c ON c.oid = con.conrelid
+	JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+	WHERE con.contype IN ('p', 'u', 'f', 'c', 'x')
+		AND c.relkind = 'r'
+		AND NOT c.relispartition
+		AND `+where+`
+		AND `+getNotExtensionFilter("c.oid")+`
+	ORDER BY n.nspname, c.relname, con.conname`, values...).Load(&constraints)
+
+	return constraints, err
+}
+
+//
+func GetSequences(ctx jgoweb.ContextInterface, schemas ...string) ([]Sequence, error) {
+	var sequences []Sequence
+
+	where, values := getSchemaFilter("n.nspname", schemas)
+
+	_, err := ctx.SelectBySql(`
+	SELECT n.nspname AS schema_name,
+		c.relname AS name,
+		pg_catalog.format_type(s.seqtypid, NULL) AS data_type,
+		s.seqstart AS start_value,
+		s.seqincrement AS increment_by,
+		s.seqmin AS min_value,
+		s.seqmax AS max_value,
+		s.seqcycle AS cycle,
+		COALESCE(otn.nspname, '') AS owner_schema,
+		COALESCE(ot.relname, '') AS owner_table,
+		COALESCE(oa.attname, '') AS owner_column
+	FROM pg_catalog.pg_sequence s
+	JOIN pg_catalog.pg_class c ON c.oid = s.seqrelid
+	JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+	LEFT JOIN pg_catalog.pg_depend own ON own.objid = c.oid
+		AND own.classid = 'pg_catalog.pg_class'::regclass
+		AND own.refclassid = 'pg_catalog.pg_class'::regclass
+		AND own.refobjsubid > 0
+		AND own.deptype = 'a'
+	LEFT JOIN pg_catalog.pg_class ot ON ot.oid = own.refobjid
+	LEFT JOIN pg_catalog.pg_namespace otn ON otn.oid = ot.relnamespace
+	LEFT JOIN pg_catalog.pg_attribute oa ON oa.attrelid = own.refobjid AND oa.attnum = own.refobjsubid
+	WHERE NOT EXISTS (SELECT 1 FROM pg_catalog.pg_depend dep WHERE dep.objid = c.oid AND dep.deptype = 'i')
+		AND `+where+`
+		AND `+getNotExtensionFilter("c.oid")+`
+	ORDER BY n.nspname, c.relname`, values...).Load(&sequences)
+
+	return sequences, err
+}
+
+// Functions and procedures (aggregates and window functions are skipped)
+func GetFunctions(ctx jgoweb.ContextInterface, schemas ...string) ([]Function, error) {
+	var functions []Function
+
+	where, values := getSchemaFilter("n.nspname", schemas)
+
+	_, err := ctx.SelectBySql(`
+	SELECT n.nspname AS schema_name,
+		p.proname AS name,
+		pg_catalog.pg_get_function_identity_arguments(p.oid) AS arguments,
+		pg_catalog.pg_get_functiondef(p.oid) AS definition
+	FROM pg_catalog.pg_proc p
+	JOIN pg_catalog.pg_namespace n ON n.oid = p.pronamespace
+	WHERE p.prokind IN ('f', 'p')
+		AND `+where+`
+		AND `+getNotExtensionFilter("p.oid")+`
+	ORDER BY n.nspname, p.proname`, values...).Load(&functions)
+
+	return functions, err
+}
+
+//
+func getSchemaFilter(column string, schemas []string) (string, []interface{}) {
+
+	if len(schemas) > 0 {
+		return column + " IN ?", []interface{}{schemas}
+	}
+
+	return fmt.Sprintf("%s NOT IN ('pg_catalog', 'information_schema') AND %s NOT LIKE 'pg\\_%%'", column, column), nil
+}
+
+//
+func getNotExtensionFilter(oid string) string {
+	return "NOT EXISTS (SELECT 1 FROM pg_catalog.pg_depend dep WHERE dep.objid = " + oid + " AND dep.deptype = 'e')"
+}
+
+// ******
+
+// Quote and join identifiers (i.e., QuoteIdent("public", "users") = "public"."users")
+func QuoteIdent(names ...string) string {
+	quoted := make([]string, len(names))
+
+	for key, name := range names {
+		quoted[key] = `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+	}
+
+	return strings.Join(quoted, ".")
+}
+
+//
+func (t *Table) GetName() string {
+	return QuoteIdent(t.SchemaName, t.Name)
+}
+
+// Column by name (nil if it doesn't exist)
+func (t *Table) GetColumn(name string) *Column {
+
+	for key := range t.Columns {
+		if t.Columns[key].Name == name {
+			return &t.Columns[key]
+		}
+	}
+
+	return nil
+}
+
+// Column definition for CREATE TABLE/ADD COLUMN
+func (c Column) GetDefinition() string {
+	def := QuoteIdent(c.Name) + " " + c.DataType
+
+	switch c.Identity {
+	case "a":
+		def += " GENERATED ALWAYS AS IDENTITY"
+	case "d":
+		def += " GENERATED BY DEFAULT AS IDENTITY"
+	}
+
+	if c.Default.Valid {
+		def += " DEFAULT " + c.Default.String
+	}
+
+	if c.NotNull && c.Identity == "" {
+		def += " NOT NULL"
+	}
+
+	return def
+}
+
+//
+func (s Sequence) GetName() string {
+	return QuoteIdent(s.SchemaName, s.Name)
+}
+
+// Qualified owner table ("" if the sequence isn't owned by a column)
+func (s Sequence) GetOwnerTable() string {
+
+	if s.OwnerTable == "" {
+		return ""
+	}
+
+	return QuoteIdent(s.OwnerSchema, s.OwnerTable)
+}
+
+// Qualified owner column for OWNED BY ("" if the sequence isn't owned by a column)
+func (s Sequence) GetOwner() string {
+
+	if s.OwnerTable == "" {
+		return ""
+	}
+
+	return s.GetOwnerTable() + "." + QuoteIdent(s.OwnerColumn)
+}
+
+// Options for CREATE/ALTER SEQUENCE
+func (s Sequence) GetOptions() string {
+	cycle := "NO CYCLE"
+
+	if s.Cycle {
+		cycle = "CYCLE"
+	}
+
+	return fmt.Sprintf("AS %s INCREMENT BY %d MINVALUE %d MAXVALUE %d START WITH %d %s", s.DataType, s.Increment, s.Min, s.Max, s.Start, cycle)
+}
+
+// Qualified name and argument types (i.e., "public"."add"(integer, integer))
+func (f Function) GetSignature() string {
+	return QuoteIdent(f.SchemaName, f.Name) + "(" + f.Arguments + ")"
+}
+
+// Sorted map keys
+func getSortedKeys(m interface{}) []string {
+	var keys []string
+
+	switch vals := m.(type) {
+	case map[string]*Table:
+		for key := range vals {
+			keys = append(keys, key)
+		}
+	case map[string]Index:
+		for key := range vals {
+			keys = append(keys, key)
+		}
+	case map[string]Constraint:
+		for key := range vals {
+			keys = append(keys, key)
+		}
+	case map[string]Sequence:
+		for key := range vals {
+			keys = append(keys, key)
+		}
+	case map[string]Function:
+		for key := range vals {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,390 @@
+package psql
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jschneider98/jgoweb"
+	"sort"
+	"strings"
+)
+
+// Changes run in phase order, so dependencies exist before they're used and are dropped after their dependents
+const (
+	phaseCreateSchema = iota
+	phaseCreateSequence
+	phaseFunction
+	phaseDropConstraint
+	phaseDropIndex
+	phaseCreateTable
+	phaseAlterColumn
+	phaseSequenceOwner
+	phaseAddConstraint
+	phaseAddForeignKey
+	phaseCreateIndex
+	phaseDropColumn
+	phaseDropTable
+	phaseDropFunction
+	phaseDropSequence
+	phaseDropSchema
+)
+
+// One statement that moves the target towards the source.
+// Destructive changes (drops and column type changes) can lose data.
+type SchemaChange struct {
+	Object      string `json:"object"`
+	Sql         string `json:"sql"`
+	Destructive bool   `json:"destructive"`
+	phase       int
+}
+
+// In execution order
+type SchemaChanges []SchemaChange
+
+// Introspect both DBs and diff them (see DiffSchemas)
+func DiffDatabases(sourceCtx jgoweb.ContextInterface, targetCtx jgoweb.ContextInterface, schemas ...string) (SchemaChanges, error) {
+	source, err := GetSchema(sourceCtx, schemas...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := GetSchema(targetCtx, schemas...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return DiffSchemas(source, target), nil
+}
+
+// Statements that make target match source (i.e., source = dev DB, target = prod shard)
+func DiffSchemas(source *Schema, target *Schema) SchemaChanges {
+	changes := make(SchemaChanges, 0)
+
+	changes = append(changes, diffSchemaNames(source, target)...)
+	changes = append(changes, diffSequences(source, target)...)
+	changes = append(changes, diffFunctions(source, target)...)
+
+	for _, key := range getSortedKeys(source.Tables) {
+		table := source.Tables[key]
+		targetTable, ok := target.Tables[key]
+
+		if !ok {
+			changes = append(changes, createTable(table))
+			targetTable = &Table{SchemaName: table.SchemaName, Name: table.Name, Columns: table.Columns}
+		} else {
+			changes = append(changes, diffColumns(table, targetTable)...)
+		}
+
+		changes = append(changes, diffConstraints(table, targetTable)...)
+		changes = append(changes, diffIndexes(table, targetTable)...)
+	}
+
+	for _, key := range getSortedKeys(target.Tables) {
+		if _, ok := source.Tables[key]; !ok {
+			changes = append(changes, SchemaChange{"table " + key, "DROP TABLE " + key + ";", true, phaseDropTable})
+		}
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].phase < changes[j].phase })
+
+	return changes
+}
+
+//
+func diffSchemaNames(source *Schema, target *Schema) SchemaChanges {
+	changes := make(SchemaChanges, 0)
+	sourceNames := make(map[string]bool)
+	targetNames := make(map[string]bool)
+
+	for _, name := range source.Names {
+		sourceNames[name] = true
+	}
+
+	for _, name := range target.Names {
+		targetNames[name] = true
+	}
+
+	for _, name := range source.Names {
+		if !targetNames[name] {
+			changes = append(changes, SchemaChange{"schema " + QuoteIdent(name), "CREATE SCHEMA " + QuoteIdent(name) + ";", false, phaseCreateSchema})
+		}
+	}
+
+	for _, name := range target.Names {
+		if !sourceNames[name] {
+			changes = append(changes, SchemaChange{"schema " + QuoteIdent(name), "DROP SCHEMA " + QuoteIdent(name) + ";", true, phaseDropSchema})
+		}
+	}
+
+	return changes
+}
+
+//
+func diffSequences(source *Schema, target *Schema) SchemaChanges {
+	changes := make(SchemaChanges, 0)
+
+	for _, key := range getSortedKeys(source.Sequences) {
+		sequence := source.Sequences[key]
+		targetSequence, ok := target.Sequences[key]
+
+		if !ok {
+			changes = append(changes, SchemaChange{"sequence " + key, "CREATE SEQUENCE " + key + " " + sequence.GetOptions() + ";", false, phaseCreateSequence})
+		} else if targetSequence.GetOptions() != sequence.GetOptions() {
+			changes = append(changes, SchemaChange{"sequence " + key, "ALTER SEQUENCE " + key + " " + sequence.GetOptions() + ";", false, phaseCreateSequence})
+		}
+
+		// once the owner column exists
+		if sequence.GetOwner() != targetSequence.GetOwner() {
+			owner := sequence.GetOwner()
+
+			if owner == "" {
+				owner = "NONE"
+			}
+
+			changes = append(changes, SchemaChange{"sequence " + key, "ALTER SEQUENCE " + key + " OWNED BY " + owner + ";", false, phaseSequenceOwner})
+		}
+	}
+
+	for _, key := range getSortedKeys(target.Sequences) {
+		sequence := target.Sequences[key]
+
+		if _, ok := source.Sequences[key]; ok {
+			continue
+		}
+
+		// dropping the owner column (or its table) drops the sequence
+		if sequence.GetOwner() != "" && isColumnDropped(source, sequence.GetOwnerTable(), sequence.OwnerColumn) {
+			continue
+		}
+
+		changes = append(changes, SchemaChange{"sequence " + key, "DROP SEQUENCE " + key + ";", true, phaseDropSequence})
+	}
+
+	return changes
+}
+
+// Column (or its table) isn't in source, so the diff drops it
+func isColumnDropped(source *Schema, tableKey string, column string) bool {
+	table, ok := source.Tables[tableKey]
+
+	return !ok || table.GetColumn(column) == nil
+}
+
+// A changed signature is a new function (and the old one is dropped)
+func diffFunctions(source *Schema, target *Schema) SchemaChanges {
+	changes := make(SchemaChanges, 0)
+
+	for _, key := range getSortedKeys(source.Functions) {
+		function := source.Functions[key]
+		targetFunction, ok := target.Functions[key]
+
+		if !ok || targetFunction.Definition != function.Definition {
+			changes = append(changes, SchemaChange{"function " + key, strings.TrimSpace(function.Definition) + ";", false, phaseFunction})
+		}
+	}
+
+	for _, key := range getSortedKeys(target.Functions) {
+		if _, ok := source.Functions[key]; !ok {
+			changes = append(changes, SchemaChange{"function " + key, "DROP ROUTINE " + key + ";", true, phaseDropFunction})
+		}
+	}
+
+	return changes
+}
+
+// Columns only. Constraints and indexes are added in later phases.
+func createTable(table *Table) SchemaChange {
+	var columns []string
+
+	for _, column := range table.Columns {
+		columns = append(columns, "\t"+column.GetDefinition())
+	}
+
+	query := fmt.Sprintf("CREATE TABLE %s (\n%s\n);", table.GetName(), strings.Join(columns, ",\n"))
+
+	return SchemaChange{"table " + table.GetName(), query, false, phaseCreateTable}
+}
+
+//
+func diffColumns(table *Table, targetTable *Table) SchemaChanges {
+	changes := make(SchemaChanges, 0)
+	alter := "ALTER TABLE " + table.GetName() + " "
+
+	for _, column := range table.Columns {
+		object := "column " + table.GetName() + "." + QuoteIdent(column.Name)
+		name := QuoteIdent(column.Name)
+		targetColumn := targetTable.GetColumn(column.Name)
+
+		if targetColumn == nil {
+			changes = append(changes, SchemaChange{object, alter + "ADD COLUMN " + column.GetDefinition() + ";", false, phaseAlterColumn})
+			continue
+		}
+
+		if targetColumn.DataType != column.DataType {
+			query := fmt.Sprintf("%sALTER COLUMN %s TYPE %s USING %s::%s;", alter, name, column.DataType, name, column.DataType)
+			changes = append(changes, SchemaChange{object, query, true, phaseAlterColumn})
+		}
+
+		if targetColumn.Identity != column.Identity {
+			if targetColumn.Identity != "" {
+				changes = append(changes, SchemaChange{object, alter + "ALTER COLUMN " + name + " DROP IDENTITY;", false, phaseAlterColumn})
+			}
+
+			switch column.Identity {
+			case "a":
+				changes = append(changes, SchemaChange{object, alter + "ALTER COLUMN " + name + " ADD GENERATED ALWAYS AS IDENTITY;", false, phaseAlterColumn})
+			case "d":
+				changes = append(changes, SchemaChange{object, alter + "ALTER COLUMN " + name + " ADD GENERATED BY DEFAULT AS IDENTITY;", false, phaseAlterColumn})
+			}
+		}
+
+		if targetColumn.Default != column.Default {
+			if column.Default.Valid {
+				changes = append(changes, SchemaChange{object, alter + "ALTER COLUMN " + name + " SET DEFAULT " + column.Default.String + ";", false, phaseAlterColumn})
+			} else {
+				changes = append(changes, SchemaChange{object, alter + "ALTER COLUMN " + name + " DROP DEFAULT;", false, phaseAlterColumn})
+			}
+		}
+
+		if targetColumn.NotNull != column.NotNull && column.Identity == "" {
+			if column.NotNull {
+				changes = append(changes, SchemaChange{object, alter + "ALTER COLUMN " + name + " SET NOT NULL;", false, phaseAlterColumn})
+			} else {
+				changes = append(changes, SchemaChange{object, alter + "ALTER COLUMN " + name + " DROP NOT NULL;", false, phaseAlterColumn})
+			}
+		}
+	}
+
+	for _, column := range targetTable.Columns {
+		if table.GetColumn(column.Name) == nil {
+			object := "column " + table.GetName() + "." + QuoteIdent(column.Name)
+			changes = append(changes, SchemaChange{object, alter + "DROP COLUMN " + QuoteIdent(column.Name) + ";", true, phaseDropColumn})
+		}
+	}
+
+	return changes
+}
+
+// A changed constraint is dropped and added again
+func diffConstraints(table *Table, targetTable *Table) SchemaChanges {
+	changes := make(SchemaChanges, 0)
+	alter := "ALTER TABLE " + table.GetName() + " "
+
+	for _, key := range getSortedKeys(table.Constraints) {
+		constraint := table.Constraints[key]
+		targetConstraint, ok := targetTable.Constraints[key]
+		object := "constraint " + table.GetName() + "." + QuoteIdent(key)
+
+		if ok && targetConstraint.Type == constraint.Type && targetConstraint.Definition == constraint.Definition {
+			continue
+		}
+
+		if ok {
+			changes = append(changes, SchemaChange{object, alter + "DROP CONSTRAINT " + QuoteIdent(key) + ";", false, phaseDropConstraint})
+		}
+
+		phase := phaseAddConstraint
+
+		if constraint.Type == "f" {
+			phase = phaseAddForeignKey
+		}
+
+		changes = append(changes, SchemaChange{object, alter + "ADD CONSTRAINT " + QuoteIdent(key) + " " + constraint.Definition + ";", false, phase})
+	}
+
+	for _, key := range getSortedKeys(targetTable.Constraints) {
+		if _, ok := table.Constraints[key]; !ok {
+			object := "constraint " + table.GetName() + "." + QuoteIdent(key)
+			changes = append(changes, SchemaChange{object, alter + "DROP CONSTRAINT " + QuoteIdent(key) + ";", false, phaseDropConstraint})
+		}
+	}
+
+	return changes
+}
+
+// A changed index is dropped and created again
+func diffIndexes(table *Table, targetTable *Table) SchemaChanges {
+	changes := make(SchemaChanges, 0)
+
+	for _, key := range getSortedKeys(table.Indexes) {
+		index := table.Indexes[key]
+		targetIndex, ok := targetTable.Indexes[key]
+
+		if ok && targetIndex.Definition == index.Definition {
+			continue
+		}
+
+		if ok {
+			changes = append(changes, SchemaChange{"index " + key, "DROP INDEX " + key + ";", false, phaseDropIndex})
+		}
+
+		changes = append(changes, SchemaChange{"index " + key, index.Definition + ";", false, phaseCreateIndex})
+	}
+
+	for _, key := range getSortedKeys(targetTable.Indexes) {
+		if _, ok := table.Indexes[key]; !ok {
+			changes = append(changes, SchemaChange{"index " + key, "DROP INDEX " + key + ";", false, phaseDropIndex})
+		}
+	}
+
+	return changes
+}
+
+// ******
+
+//
+func (sc SchemaChanges) Destructive() SchemaChanges {
+	destructive := make(SchemaChanges, 0)
+
+	for _, change := range sc {
+		if change.Destructive {
+			destructive = append(destructive, change)
+		}
+	}
+
+	return destructive
+}
+
+// Destructive changes are refused unless allowDestructive is set (and are marked with a comment when they are)
+func (sc SchemaChanges) Sql(allowDestructive bool) (string, error) {
+	var statements []string
+
+	destructive := sc.Destructive()
+
+	if len(destructive) > 0 && !allowDestructive {
+		var objects []string
+
+		for _, change := range destructive {
+			objects = append(objects, change.Object)
+		}
+
+		return "", errors.New(fmt.Sprintf("Destructive changes require opt-in: %s", strings.Join(objects, ", ")))
+	}
+
+	for _, change := range sc {
+		if change.Destructive {
+			statements = append(statements, "-- DESTRUCTIVE: "+change.Object+"\n"+change.Sql)
+		} else {
+			statements = append(statements, change.Sql)
+		}
+	}
+
+	return strings.Join(statements, "\n\n"), nil
+}
+
+// Update that applies the changes (i.e., to run on every shard with the SystemDbUpdater)
+func (sc SchemaChanges) GetDbUpdate(updateName string, desc string, allowDestructive bool) (*jgoweb.SqlSystemDbUpdate, error) {
+
+	if len(sc) == 0 {
+		return nil, errors.New("No schema changes")
+	}
+
+	query, err := sc.Sql(allowDestructive)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return jgoweb.NewSqlSystemDbUpdate(updateName, desc, "", query), nil
+}
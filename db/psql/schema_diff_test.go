@@ -0,0 +1,186 @@
+// +build unit
+
+package psql
+
+import (
+	"github.com/gocraft/dbr"
+	"strings"
+	"testing"
+)
+
+//
+func getTestSchema() *Schema {
+	s := &Schema{Names: []string{"public"}, Tables: make(map[string]*Table), Sequences: make(map[string]Sequence), Functions: make(map[string]Function)}
+
+	users := s.getTable("public", "users")
+	users.Columns = []Column{
+		{Name: "id", DataType: "bigint", NotNull: true, Identity: "d"},
+		{Name: "email", DataType: "text", NotNull: true},
+		{Name: "status", DataType: "text", Default: dbr.NewNullString("'active'::text")},
+	}
+	users.Constraints["users_pkey"] = Constraint{Name: "users_pkey", Type: "p", Definition: "PRIMARY KEY (id)"}
+	users.Indexes[`"public"."users_email_idx"`] = Index{Name: "users_email_idx", Definition: "CREATE UNIQUE INDEX users_email_idx ON public.users USING btree (email)"}
+
+	return s
+}
+
+//
+func TestQuoteIdent(t *testing.T) {
+	result := QuoteIdent("public", `we"ird`)
+
+	if result != `"public"."we""ird"` {
+		t.Errorf("\nERROR: Unexpected identifier: %s\n", result)
+	}
+}
+
+//
+func TestDiffSchemasSame(t *testing.T) {
+	changes := DiffSchemas(getTestSchema(), getTestSchema())
+
+	if len(changes) != 0 {
+		t.Errorf("\nERROR: Expected no changes. Got: %v\n", changes)
+	}
+}
+
+//
+func TestDiffSchemasCreate(t *testing.T) {
+	source := getTestSchema()
+	source.Names = append(source.Names, "audit")
+	source.Sequences[`"public"."invoice_seq"`] = Sequence{SchemaName: "public", Name: "invoice_seq", DataType: "bigint", Start: 1, Increment: 1, Min: 1, Max: 100}
+
+	orders := source.getTable("public", "orders")
+	orders.Columns = []Column{{Name: "user_id", DataType: "bigint", NotNull: true}}
+	orders.Constraints["orders_user_id_fkey"] = Constraint{Name: "orders_user_id_fkey", Type: "f", Definition: "FOREIGN KEY (user_id) REFERENCES users(id)"}
+
+	target := getTestSchema()
+
+	query, err := DiffSchemas(source, target).Sql(false)
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+		return
+	}
+
+	expected := strings.Join([]string{
+		`CREATE SCHEMA "audit";`,
+		`CREATE SEQUENCE "public"."invoice_seq" AS bigint INCREMENT BY 1 MINVALUE 1 MAXVALUE 100 START WITH 1 NO CYCLE;`,
+		"CREATE TABLE \"public\".\"orders\" (\n\t\"user_id\" bigint NOT NULL\n);",
+		`ALTER TABLE "public"."orders" ADD CONSTRAINT "orders_user_id_fkey" FOREIGN KEY (user_id) REFERENCES users(id);`,
+	}, "\n\n")
+
+	if query != expected {
+		t.Errorf("\nERROR: Unexpected SQL\nExpected:\n%s\nResult:\n%s\n", expected, query)
+	}
+}
+
+//
+func TestDiffSchemasAlter(t *testing.T) {
+	source := getTestSchema()
+	users := source.Tables[`"public"."users"`]
+	users.Columns[1].DataType = "character varying(255)"
+	users.Columns[2].Default = dbr.NullString{}
+	users.Columns = append(users.Columns, Column{Name: "name", DataType: "text"})
+	users.Indexes[`"public"."users_email_idx"`] = Index{Name: "users_email_idx", Definition: "CREATE INDEX users_email_idx ON public.users USING btree (lower(email))"}
+
+	target := getTestSchema()
+	target.Tables[`"public"."users"`].Columns = append(target.Tables[`"public"."users"`].Columns, Column{Name: "legacy", DataType: "text"})
+
+	changes := DiffSchemas(source, target)
+
+	_, err := changes.Sql(false)
+
+	if err == nil || !strings.Contains(err.Error(), `"legacy"`) || !strings.Contains(err.Error(), `"email"`) {
+		t.Errorf("\nERROR: Expected destructive changes to be refused. Got: %v\n", err)
+	}
+
+	query, err := changes.Sql(true)
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+		return
+	}
+
+	expected := strings.Join([]string{
+		`DROP INDEX "public"."users_email_idx";`,
+		"-- DESTRUCTIVE: column \"public\".\"users\".\"email\"\n" + `ALTER TABLE "public"."users" ALTER COLUMN "email" TYPE character varying(255) USING "email"::character varying(255);`,
+		`ALTER TABLE "public"."users" ALTER COLUMN "status" DROP DEFAULT;`,
+		`ALTER TABLE "public"."users" ADD COLUMN "name" text;`,
+		`CREATE INDEX users_email_idx ON public.users USING btree (lower(email));`,
+		"-- DESTRUCTIVE: column \"public\".\"users\".\"legacy\"\n" + `ALTER TABLE "public"."users" DROP COLUMN "legacy";`,
+	}, "\n\n")
+
+	if query != expected {
+		t.Errorf("\nERROR: Unexpected SQL\nExpected:\n%s\nResult:\n%s\n", expected, query)
+	}
+}
+
+//
+func TestDiffSchemasDrop(t *testing.T) {
+	source := &Schema{Tables: make(map[string]*Table), Sequences: make(map[string]Sequence), Functions: make(map[string]Function)}
+	target := getTestSchema()
+	target.Functions[`"public"."add"(integer, integer)`] = Function{SchemaName: "public", Name: "add", Arguments: "integer, integer"}
+
+	changes := DiffSchemas(source, target)
+	destructive := changes.Destructive()
+
+	if len(destructive) != 3 || destructive[0].Object != `table "public"."users"` || destructive[2].Object != `schema "public"` {
+		t.Errorf("\nERROR: Unexpected destructive changes: %v\n", destructive)
+	}
+
+	_, err := changes.GetDbUpdate("0002_drop", "drop", false)
+
+	if err == nil {
+		t.Errorf("\nERROR: Expected destructive update to be refused\n")
+	}
+
+	update, err := changes.GetDbUpdate("0002_drop", "drop", true)
+
+	if err != nil || !strings.Contains(update.Sql, `DROP ROUTINE "public"."add"(integer, integer);`) {
+		t.Errorf("\nERROR: Unexpected update: %v Error: %v\n", update, err)
+	}
+}
+
+//
+func getTestSerialSchema() *Schema {
+	s := getTestSchema()
+	s.Sequences[`"public"."orders_id_seq"`] = Sequence{SchemaName: "public", Name: "orders_id_seq", DataType: "integer", Start: 1, Increment: 1, Min: 1, Max: 2147483647, OwnerSchema: "public", OwnerTable: "orders", OwnerColumn: "id"}
+
+	orders := s.getTable("public", "orders")
+	orders.Columns = []Column{{Name: "id", DataType: "integer", NotNull: true, Default: dbr.NewNullString(`nextval('orders_id_seq'::regclass)`)}}
+
+	return s
+}
+
+//
+func TestDiffSchemasDropSerialTable(t *testing.T) {
+	query, err := DiffSchemas(getTestSchema(), getTestSerialSchema()).Sql(true)
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+		return
+	}
+
+	if !strings.Contains(query, `DROP TABLE "public"."orders";`) || strings.Contains(query, "DROP SEQUENCE") {
+		t.Errorf("\nERROR: Expected DROP TABLE to drop the owned sequence. Got:\n%s\n", query)
+	}
+}
+
+//
+func TestDiffSchemasCreateSerialTable(t *testing.T) {
+	query, err := DiffSchemas(getTestSerialSchema(), getTestSchema()).Sql(false)
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+		return
+	}
+
+	expected := strings.Join([]string{
+		`CREATE SEQUENCE "public"."orders_id_seq" AS integer INCREMENT BY 1 MINVALUE 1 MAXVALUE 2147483647 START WITH 1 NO CYCLE;`,
+		"CREATE TABLE \"public\".\"orders\" (\n\t\"id\" integer DEFAULT nextval('orders_id_seq'::regclass) NOT NULL\n);",
+		`ALTER SEQUENCE "public"."orders_id_seq" OWNED BY "public"."orders"."id";`,
+	}, "\n\n")
+
+	if query != expected {
+		t.Errorf("\nERROR: Unexpected SQL\nExpected:\n%s\nResult:\n%s\n", expected, query)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/gocraft/dbr/dialect"
 	"github.com/jschneider98/jgoweb"
 	"github.com/jschneider98/jgoweb/util"
+	"github.com/lib/pq"
 	"regexp"
 	"strings"
 )
@@ -21,8 +22,13 @@ type Field struct {
 	Annotation      string         `json:"annotation"`
 	NotNull         bool           `json:"not_null"`
 	SortNum         int            `json:"sort_num"`
+	IsEnum          bool           `json:"is_enum"`
+	EnumValues      pq.StringArray `json:"enum_values"`
 }
 
+var dataTypeModifierRegexp = regexp.MustCompile(`\([0-9, ]+\)`)
+var typedLiteralRegexp = regexp.MustCompile(`^'((?:[^']|'')*)'::[\w ."]+$`)
+
 func GetFields(ctx jgoweb.ContextInterface, schema string, table string) ([]Field, error) {
 	var fields []Field
 
@@ -35,11 +41,19 @@ func GetFields(ctx jgoweb.ContextInterface, schema string, table string) ([]Fiel
 			WHERE d.adrelid = a.attrelid AND d.adnum = a.attnum AND a.atthasdef
 		) as db_default,
 		a.attnotnull as not_null,
-		a.attnum as sort_num
+		a.attnum as sort_num,
+		t.typtype = 'e' as is_enum,
+		ARRAY(
+			SELECT e.enumlabel::text
+			FROM pg_catalog.pg_enum e
+			WHERE e.enumtypid = a.atttypid
+			ORDER BY e.enumsortorder
+		) as enum_values
 	`).
 		From(dbr.I("pg_catalog.pg_class").As("c")).
 		Join(dbr.I("pg_catalog.pg_namespace").As("n"), "n.oid = c.relnamespace").
 		Join(dbr.I("pg_catalog.pg_attribute").As("a"), "c.oid = a.attrelid").
+		Join(dbr.I("pg_catalog.pg_type").As("t"), "t.oid = a.atttypid").
 		Where("n.nspname = ?", schema).
 		Where("c.relname = ?", table).
 		Where("a.attnum > ?", 0).
@@ -81,32 +95,67 @@ func (f *Field) SetDefault() {
 
 	if strings.Contains(f.DbDefault.String, "(") {
 		f.DbDefaultIsFunc = true
-	} else {
-		f.Default = f.DbDefault.String
+		return
+	}
+
+	f.Default = f.DbDefault.String
+
+	// 'draft'::text => draft
+	match := typedLiteralRegexp.FindStringSubmatch(f.Default)
+
+	if match != nil {
+		f.Default = strings.ReplaceAll(match[1], "''", "'")
 	}
 }
 
+// Without type modifiers or array brackets. e.g., character varying(20)[] => character varying
+func (f *Field) GetBaseDbDataType() string {
+	return strings.TrimSuffix(dataTypeModifierRegexp.ReplaceAllString(f.DbDataType, ""), "[]")
+}
+
 //
+func (f *Field) IsArray() bool {
+	return strings.HasSuffix(f.DbDataType, "[]")
+}
+
+// Go type of the field. NOT NULL timestamps are time.Time, nullable types use sql.Null* (or util.NullRawMessage for json).
+// Enums, uuids, numerics, etc. are strings.
 func (f *Field) SetDataType() {
+	dataType := f.GetBaseDbDataType()
+
+	if f.IsArray() {
+		switch dataType {
+		case "smallint", "integer", "bigint":
+			f.DataType = "pq.Int64Array"
+		case "boolean":
+			f.DataType = "pq.BoolArray"
+		case "double precision", "real":
+			f.DataType = "pq.Float64Array"
+		default:
+			f.DataType = "pq.StringArray"
+		}
+
+		return
+	}
 
-	// switch f.DbDataType {
-	// case "smallint":
-	// case "smallserial":
-	// case "serial":
-	// case "bigint":
-	// case "bigserial":
-	// case "integer":
-	// 	f.DataType = "sql.NullInt64"
-	// 	return
-	// case "boolean":
-	// 	f.DataType = "sql.NullBool"
-	// 	return
-	// case "double precision":
-	// 	f.DataType = "sql.NullFloat64"
-	// 	return
-	// }
-
-	f.DataType = "sql.NullString"
+	switch dataType {
+	case "smallint", "integer", "bigint":
+		f.DataType = "sql.NullInt64"
+	case "boolean":
+		f.DataType = "sql.NullBool"
+	case "double precision", "real":
+		f.DataType = "sql.NullFloat64"
+	case "timestamp with time zone", "timestamp without time zone", "date":
+		if f.NotNull {
+			f.DataType = "time.Time"
+		} else {
+			f.DataType = "sql.NullTime"
+		}
+	case "json", "jsonb":
+		f.DataType = "util.NullRawMessage"
+	default:
+		f.DataType = "sql.NullString"
+	}
 }
 
 //
@@ -114,56 +163,56 @@ func (f *Field) SetAnnotation() {
 	f.Annotation = fmt.Sprintf("`json:\"%s\" %s`", f.FieldName, f.GetValidation())
 }
 
-//
+// Validation for the Go type. Format checks (uuid, max length, enum values) only apply to strings.
 func (f *Field) GetValidation() string {
 	val := "validate:"
 
-	// if not null and no default = required (Special case, bool = notnull)
+	// if not null and no default = required (Special case, bools and numbers = notNull, since zero is a value)
 	// if not null with default = no insert/update (i.e., use default)
 	// if nullable = omitempty
 	if f.NotNull == true && !f.DbDefault.Valid {
 
-		if f.DbDataType == "boolean" {
+		switch f.DataType {
+		case "sql.NullBool", "sql.NullInt64", "sql.NullFloat64":
 			val += `"notNull`
-		} else {
+		default:
 			val += `"required`
 		}
 	} else {
 		val += `"omitempty`
 	}
 
-	// if f.NotNull == true && !f.DbDefault.Valid {
-	// 	val += `"required`
-	// } else {
-	// 	val += `"omitempty`
-	// }
+	if f.DataType == "sql.NullString" {
+		val += f.GetStringValidation()
+	}
 
-	switch f.DbDataType {
-	case "smallint", "smallserial", "serial", "integer", "bigint", "bigserial":
-		val += ",int"
-	case "double precision", "real":
-		val += ",numeric"
+	val += `"`
+
+	return val
+}
+
+//
+func (f *Field) GetStringValidation() string {
+	var val string
+
+	switch f.GetBaseDbDataType() {
 	case "uuid":
 		val += ",uuid"
-	case "timestamp with time zone":
-		val += ",rfc3339"
-	case "timestamp without time zone":
-		val += ",rfc3339WithoutZone"
-	case "date":
-		val += ",date"
-	}
-
-	if strings.HasPrefix(f.DbDataType, "character varying(") {
+	case "numeric":
+		val += ",numeric"
+	case "character varying", "character":
 		re := regexp.MustCompile("[0-9]+")
-		val += ",min=1,max=" + re.FindString(f.DbDataType)
-	}
+		length := re.FindString(f.DbDataType)
 
-	if strings.HasPrefix(f.DbDataType, "character(") {
-		re := regexp.MustCompile("[0-9]+")
-		val += ",min=1,max=" + re.FindString(f.DbDataType)
+		if length != "" {
+			val += ",min=1,max=" + length
+		}
 	}
 
-	val += `"`
+	// oneof can't handle values with spaces (or tag syntax)
+	if f.IsEnum && len(f.EnumValues) > 0 && !strings.ContainsAny(strings.Join(f.EnumValues, ""), " ,|'\"`") {
+		val += ",oneof=" + strings.Join(f.EnumValues, " ")
+	}
 
 	return val
 }
@@ -0,0 +1,90 @@
+// +build unit
+
+package psql
+
+import (
+	"github.com/gocraft/dbr"
+	"testing"
+)
+
+//
+func TestFieldSetDataType(t *testing.T) {
+	tests := []struct {
+		dbDataType string
+		notNull    bool
+		expected   string
+	}{
+		{"integer", false, "sql.NullInt64"},
+		{"bigint", true, "sql.NullInt64"},
+		{"boolean", false, "sql.NullBool"},
+		{"double precision", false, "sql.NullFloat64"},
+		{"numeric(10,2)", false, "sql.NullString"},
+		{"timestamp(3) with time zone", true, "time.Time"},
+		{"timestamp without time zone", false, "sql.NullTime"},
+		{"date", false, "sql.NullTime"},
+		{"jsonb", true, "util.NullRawMessage"},
+		{"uuid", false, "sql.NullString"},
+		{"character varying(20)[]", false, "pq.StringArray"},
+		{"integer[]", false, "pq.Int64Array"},
+		{"boolean[]", false, "pq.BoolArray"},
+	}
+
+	for _, test := range tests {
+		f := Field{DbFieldName: "x", DbDataType: test.dbDataType, NotNull: test.notNull}
+		f.SetStructVals()
+
+		if f.DataType != test.expected {
+			t.Errorf("\nERROR: %s: Expected: %s Got: %s\n", test.dbDataType, test.expected, f.DataType)
+		}
+	}
+}
+
+//
+func TestFieldGetValidation(t *testing.T) {
+	tests := []struct {
+		field    Field
+		expected string
+	}{
+		{Field{DbDataType: "integer", NotNull: true}, `validate:"notNull"`},
+		{Field{DbDataType: "integer", NotNull: true, DbDefault: dbr.NewNullString("0")}, `validate:"omitempty"`},
+		{Field{DbDataType: "timestamp with time zone", NotNull: true}, `validate:"required"`},
+		{Field{DbDataType: "date"}, `validate:"omitempty"`},
+		{Field{DbDataType: "character varying(50)", NotNull: true}, `validate:"required,min=1,max=50"`},
+		{Field{DbDataType: "character varying"}, `validate:"omitempty"`},
+		{Field{DbDataType: "uuid"}, `validate:"omitempty,uuid"`},
+		{Field{DbDataType: "status", IsEnum: true, EnumValues: []string{"draft", "live"}}, `validate:"omitempty,oneof=draft live"`},
+		{Field{DbDataType: "status", IsEnum: true, EnumValues: []string{"in progress"}}, `validate:"omitempty"`},
+	}
+
+	for _, test := range tests {
+		test.field.SetDataType()
+
+		if test.field.GetValidation() != test.expected {
+			t.Errorf("\nERROR: %s: Expected: %s Got: %s\n", test.field.DbDataType, test.expected, test.field.GetValidation())
+		}
+	}
+}
+
+//
+func TestFieldSetDefault(t *testing.T) {
+	tests := []struct {
+		dbDefault string
+		isFunc    bool
+		expected  string
+	}{
+		{"'draft'::character varying", false, "draft"},
+		{"'it''s'::public.status", false, "it's"},
+		{"'{}'::jsonb", false, "{}"},
+		{"0", false, "0"},
+		{"now()", true, ""},
+	}
+
+	for _, test := range tests {
+		f := Field{DbDefault: dbr.NewNullString(test.dbDefault)}
+		f.SetDefault()
+
+		if f.Default != test.expected || f.DbDefaultIsFunc != test.isFunc {
+			t.Errorf("\nERROR: %s: Expected: %s %v Got: %s %v\n", test.dbDefault, test.expected, test.isFunc, f.Default, f.DbDefaultIsFunc)
+		}
+	}
+}
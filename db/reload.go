@@ -0,0 +1,228 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gocraft/dbr"
+	"github.com/jschneider98/jgoweb/config"
+	"reflect"
+	"time"
+)
+
+// How long a removed/replaced pool gets to finish in-flight queries before it's closed
+var DrainTimeout = 30 * time.Second
+
+// Add a shard connection (and its replicas) at runtime
+func (db *Collection) AddConn(connInfo config.DbConnOptions) error {
+
+	if _, err := db.GetConnByName(connInfo.ShardName); err == nil {
+		return errors.New(fmt.Sprintf("Connection %s already exists", connInfo.ShardName))
+	}
+
+	return db.ReplaceConn(connInfo)
+}
+
+// Add or replace a shard connection. The old pools are drained in the background.
+func (db *Collection) ReplaceConn(connInfo config.DbConnOptions) error {
+
+	if connInfo.ShardName == "" {
+		return errors.New("Connection requires a shard name")
+	}
+
+	conn, replicas, err := openConns(connInfo)
+
+	if err != nil {
+		return err
+	}
+
+	db.mu.Lock()
+
+	oldConn := db.Conns[connInfo.ShardName]
+	oldReplicas := db.Replicas[connInfo.ShardName]
+
+	if db.Conns == nil {
+		db.Conns = make(map[string]*dbr.Connection)
+	}
+
+	if db.Replicas == nil {
+		db.Replicas = make(map[string][]*Replica)
+	}
+
+	db.Conns[connInfo.ShardName] = conn
+	db.Replicas[connInfo.ShardName] = replicas
+
+	if index, ok := db.ConfigMap[connInfo.ShardName]; ok {
+		db.Config[index] = connInfo
+	} else {
+		db.Config = append(db.Config, connInfo)
+		db.rebuildConfigMap()
+	}
+
+	db.mu.Unlock()
+
+	// new pool, new health
+	db.clearHealth(connInfo.ShardName)
+	drainConns(oldConn, oldReplicas)
+
+	return nil
+}
+
+// Remove a shard connection. In-flight queries get DrainTimeout to finish.
+func (db *Collection) RemoveConn(name string) error {
+	db.mu.Lock()
+
+	conn, ok := db.Conns[name]
+
+	if !ok {
+		db.mu.Unlock()
+		return errors.New(fmt.Sprintf("Connection %s does not exist", name))
+	}
+
+	replicas := db.Replicas[name]
+
+	delete(db.Conns, name)
+	delete(db.Replicas, name)
+
+	dbConns := make([]config.DbConnOptions, 0)
+
+	for _, connInfo := range db.Config {
+		if connInfo.ShardName != name {
+			dbConns = append(dbConns, connInfo)
+		}
+	}
+
+	db.Config = dbConns
+	db.rebuildConfigMap()
+
+	db.mu.Unlock()
+
+	db.clearHealth(name)
+	drainConns(conn, replicas)
+
+	return nil
+}
+
+// Make the collection match dbConns. Unchanged connections are left alone.
+func (db *Collection) Reload(dbConns []config.DbConnOptions) error {
+	var msg string
+
+	db.reloadMu.Lock()
+	defer db.reloadMu.Unlock()
+
+	current := make(map[string]config.DbConnOptions)
+	wanted := make(map[string]bool)
+
+	for _, name := range db.GetNames() {
+		connInfo, err := db.GetConfigByName(name)
+
+		if err == nil {
+			current[name] = connInfo
+		}
+	}
+
+	for _, connInfo := range dbConns {
+		wanted[connInfo.ShardName] = true
+
+		if oldInfo, ok := current[connInfo.ShardName]; ok && reflect.DeepEqual(oldInfo, connInfo) {
+			continue
+		}
+
+		err := db.ReplaceConn(connInfo)
+
+		if err != nil {
+			msg += connInfo.ShardName + ": " + err.Error() + "\n"
+		}
+	}
+
+	for name := range current {
+		if wanted[name] {
+			continue
+		}
+
+		err := db.RemoveConn(name)
+
+		if err != nil {
+			msg += name + ": " + err.Error() + "\n"
+		}
+	}
+
+	if msg != "" {
+		return errors.New(msg)
+	}
+
+	return nil
+}
+
+// caller must hold the write lock
+func (db *Collection) rebuildConfigMap() {
+	db.ConfigMap = make(map[string]int)
+
+	for index, connInfo := range db.Config {
+		db.ConfigMap[connInfo.ShardName] = index
+	}
+}
+
+//
+func (db *Collection) clearHealth(name string) {
+	db.health.mu.Lock()
+	defer db.health.mu.Unlock()
+
+	delete(db.health.conns, name)
+}
+
+//
+func openConns(connInfo config.DbConnOptions) (*dbr.Connection, []*Replica, error) {
+	var replicas []*Replica
+
+	conn, err := OpenConn(connInfo.Dsn, connInfo)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, dsn := range connInfo.Replicas {
+		replicaConn, err := OpenConn(dsn, connInfo)
+
+		if err != nil {
+			conn.Close()
+			drainConns(nil, replicas)
+
+			return nil, nil, err
+		}
+
+		replica := &Replica{ShardName: connInfo.ShardName, Dsn: dsn, Conn: replicaConn}
+		replica.MaxLag = time.Duration(connInfo.MaxReplicaLag) * time.Millisecond
+
+		replicas = append(replicas, replica)
+	}
+
+	return conn, replicas, nil
+}
+
+// Close pools once they're idle (or after DrainTimeout)
+func drainConns(conn *dbr.Connection, replicas []*Replica) {
+	var conns []*dbr.Connection
+
+	if conn != nil {
+		conns = append(conns, conn)
+	}
+
+	for _, replica := range replicas {
+		conns = append(conns, replica.Conn)
+	}
+
+	for _, curConn := range conns {
+		go DrainConn(curConn, DrainTimeout)
+	}
+}
+
+// Wait for in-use connections to be returned to the pool, then close it
+func DrainConn(conn *dbr.Connection, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+
+	for conn.Stats().InUse > 0 && time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	return conn.Close()
+}
@@ -0,0 +1,145 @@
+// +build unit
+
+package db
+
+import (
+	"github.com/jschneider98/jgoweb/config"
+	"sync"
+	"testing"
+)
+
+//
+func getTestReloadConn(name string) config.DbConnOptions {
+	return config.DbConnOptions{ShardName: name, Dsn: "postgres://127.0.0.1:1/" + name + "?sslmode=disable"}
+}
+
+//
+func TestAddRemoveConn(t *testing.T) {
+	db, err := NewDb([]config.DbConnOptions{getTestReloadConn("shard_1")})
+
+	if err != nil {
+		t.Fatalf("\nERROR: %v\n", err)
+	}
+
+	err = db.AddConn(getTestReloadConn("shard_2"))
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+	}
+
+	err = db.AddConn(getTestReloadConn("shard_2"))
+
+	if err == nil {
+		t.Errorf("\nERROR: Adding an existing connection should fail.\n")
+	}
+
+	_, err = db.GetSessionByName("shard_2")
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+	}
+
+	err = db.RemoveConn("shard_1")
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+	}
+
+	_, err = db.GetConfigByName("shard_1")
+
+	if err == nil {
+		t.Errorf("\nERROR: Removed connection config should be gone.\n")
+	}
+
+	names := db.GetNames()
+
+	if len(names) != 1 || names[0] != "shard_2" {
+		t.Errorf("\nERROR: Expected only shard_2. Got: %v\n", names)
+	}
+
+	err = db.RemoveConn("shard_1")
+
+	if err == nil {
+		t.Errorf("\nERROR: Removing a missing connection should fail.\n")
+	}
+}
+
+//
+func TestReload(t *testing.T) {
+	db, err := NewDb([]config.DbConnOptions{getTestReloadConn("shard_1"), getTestReloadConn("shard_2")})
+
+	if err != nil {
+		t.Fatalf("\nERROR: %v\n", err)
+	}
+
+	shard1, _ := db.GetConnByName("shard_1")
+	shard2, _ := db.GetConnByName("shard_2")
+
+	changed := getTestReloadConn("shard_2")
+	changed.StatementTimeout = 1000
+
+	err = db.Reload([]config.DbConnOptions{getTestReloadConn("shard_1"), changed, getTestReloadConn("shard_3")})
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+	}
+
+	if conn, _ := db.GetConnByName("shard_1"); conn != shard1 {
+		t.Errorf("\nERROR: Unchanged connection should not be replaced.\n")
+	}
+
+	if conn, _ := db.GetConnByName("shard_2"); conn == shard2 {
+		t.Errorf("\nERROR: Changed connection should be replaced.\n")
+	}
+
+	if _, err := db.GetConnByName("shard_3"); err != nil {
+		t.Errorf("\nERROR: New connection should be added. %v\n", err)
+	}
+
+	err = db.Reload([]config.DbConnOptions{getTestReloadConn("shard_1")})
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+	}
+
+	if len(db.GetConns()) != 1 {
+		t.Errorf("\nERROR: Expected 1 connection. Got: %v\n", len(db.GetConns()))
+	}
+}
+
+//
+func TestReloadConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+
+	db, err := NewDb([]config.DbConnOptions{getTestReloadConn("shard_1")})
+
+	if err != nil {
+		t.Fatalf("\nERROR: %v\n", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+
+		go func() {
+			defer wg.Done()
+			db.Reload([]config.DbConnOptions{getTestReloadConn("shard_1"), getTestReloadConn("shard_2")})
+			db.Reload([]config.DbConnOptions{getTestReloadConn("shard_1")})
+		}()
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 10; j++ {
+				db.GetSessionByName("shard_1")
+				db.GetRandomConn()
+				db.GetConns()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if _, err := db.GetConnByName("shard_1"); err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+	}
+}
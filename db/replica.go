@@ -0,0 +1,173 @@
+package db
+
+import (
+	"context"
+	"github.com/gocraft/dbr"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// How often a replica's lag is re-checked
+var ReplicaLagCheckInterval = 5 * time.Second
+
+// Read replica for a shard
+type Replica struct {
+	ShardName string
+	Dsn       string
+	Conn      *dbr.Connection
+	MaxLag    time.Duration
+	lag       time.Duration
+	lagErr    error
+	checkedAt time.Time
+	pingErr   error
+	mu        sync.Mutex
+}
+
+// Replica is usable if it's healthy and within MaxLag (0 = lag isn't checked)
+func (r *Replica) IsCurrent() bool {
+
+	if !r.IsHealthy() {
+		return false
+	}
+
+	if r.MaxLag <= 0 {
+		return true
+	}
+
+	r.mu.Lock()
+	stale := time.Since(r.checkedAt) > ReplicaLagCheckInterval
+	r.mu.Unlock()
+
+	if stale {
+		r.CheckLag()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.lagErr == nil && r.lag <= r.MaxLag
+}
+
+// Query the replica's replay lag. 0 if it has replayed everything it has received.
+func (r *Replica) CheckLag() (time.Duration, error) {
+	var seconds []float64
+
+	_, err := r.Conn.NewSession(nil).SelectBySql(`
+	SELECT
+		CASE
+			WHEN NOT pg_is_in_recovery() THEN 0
+			WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+			ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+		END::float8`).Load(&seconds)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.checkedAt = time.Now()
+	r.lagErr = err
+	r.lag = 0
+
+	if err == nil && len(seconds) > 0 {
+		r.lag = time.Duration(seconds[0] * float64(time.Second))
+	}
+
+	return r.lag, r.lagErr
+}
+
+//
+func (r *Replica) GetLag() (time.Duration, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.lag, r.lagErr
+}
+
+// Ping the replica. Unhealthy replicas are skipped until a later check passes.
+func (r *Replica) CheckHealth(timeout time.Duration) error {
+	goCtx := context.Background()
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		goCtx, cancel = context.WithTimeout(goCtx, timeout)
+		defer cancel()
+	}
+
+	err := r.Conn.PingContext(goCtx)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.pingErr = err
+
+	return err
+}
+
+//
+func (r *Replica) IsHealthy() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.pingErr == nil
+}
+
+// ******
+
+//
+func (db *Collection) GetReplicasByName(name string) []*Replica {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return db.Replicas[name]
+}
+
+// Primary session for a shard (same as GetSessionByName)
+func (db *Collection) GetPrimarySessionByName(name string) (*dbr.Session, error) {
+	return db.GetSessionByName(name)
+}
+
+// Round robin over the shard's current replicas. Falls back to the primary if none are usable.
+func (db *Collection) GetReplicaSessionByName(name string) (*dbr.Session, error) {
+	replicas := db.GetReplicasByName(name)
+
+	if len(replicas) == 0 {
+		return db.GetSessionByName(name)
+	}
+
+	config, err := db.GetConfigByName(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	start := atomic.AddUint64(&db.replicaNext, 1)
+
+	for i := 0; i < len(replicas); i++ {
+		replica := replicas[(start+uint64(i))%uint64(len(replicas))]
+
+		if !replica.IsCurrent() {
+			continue
+		}
+
+		dbSess := replica.Conn.NewSession(nil)
+		dbSess.Timeout = time.Duration(config.StatementTimeout) * time.Millisecond
+
+		return dbSess, nil
+	}
+
+	return db.GetSessionByName(name)
+}
+
+// Shard name for a primary connection
+func (db *Collection) GetConnName(conn *dbr.Connection) (string, bool) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	for name, curConn := range db.Conns {
+		if curConn == conn {
+			return name, true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,91 @@
+// +build unit
+
+package db
+
+import (
+	"github.com/jschneider98/jgoweb/config"
+	"testing"
+)
+
+// Pools are opened lazily, so nothing connects
+func getTestReplicaDb(t *testing.T) *Collection {
+	dbConns := []config.DbConnOptions{
+		config.DbConnOptions{
+			ShardName: "shard_1",
+			Dsn:       "postgres://primary/test?sslmode=disable",
+			Replicas:  []string{"postgres://replica_a/test?sslmode=disable", "postgres://replica_b/test?sslmode=disable"},
+		},
+		config.DbConnOptions{
+			ShardName: "shard_2",
+			Dsn:       "postgres://primary_2/test?sslmode=disable",
+		},
+	}
+
+	db, err := NewDb(dbConns)
+
+	if err != nil {
+		t.Fatalf("\nERROR: %v\n", err)
+	}
+
+	return db
+}
+
+//
+func TestGetReplicaSessionByName(t *testing.T) {
+	db := getTestReplicaDb(t)
+	seen := make(map[string]int)
+
+	for i := 0; i < 4; i++ {
+		sess, err := db.GetReplicaSessionByName("shard_1")
+
+		if err != nil {
+			t.Errorf("\nERROR: %v\n", err)
+			return
+		}
+
+		for _, replica := range db.GetReplicasByName("shard_1") {
+			if sess.Connection == replica.Conn {
+				seen[replica.Dsn]++
+			}
+		}
+	}
+
+	if len(seen) != 2 || seen["postgres://replica_a/test?sslmode=disable"] != 2 {
+		t.Errorf("\nERROR: Replicas should be used round robin. Got: %v\n", seen)
+	}
+
+	// no replicas = primary
+	sess, err := db.GetReplicaSessionByName("shard_2")
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+		return
+	}
+
+	if sess.Connection != db.Conns["shard_2"] {
+		t.Errorf("\nERROR: Shard without replicas should use the primary.\n")
+	}
+
+	_, err = db.GetReplicaSessionByName("bad_shard")
+
+	if err == nil {
+		t.Errorf("\nERROR: Invalid shard should return an error.\n")
+	}
+}
+
+//
+func TestGetConnName(t *testing.T) {
+	db := getTestReplicaDb(t)
+
+	name, ok := db.GetConnName(db.Conns["shard_2"])
+
+	if !ok || name != "shard_2" {
+		t.Errorf("\nERROR: Expected shard_2. Got: %v\n", name)
+	}
+
+	_, ok = db.GetConnName(db.GetReplicasByName("shard_1")[0].Conn)
+
+	if ok {
+		t.Errorf("\nERROR: Replica connections are not primaries.\n")
+	}
+}
@@ -0,0 +1,75 @@
+package jgoweb
+
+import (
+	"github.com/gocraft/health"
+	jgoWebDb "github.com/jschneider98/jgoweb/db"
+	"github.com/prometheus/client_golang/prometheus"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	dbConnUpGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "db_connection_up",
+		Help: "1 if the shard's primary passed its last health check. Labels: shard.",
+	},
+		[]string{"shard"},
+	)
+
+	dbConnPingGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "db_connection_ping_milliseconds",
+		Help: "Latency of the last health check ping. Labels: shard.",
+	},
+		[]string{"shard"},
+	)
+)
+
+var dbHealthOnce sync.Once
+
+// Startup connectivity check + background health checks (see config.DbHealth)
+func InitDbHealthChecks() {
+	dbHealthOnce.Do(func() {
+		InitDbCollection()
+
+		options := appConfig.DbHealth
+		timeout := time.Duration(options.Timeout) * time.Millisecond
+
+		err := db.CheckStartup(options.Mode == "failfast", timeout)
+
+		if err != nil {
+			if options.Mode == "failfast" {
+				panic(err)
+			}
+
+			log.Printf("WARNING: %s", err)
+		}
+
+		ReportDbHealth(db.GetHealth())
+
+		db.StartHealthChecks(time.Duration(options.Interval)*time.Second, timeout, ReportDbHealth)
+	})
+}
+
+// Send shard health to the metrics and the health stream. Failures are reported every round.
+func ReportDbHealth(conns map[string]jgoWebDb.ConnHealth) {
+
+	for name, connHealth := range conns {
+		up := 0.0
+
+		if connHealth.Healthy {
+			up = 1
+		}
+
+		dbConnUpGauge.WithLabelValues(name).Set(up)
+		dbConnPingGauge.WithLabelValues(name).Set(float64(connHealth.Latency.Nanoseconds()) / 1000000)
+
+		if !connHealth.Healthy {
+			healthStream.EventErrKv("db_health_check", connHealth.Err, health.Kvs{
+				"shard":    name,
+				"failures": strconv.Itoa(connHealth.Failures),
+			})
+		}
+	}
+}
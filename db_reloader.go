@@ -0,0 +1,201 @@
+package jgoweb
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jschneider98/jgoweb/config"
+	jgoWebDb "github.com/jschneider98/jgoweb/db"
+	"github.com/jschneider98/jgoweb/util"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	DbReloadSourceFile   = "file"
+	DbReloadSourceShards = "shards"
+)
+
+var dbReloader *DbReloader
+
+// Keeps a Collection's connections in sync with the config file or system.shards
+type DbReloader struct {
+	Db         *jgoWebDb.Collection
+	Source     string
+	Interval   time.Duration
+	ConfigPath string
+	BaseConns  []config.DbConnOptions
+	modTime    time.Time
+	stop       chan struct{}
+	mu         sync.Mutex
+}
+
+// baseConns are the configured connections. The shards source only adds/removes connections not in baseConns.
+func NewDbReloader(db *jgoWebDb.Collection, source string, baseConns []config.DbConnOptions) *DbReloader {
+	dr := &DbReloader{Db: db, Source: source, BaseConns: baseConns}
+	dr.Interval = 30 * time.Second
+	dr.ConfigPath = appConfigPath
+
+	return dr
+}
+
+// Start the reloader from config.DbReload (if a source is set)
+func InitDbReloader() {
+	InitDbCollection()
+
+	options := appConfig.DbReload
+
+	if options.Source == "" || dbReloader != nil {
+		return
+	}
+
+	jgoWebDb.DrainTimeout = time.Duration(options.DrainTimeout) * time.Second
+
+	dbReloader = NewDbReloader(db, options.Source, appConfig.DbConns)
+	dbReloader.Interval = time.Duration(options.Interval) * time.Second
+	dbReloader.Start()
+}
+
+//
+func GetDbReloader() *DbReloader {
+	return dbReloader
+}
+
+//
+func (dr *DbReloader) Start() {
+	dr.Stop()
+
+	stop := make(chan struct{})
+
+	dr.mu.Lock()
+	dr.stop = stop
+	dr.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(dr.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				err := dr.Reload()
+
+				if err != nil {
+					log.Printf("ERROR: %s %s", util.WhereAmI(), err)
+				}
+			}
+		}
+	}()
+}
+
+//
+func (dr *DbReloader) Stop() {
+	dr.mu.Lock()
+	defer dr.mu.Unlock()
+
+	if dr.stop != nil {
+		close(dr.stop)
+		dr.stop = nil
+	}
+}
+
+//
+func (dr *DbReloader) Reload() error {
+
+	switch dr.Source {
+	case DbReloadSourceFile:
+		return dr.ReloadFromFile()
+	case DbReloadSourceShards:
+		return dr.ReloadFromShards()
+	}
+
+	return errors.New(fmt.Sprintf("Unknown DB reload source: %s", dr.Source))
+}
+
+// Reload when the config file changes
+func (dr *DbReloader) ReloadFromFile() error {
+	info, err := os.Stat(dr.ConfigPath)
+
+	if err != nil {
+		return err
+	}
+
+	if info.ModTime().Equal(dr.modTime) {
+		return nil
+	}
+
+	conf, err := config.NewFromFile(dr.ConfigPath)
+
+	if err != nil {
+		return err
+	}
+
+	err = dr.Db.Reload(conf.DbConns)
+
+	if err != nil {
+		return err
+	}
+
+	dr.modTime = info.ModTime()
+	dr.BaseConns = conf.DbConns
+
+	return nil
+}
+
+// Configured connections + every undeleted shard with a DSN. Pool options come from the first configured connection.
+func (dr *DbReloader) ReloadFromShards() error {
+	var shards []struct {
+		Name sql.NullString `db:"name"`
+		Dsn  sql.NullString `db:"dsn"`
+	}
+
+	dbConn, err := dr.Db.GetRandomConn()
+
+	if err != nil {
+		return err
+	}
+
+	_, err = dbConn.NewSession(nil).SelectBySql(`
+	SELECT
+		name,
+		dsn
+	FROM system.shards
+	WHERE deleted_at IS NULL
+		AND dsn IS NOT NULL
+		AND dsn <> ''`).Load(&shards)
+
+	if err != nil {
+		return err
+	}
+
+	dbConns := append([]config.DbConnOptions{}, dr.BaseConns...)
+	names := make(map[string]bool)
+	template := config.DbConnOptions{}
+
+	for _, connInfo := range dr.BaseConns {
+		names[connInfo.ShardName] = true
+	}
+
+	if len(dr.BaseConns) > 0 {
+		template = dr.BaseConns[0]
+		template.Replicas = nil
+	}
+
+	for _, shard := range shards {
+		if names[shard.Name.String] {
+			continue
+		}
+
+		connInfo := template
+		connInfo.ShardName = shard.Name.String
+		connInfo.Dsn = shard.Dsn.String
+
+		dbConns = append(dbConns, connInfo)
+	}
+
+	return dr.Db.Reload(dbConns)
+}
@@ -27,7 +27,7 @@ import (
 	"html/template"
 	"github.com/gocraft/web"
 	"github.com/jschneider98/jgoweb/util"
-	"github.com/jschneider98/medex/models"
+	"` + mg.GetModelsPackage() + `"
 )
 `
 	return code
@@ -0,0 +1,56 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jschneider98/jgoweb/config"
+	"github.com/jschneider98/jgoweb/util"
+	"path/filepath"
+)
+
+// File kinds generated for a table (in generation order)
+var GeneratedFileKinds = []string{"model", "test", "controller", "list_controller", "view", "list_view"}
+
+// Generated files for the table, laid out in the configured dirs (i.e., models/user.go, web/user_list.go,
+// templates/user.html). kinds defaults to GeneratedFileKinds.
+func (mg *ModelGenerator) GetFiles(opts config.GeneratorOptions, kinds ...string) ([]*GeneratedFile, error) {
+	var files []*GeneratedFile
+
+	if len(kinds) == 0 {
+		kinds = GeneratedFileKinds
+	}
+
+	name := util.ToSnakeCase(mg.ModelName)
+
+	for _, kind := range kinds {
+		var path string
+		var code string
+
+		switch kind {
+		case "model":
+			path, code = filepath.Join(opts.ModelDir, name+".go"), mg.Generate()
+		case "test":
+			path, code = filepath.Join(opts.ModelDir, name+"_test.go"), mg.GenerateTest()
+		case "controller":
+			path, code = filepath.Join(opts.ControllerDir, name+".go"), mg.GenerateController()
+		case "list_controller":
+			path, code = filepath.Join(opts.ControllerDir, name+"_list.go"), mg.GenerateListController()
+		case "view":
+			path, code = filepath.Join(opts.ViewDir, name+".html"), mg.GenerateView()
+		case "list_view":
+			path, code = filepath.Join(opts.ViewDir, name+"_list.html"), mg.GenerateListView()
+		default:
+			return nil, errors.New(fmt.Sprintf("Unknown file kind: %s", kind))
+		}
+
+		file, err := NewGeneratedFile(path, code)
+
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files, file)
+	}
+
+	return files, nil
+}
@@ -0,0 +1,73 @@
+// +build unit
+
+package generator
+
+import (
+	"github.com/gocraft/dbr"
+	"github.com/jschneider98/jgomodel"
+	"github.com/jschneider98/jgoweb/config"
+	"github.com/jschneider98/jgoweb/db/psql"
+	"strings"
+	"testing"
+)
+
+//
+func getTestModelGenerator() *ModelGenerator {
+	fields := []psql.Field{
+		{DbFieldName: "id", DbDataType: "uuid", DbDefault: dbr.NewNullString("gen_random_uuid()"), NotNull: true},
+		{DbFieldName: "account_id", DbDataType: "uuid", NotNull: true},
+		{DbFieldName: "name", DbDataType: "text", NotNull: true},
+		{DbFieldName: "created_at", DbDataType: "timestamp with time zone", DbDefault: dbr.NewNullString("now()"), NotNull: true},
+		{DbFieldName: "updated_at", DbDataType: "timestamp with time zone"},
+		{DbFieldName: "deleted_at", DbDataType: "timestamp with time zone"},
+	}
+
+	for key := range fields {
+		fields[key].SetStructVals()
+	}
+
+	mg := &ModelGenerator{Model: &jgomodel.Model{Schema: "public", Table: "widgets", FullTableName: "public.widgets", Fields: fields}, Fields: fields}
+	mg.TrimSuffix = "s"
+	mg.MakeModelName()
+	mg.MakeInstanceName()
+	mg.MakeStructInstanceName()
+
+	return mg
+}
+
+//
+func TestModelGeneratorGetFiles(t *testing.T) {
+	mg := getTestModelGenerator()
+	mg.ModelsPackage = "example.com/app/models"
+
+	opts := config.GeneratorOptions{ModelDir: "models", ControllerDir: "web", ViewDir: "templates"}
+
+	files, err := mg.GetFiles(opts)
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+		return
+	}
+
+	var paths []string
+
+	for _, file := range files {
+		paths = append(paths, file.Path)
+	}
+
+	expected := "models/widget.go,models/widget_test.go,web/widget.go,web/widget_list.go,templates/widget.html,templates/widget_list.html"
+
+	if strings.Join(paths, ",") != expected {
+		t.Errorf("\nERROR: Unexpected paths\nExpected: %s\nResult: %s\n", expected, strings.Join(paths, ","))
+	}
+
+	if !strings.Contains(files[2].Code, `"example.com/app/models"`) {
+		t.Errorf("\nERROR: Expected models import:\n%s\n", files[2].Code)
+	}
+
+	_, err = mg.GetFiles(opts, "bogus")
+
+	if err == nil {
+		t.Errorf("\nERROR: Expected unknown kind error\n")
+	}
+}
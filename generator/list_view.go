@@ -92,12 +92,12 @@ func (mg *ModelGenerator) GetListViewBodyCode() string {
 					<td class="no-print">
 						<div style="min-width: 50px;">
 							<span class="text-primary">
-								<a :href="getEditRoute(item.Id.String)"><i class="fa fa-edit fa-lg"></i></a>
-								<a href="#"><i v-on:click="deleteConfirm(item.Id.String)" class="fa fa-trash fa-lg"></i></a>
+								<a :href="getEditRoute(item.Id.{{{ .Mg.GetNullValueField .Mg.GetIdField }}})"><i class="fa fa-edit fa-lg"></i></a>
+								<a href="#"><i v-on:click="deleteConfirm(item.Id.{{{ .Mg.GetNullValueField .Mg.GetIdField }}})" class="fa fa-trash fa-lg"></i></a>
 							</span>
 						</div>
 					</td>{{{range $val := .Mg.Fields}}}
-					<td>{{ item.{{{ $val.FieldName }}}.String }}</td>{{{end}}}
+					<td>{{ item.{{{ $val.FieldName }}}{{{ with $.Mg.GetNullValueField $val }}}.{{{ . }}}{{{ end }}} }}</td>{{{end}}}
 				</tr>
 
 			</tbody>
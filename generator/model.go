@@ -6,14 +6,20 @@ import (
 	"github.com/jschneider98/jgoweb"
 	"github.com/jschneider98/jgoweb/db/psql"
 	"github.com/jschneider98/jgoweb/util"
+	"net/url"
+	"strconv"
 	"strings"
 )
 
+// Default import path of the generated models (used by the generated controllers)
+const DefaultModelsPackage = "github.com/jschneider98/medex/models"
+
 type ModelGenerator struct {
 	ModelName     string `json:"model_name"`
 	InstanceName  string `json:"instance_name"`
 	TrimSuffix    string
 	StructAcronym string
+	ModelsPackage string
 	Model         *jgomodel.Model
 	Fields        []psql.Field
 }
@@ -70,6 +76,16 @@ func (mg *ModelGenerator) MakeStructInstanceName() {
 	mg.StructAcronym = util.ToLowerAcronym(mg.ModelName)
 }
 
+//
+func (mg *ModelGenerator) GetModelsPackage() string {
+
+	if mg.ModelsPackage == "" {
+		return DefaultModelsPackage
+	}
+
+	return mg.ModelsPackage
+}
+
 //
 func (mg *ModelGenerator) Generate() string {
 	var code string
@@ -97,18 +113,63 @@ func (mg *ModelGenerator) Generate() string {
 	return code
 }
 
-//
+// Only the packages the field types need (unused imports don't compile)
 func (mg *ModelGenerator) GetImportCode() string {
-	return `package models
+	var imports []string
 
-import(
-	"time"
-	"database/sql"
-	"github.com/gocraft/web"
-	"github.com/jschneider98/jgoweb"
-	"github.com/jschneider98/jgoweb/util"
-)
-`
+	if mg.UsesTime() {
+		imports = append(imports, "time")
+	}
+
+	for _, pkg := range []string{"database/sql", "encoding/json", "github.com/lib/pq"} {
+		if mg.UsesPackage(pkg) {
+			imports = append(imports, pkg)
+		}
+	}
+
+	imports = append(imports, "github.com/gocraft/dbr", "github.com/gocraft/web", "github.com/jschneider98/jgoweb", "github.com/jschneider98/jgoweb/util")
+
+	return "package models\n\n" + GetImportBlock(imports)
+}
+
+//
+func GetImportBlock(imports []string) string {
+	code := "import(\n"
+
+	for _, pkg := range imports {
+		code += fmt.Sprintf("\t\"%s\"\n", pkg)
+	}
+
+	return code + ")\n"
+}
+
+// Package used by a field's struct type or Get/Set type
+func (mg *ModelGenerator) UsesPackage(pkg string) bool {
+
+	for _, field := range mg.Fields {
+		switch {
+		case pkg == "database/sql" && strings.HasPrefix(field.DataType, "sql."):
+			return true
+		case pkg == "encoding/json" && field.DataType == "util.NullRawMessage":
+			return true
+		case pkg == "github.com/lib/pq" && strings.HasPrefix(field.DataType, "pq."):
+			return true
+		}
+	}
+
+	return false
+}
+
+// time fields, or time.Now() for timestamps and soft deletes
+func (mg *ModelGenerator) UsesTime() bool {
+
+	for _, field := range mg.Fields {
+		if mg.IsTimeType(field) || field.FieldName == "CreatedAt" || field.FieldName == "UpdatedAt" {
+			return true
+		}
+	}
+
+	return mg.IsSoftDelete()
 }
 
 //
@@ -119,8 +180,7 @@ func (mg *ModelGenerator) GetStructCode() string {
 	code += fmt.Sprintf("type %s struct {\n", mg.ModelName)
 
 	for key := range mg.Fields {
-		// code += fmt.Sprintf("\t%s %s %s\n", mg.Fields[key].FieldName, mg.Fields[key].DataType, mg.Fields[key].Annotation)
-		code += fmt.Sprintf("\t%s %s %s\n", mg.Fields[key].FieldName, "sql.NullString", mg.Fields[key].Annotation)
+		code += fmt.Sprintf("\t%s %s %s\n", mg.Fields[key].FieldName, mg.Fields[key].DataType, mg.Fields[key].Annotation)
 	}
 
 	code += "\tCtx jgoweb.ContextInterface `json:\"-\" validate:\"-\"`\n"
@@ -184,12 +244,16 @@ func (mg *ModelGenerator) GetSetDefaultCode() string {
 	var code string
 	var defaults string
 
-	for key := range mg.Fields {
+	for _, field := range mg.Fields {
+
+		if field.FieldName == "CreatedAt" || field.FieldName == "UpdatedAt" || (mg.IsTimeType(field) && mg.IsNowDefault(field)) {
+			defaults += fmt.Sprintf("\t%s.Set%s(%s)\n", mg.StructAcronym, field.FieldName, mg.GetNowCode(field))
+		} else if field.DbDefault.Valid && !field.DbDefaultIsFunc {
+			literal := mg.GetLiteralCode(field, field.Default)
 
-		if mg.Fields[key].DbDefault.Valid && !mg.Fields[key].DbDefaultIsFunc {
-			defaults += fmt.Sprintf("\t%s.Set%s(\"%s\")\n", mg.StructAcronym, mg.Fields[key].FieldName, mg.Fields[key].Default)
-		} else if mg.Fields[key].FieldName == "CreatedAt" || mg.Fields[key].FieldName == "UpdatedAt" {
-			defaults += fmt.Sprintf("\t%s.Set%s(%s)\n", mg.StructAcronym, mg.Fields[key].FieldName, " time.Now().Format(time.RFC3339) ")
+			if literal != "" {
+				defaults += fmt.Sprintf("\t%s.Set%s(%s)\n", mg.StructAcronym, field.FieldName, literal)
+			}
 		}
 	}
 
@@ -304,34 +368,8 @@ func (mg *ModelGenerator) GetHydrateCode() string {
 	var code string
 	assignments := ""
 
-	for key := range mg.Fields {
-
-		assignments += fmt.Sprintf("\t%s.Set%s(req.PostFormValue(\"%s\"))\n", mg.StructAcronym, mg.Fields[key].FieldName, mg.Fields[key].FieldName)
-
-		// fieldName := mg.Fields[key].FieldName
-
-		// switch mg.Fields[key].DataType {
-		// case "sql.NullString":
-		// 	assignments += fmt.Sprintf("\tif %sHydrator.%s != \"\" {\n", mg.InstanceName, fieldName)
-		// 	assignments += fmt.Sprintf("\t\t%s.%s.String = %sHydrator.%s\n", mg.InstanceName, fieldName, mg.InstanceName, fieldName)
-		// 	assignments += fmt.Sprintf("\t\t%s.%s.Valid = true\n", mg.InstanceName, fieldName)
-		// 	assignments += fmt.Sprintf("\t}\n\n")
-		// case "sql.NullInt64":
-		// 	assignments += fmt.Sprintf("\tif %sHydrator.%s != \"\" {\n", mg.InstanceName, fieldName)
-		// 	assignments += fmt.Sprintf("\t%s.%s.Int64 = int64(%sHydrator.%s)\n", mg.InstanceName, fieldName, mg.InstanceName, fieldName)
-		// 	assignments += fmt.Sprintf("\t%s.%s.Valid = true\n", mg.InstanceName, fieldName)
-		// 	assignments += fmt.Sprintf("\t}\n\n")
-		// case "slq.NullBool":
-		// 	assignments += fmt.Sprintf("\tif %sHydrator.%s != \"\" {\n", mg.InstanceName, fieldName)
-		// 	assignments += fmt.Sprintf("\t%s.%s.Bool = bool(%sHydrator.%s)\n", mg.InstanceName, fieldName, mg.InstanceName, fieldName)
-		// 	assignments += fmt.Sprintf("\t%s.%s.Valid = true\n", mg.InstanceName, fieldName)
-		// 	assignments += fmt.Sprintf("\t}\n\n")
-		// case "slq.NullFloat64":
-		// 	assignments += fmt.Sprintf("\tif %sHydrator.%s != \"\" {\n", mg.InstanceName, fieldName)
-		// 	assignments += fmt.Sprintf("\t%s.%s.Float64 = float64(%sHydrator.%s)\n", mg.InstanceName, fieldName, mg.InstanceName, fieldName)
-		// 	assignments += fmt.Sprintf("\t%s.%s.Valid = true\n\n", mg.InstanceName, fieldName)
-		// 	assignments += fmt.Sprintf("\t}\n\n")
-		// }
+	for _, field := range mg.Fields {
+		assignments += mg.GetHydrateFieldCode(field)
 	}
 
 	code += fmt.Sprintf(`
@@ -351,6 +389,41 @@ func (%s *%s) Hydrate(req *web.Request) error {
 	return code
 }
 
+// Strings go through the setter. Other types are parsed (parse errors are returned).
+func (mg *ModelGenerator) GetHydrateFieldCode(field psql.Field) string {
+	fullFieldName := fmt.Sprintf("%s.%s", mg.StructAcronym, field.FieldName)
+	value := fmt.Sprintf("req.PostFormValue(\"%s\")", field.FieldName)
+	values := fmt.Sprintf("req.PostForm[\"%s\"]", field.FieldName)
+	var parse string
+
+	switch field.DataType {
+	case "sql.NullString":
+		return fmt.Sprintf("\t%s.Set%s(%s)\n", mg.StructAcronym, field.FieldName, value)
+	case "pq.StringArray":
+		return fmt.Sprintf("\t%s.Set%s(%s)\n", mg.StructAcronym, field.FieldName, values)
+	case "sql.NullInt64":
+		parse = "util.ParseNullInt64(" + value + ")"
+	case "sql.NullFloat64":
+		parse = "util.ParseNullFloat64(" + value + ")"
+	case "sql.NullBool":
+		parse = "util.ParseNullBool(" + value + ")"
+	case "time.Time":
+		parse = "util.ParseTime(" + value + ")"
+	case "sql.NullTime":
+		parse = "util.ParseNullTime(" + value + ")"
+	case "util.NullRawMessage":
+		parse = "util.ParseNullRawMessage(" + value + ")"
+	case "pq.Int64Array":
+		parse = "util.ParseInt64Array(" + values + ")"
+	case "pq.Float64Array":
+		parse = "util.ParseFloat64Array(" + values + ")"
+	case "pq.BoolArray":
+		parse = "util.ParseBoolArray(" + values + ")"
+	}
+
+	return fmt.Sprintf("\t%s, err = %s\n\n\tif err != nil {\n\t\treturn err\n\t}\n\n", fullFieldName, parse)
+}
+
 //
 func (mg *ModelGenerator) GetIsValidCode() string {
 	var code string
@@ -386,11 +459,7 @@ func (~StructAcronym~ *~ModelName~) Save() error {
 		return err
 	}
 
-	if !~StructAcronym~.Id.Valid {
-		return ~StructAcronym~.Insert()
-	} else {
-		return ~StructAcronym~.Update()
-	}
+	return jgoweb.SaveInTransaction(~StructAcronym~.Ctx, &~StructAcronym~.Id.Valid, ~StructAcronym~.Insert, ~StructAcronym~.Update)
 }
 `, ph)
 
@@ -461,7 +530,7 @@ func (mg *ModelGenerator) GetUpdateCode() string {
 		}
 
 		if mg.Fields[key].FieldName == "UpdatedAt" {
-			updatedAt = fmt.Sprintf("%s.SetUpdatedAt( time.Now().Format(time.RFC3339) )", mg.StructAcronym)
+			updatedAt = fmt.Sprintf("%s.SetUpdatedAt(%s)", mg.StructAcronym, mg.GetNowCode(mg.Fields[key]))
 		}
 	}
 
@@ -548,7 +617,9 @@ func (~StructAcronym~ *~ModelName~) Delete() error {
 func (mg *ModelGenerator) GetSoftDeleteCode() string {
 	var code string
 	ph := make(map[string]string)
+	field, _ := mg.GetField("deleted_at")
 
+	ph["~Now~"] = mg.GetNowCode(field)
 	ph["~StructAcronym~"] = mg.StructAcronym
 	ph["~ModelName~"] = mg.ModelName
 	ph["~FullTableName~"] = mg.Model.FullTableName
@@ -561,7 +632,7 @@ func (~StructAcronym~ *~ModelName~) Delete() error {
 		return nil
 	}
 
-	~StructAcronym~.SetDeletedAt( (time.Now()).Format(time.RFC3339) )
+	~StructAcronym~.SetDeletedAt(~Now~)
 
 	_, err := ~StructAcronym~.Ctx.Update("~FullTableName~").
 		Set("deleted_at", ~StructAcronym~.DeletedAt).
@@ -582,19 +653,14 @@ func (~StructAcronym~ *~ModelName~) Delete() error {
 //
 func (mg *ModelGenerator) GetUndeleteCode() string {
 	var code string
-	softDelete := false
 	ph := make(map[string]string)
-
-	for key := range mg.Fields {
-		if mg.Fields[key].DbFieldName == "deleted_at" {
-			softDelete = true
-		}
-	}
+	field, softDelete := mg.GetField("deleted_at")
 
 	if !softDelete {
 		return ""
 	}
 
+	ph["~Zero~"] = mg.GetZeroCode(field)
 	ph["~StructAcronym~"] = mg.StructAcronym
 	ph["~ModelName~"] = mg.ModelName
 	ph["~FullTableName~"] = mg.Model.FullTableName
@@ -607,7 +673,7 @@ func (~StructAcronym~ *~ModelName~) Undelete() error {
 		return nil
 	}
 
-	~StructAcronym~.SetDeletedAt("")
+	~StructAcronym~.SetDeletedAt(~Zero~)
 
 	_, err := ~StructAcronym~.Ctx.Update("~FullTableName~").
 		Set("deleted_at", ~StructAcronym~.DeletedAt).
@@ -629,25 +695,29 @@ func (~StructAcronym~ *~ModelName~) Undelete() error {
 func (mg *ModelGenerator) GetSetterGetterCode() string {
 	var code string
 
-	for key := range mg.Fields {
+	for _, field := range mg.Fields {
 
-		code += mg.GetStringGetterCode(mg.Fields[key])
-		code += mg.GetStringSetterCode(mg.Fields[key])
-
-		// switch mg.Fields[key].DataType {
-		// case "sql.NullString":
-		// 	code += mg.GetStringGetterCode(mg.Fields[key])
-		// 	code += mg.GetStringSetterCode(mg.Fields[key])
-		// case "sql.NullInt64":
-		// 	code += mg.GetIntGetterCode(mg.Fields[key])
-		// 	code += mg.GetIntSetterCode(mg.Fields[key])
-		// case "sql.NullFloat64":
-		// 	code += mg.GetFloatGetterCode(mg.Fields[key])
-		// 	code += mg.GetFloatSetterCode(mg.Fields[key])
-		// case "sql.NullBool":
-		// 	code += mg.GetBoolGetterCode(mg.Fields[key])
-		// 	code += mg.GetBoolSetterCode(mg.Fields[key])
-		// }
+		switch field.DataType {
+		case "sql.NullString":
+			code += mg.GetStringGetterCode(field)
+			code += mg.GetStringSetterCode(field)
+		case "sql.NullInt64":
+			code += mg.GetIntGetterCode(field)
+			code += mg.GetIntSetterCode(field)
+		case "sql.NullFloat64":
+			code += mg.GetFloatGetterCode(field)
+			code += mg.GetFloatSetterCode(field)
+		case "sql.NullBool":
+			code += mg.GetBoolGetterCode(field)
+			code += mg.GetBoolSetterCode(field)
+		case "sql.NullTime", "util.NullRawMessage":
+			code += mg.GetNullGetterCode(field)
+			code += mg.GetNullSetterCode(field)
+		default:
+			// time.Time and arrays
+			code += mg.GetValueGetterCode(field)
+			code += mg.GetValueSetterCode(field)
+		}
 	}
 
 	return code
@@ -656,24 +726,19 @@ func (mg *ModelGenerator) GetSetterGetterCode() string {
 //
 func (mg *ModelGenerator) GetStringGetterCode(field psql.Field) string {
 	var code string
-	var slice string
 	fullFieldName := fmt.Sprintf("%s.%s", mg.StructAcronym, field.FieldName)
 
-	if field.DbDataType == "date" {
-		slice = "[0:10]"
-	}
-
 	code += fmt.Sprintf(`
 //
 func (%s *%s) Get%s() string {
 
 	if %s.Valid {
-		return %s.String%s
+		return %s.String
 	}
 
 	return ""
 }
-`, mg.StructAcronym, mg.ModelName, field.FieldName, fullFieldName, fullFieldName, slice)
+`, mg.StructAcronym, mg.ModelName, field.FieldName, fullFieldName, fullFieldName)
 
 	return code
 }
@@ -681,13 +746,8 @@ func (%s *%s) Get%s() string {
 //
 func (mg *ModelGenerator) GetStringSetterCode(field psql.Field) string {
 	var code string
-	var slice string
 	fullFieldName := fmt.Sprintf("%s.%s", mg.StructAcronym, field.FieldName)
 
-	if field.DbDataType == "date" {
-		slice = "[0:10]"
-	}
-
 	code += fmt.Sprintf(`
 //
 func (%s *%s) Set%s(val string) {
@@ -700,9 +760,9 @@ func (%s *%s) Set%s(val string) {
 	}
 
 	%s.Valid = true
-	%s.String = val%s
+	%s.String = val
 }
-`, mg.StructAcronym, mg.ModelName, field.FieldName, fullFieldName, fullFieldName, fullFieldName, fullFieldName, slice)
+`, mg.StructAcronym, mg.ModelName, field.FieldName, fullFieldName, fullFieldName, fullFieldName, fullFieldName)
 
 	return code
 }
@@ -727,11 +787,21 @@ func (%s *%s) Get%s() int64 {
 	return code
 }
 
-//
+// Zero = null, unless the field is NOT NULL
 func (mg *ModelGenerator) GetIntSetterCode(field psql.Field) string {
 	var code string
 	fullFieldName := fmt.Sprintf("%s.%s", mg.StructAcronym, field.FieldName)
 
+	if field.NotNull {
+		return fmt.Sprintf(`
+//
+func (%s *%s) Set%s(val int64) {
+	%s.Valid = true
+	%s.Int64 = val
+}
+`, mg.StructAcronym, mg.ModelName, field.FieldName, fullFieldName, fullFieldName)
+	}
+
 	code += fmt.Sprintf(`
 //
 func (%s *%s) Set%s(val int64) {
@@ -771,11 +841,21 @@ func (%s *%s) Get%s() float64 {
 	return code
 }
 
-//
+// Zero = null, unless the field is NOT NULL
 func (mg *ModelGenerator) GetFloatSetterCode(field psql.Field) string {
 	var code string
 	fullFieldName := fmt.Sprintf("%s.%s", mg.StructAcronym, field.FieldName)
 
+	if field.NotNull {
+		return fmt.Sprintf(`
+//
+func (%s *%s) Set%s(val float64) {
+	%s.Valid = true
+	%s.Float64 = val
+}
+`, mg.StructAcronym, mg.ModelName, field.FieldName, fullFieldName, fullFieldName)
+	}
+
 	code += fmt.Sprintf(`
 //
 func (%s *%s) Set%s(val float64) {
@@ -834,6 +914,217 @@ func (%s *%s) Set%s(val bool) {
 	return code
 }
 
+// sql.NullTime and util.NullRawMessage. Zero value = null.
+func (mg *ModelGenerator) GetNullGetterCode(field psql.Field) string {
+	var code string
+	fullFieldName := fmt.Sprintf("%s.%s", mg.StructAcronym, field.FieldName)
+	valueField := mg.GetNullValueField(field)
+
+	code += fmt.Sprintf(`
+//
+func (%s *%s) Get%s() %s {
+
+	if %s.Valid {
+		return %s.%s
+	}
+
+	return %s
+}
+`, mg.StructAcronym, mg.ModelName, field.FieldName, mg.GetGoType(field), fullFieldName, fullFieldName, valueField, mg.GetZeroCode(field))
+
+	return code
+}
+
+//
+func (mg *ModelGenerator) GetNullSetterCode(field psql.Field) string {
+	var code string
+	fullFieldName := fmt.Sprintf("%s.%s", mg.StructAcronym, field.FieldName)
+	valueField := mg.GetNullValueField(field)
+	isZero := "val.IsZero()"
+
+	if field.DataType == "util.NullRawMessage" {
+		isZero = "len(val) == 0"
+	}
+
+	code += fmt.Sprintf(`
+//
+func (%s *%s) Set%s(val %s) {
+
+	if %s {
+		%s.Valid = false
+		%s.%s = %s
+
+		return
+	}
+
+	%s.Valid = true
+	%s.%s = val
+}
+`, mg.StructAcronym, mg.ModelName, field.FieldName, mg.GetGoType(field), isZero, fullFieldName, fullFieldName, valueField, mg.GetZeroCode(field), fullFieldName, fullFieldName, valueField)
+
+	return code
+}
+
+// time.Time and arrays
+func (mg *ModelGenerator) GetValueGetterCode(field psql.Field) string {
+	var code string
+
+	code += fmt.Sprintf(`
+//
+func (%s *%s) Get%s() %s {
+	return %s.%s
+}
+`, mg.StructAcronym, mg.ModelName, field.FieldName, mg.GetGoType(field), mg.StructAcronym, field.FieldName)
+
+	return code
+}
+
+//
+func (mg *ModelGenerator) GetValueSetterCode(field psql.Field) string {
+	var code string
+
+	code += fmt.Sprintf(`
+//
+func (%s *%s) Set%s(val %s) {
+	%s.%s = val
+}
+`, mg.StructAcronym, mg.ModelName, field.FieldName, mg.GetGoType(field), mg.StructAcronym, field.FieldName)
+
+	return code
+}
+
+// Get/Set type of a field
+func (mg *ModelGenerator) GetGoType(field psql.Field) string {
+
+	switch field.DataType {
+	case "sql.NullInt64":
+		return "int64"
+	case "sql.NullFloat64":
+		return "float64"
+	case "sql.NullBool":
+		return "bool"
+	case "time.Time", "sql.NullTime":
+		return "time.Time"
+	case "util.NullRawMessage":
+		return "json.RawMessage"
+	case "pq.StringArray":
+		return "[]string"
+	case "pq.Int64Array":
+		return "[]int64"
+	case "pq.Float64Array":
+		return "[]float64"
+	case "pq.BoolArray":
+		return "[]bool"
+	}
+
+	return "string"
+}
+
+// Struct field holding the value of a sql.Null* style type ("" for time.Time and arrays)
+func (mg *ModelGenerator) GetNullValueField(field psql.Field) string {
+
+	switch field.DataType {
+	case "sql.NullString":
+		return "String"
+	case "sql.NullInt64":
+		return "Int64"
+	case "sql.NullFloat64":
+		return "Float64"
+	case "sql.NullBool":
+		return "Bool"
+	case "sql.NullTime":
+		return "Time"
+	case "util.NullRawMessage":
+		return "RawMessage"
+	}
+
+	return ""
+}
+
+//
+func (mg *ModelGenerator) GetZeroCode(field psql.Field) string {
+
+	switch mg.GetGoType(field) {
+	case "string":
+		return `""`
+	case "int64", "float64":
+		return "0"
+	case "bool":
+		return "false"
+	case "time.Time":
+		return "time.Time{}"
+	}
+
+	return "nil"
+}
+
+//
+func (mg *ModelGenerator) IsTimeType(field psql.Field) bool {
+	return field.DataType == "time.Time" || field.DataType == "sql.NullTime"
+}
+
+// now(), CURRENT_TIMESTAMP, etc.
+func (mg *ModelGenerator) IsNowDefault(field psql.Field) bool {
+	def := strings.ToLower(field.DbDefault.String)
+
+	return strings.Contains(def, "now()") || strings.HasPrefix(def, "current_")
+}
+
+// Current time for time types, RFC3339 for strings
+func (mg *ModelGenerator) GetNowCode(field psql.Field) string {
+
+	if mg.IsTimeType(field) {
+		return "time.Now()"
+	}
+
+	return "time.Now().Format(time.RFC3339)"
+}
+
+// Go literal for a DB default value ("" if there isn't one for the type)
+func (mg *ModelGenerator) GetLiteralCode(field psql.Field, val string) string {
+	var err error
+
+	switch field.DataType {
+	case "sql.NullString":
+		return strconv.Quote(val)
+	case "sql.NullInt64":
+		_, err = strconv.ParseInt(val, 10, 64)
+	case "sql.NullFloat64":
+		_, err = strconv.ParseFloat(val, 64)
+	case "sql.NullBool":
+		_, err = strconv.ParseBool(val)
+	case "util.NullRawMessage":
+		return fmt.Sprintf("json.RawMessage(%s)", strconv.Quote(val))
+	default:
+		return ""
+	}
+
+	if err != nil {
+		return ""
+	}
+
+	return val
+}
+
+//
+func (mg *ModelGenerator) GetField(dbFieldName string) (psql.Field, bool) {
+
+	for _, field := range mg.Fields {
+		if field.DbFieldName == dbFieldName {
+			return field, true
+		}
+	}
+
+	return psql.Field{}, false
+}
+
+//
+func (mg *ModelGenerator) GetIdField() psql.Field {
+	field, _ := mg.GetField("id")
+
+	return field
+}
+
 //
 func (mg *ModelGenerator) IsHiddenField(fieldName string) bool {
 	return fieldName == "Id" || fieldName == "AccountId" || fieldName == "CreatedAt" || fieldName == "UpdatedAt" || fieldName == "DeletedAt"
@@ -864,18 +1155,157 @@ func (mg *ModelGenerator) GenerateTest() string {
 
 //
 func (mg *ModelGenerator) GetTestImportCode() string {
-	return `// +build integration
+	var imports []string
 
-package models
+	for _, pkg := range []string{"encoding/json", "time"} {
+		if pkg == "time" && mg.UsesTimeField() || pkg != "time" && mg.UsesPackage(pkg) {
+			imports = append(imports, pkg)
+		}
+	}
 
-import (
-	"github.com/gocraft/web"
-	"github.com/jschneider98/jgoweb"
-	"net/http"
-	"testing"
-	"strings"
-)
-`
+	// fmt.Sprint for arrays and non-string ids
+	if mg.UsesArray() || mg.GetGoType(mg.GetIdField()) != "string" {
+		imports = append(imports, "fmt")
+	}
+
+	imports = append(imports, "github.com/gocraft/web", "github.com/jschneider98/jgoweb", "net/http", "testing", "strings")
+
+	return "// +build integration\n\npackage models\n\n" + GetImportBlock(imports)
+}
+
+//
+func (mg *ModelGenerator) UsesTimeField() bool {
+
+	for _, field := range mg.Fields {
+		if mg.IsTimeType(field) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//
+func (mg *ModelGenerator) UsesArray() bool {
+	return mg.UsesPackage("github.com/lib/pq")
+}
+
+// Fetch*ById takes a string id
+func (mg *ModelGenerator) GetTestIdCode(getId string) string {
+	if mg.GetGoType(mg.GetIdField()) == "string" {
+		return getId
+	}
+
+	return fmt.Sprintf("fmt.Sprint(%s)", getId)
+}
+
+// Value for tests. label: "test" (setter/getter tests), "Insert" or "Update" (Update gets a second value)
+func (mg *ModelGenerator) GetTestValueCode(field psql.Field, label string) string {
+	second := label == "Update"
+
+	switch mg.GetGoType(field) {
+	case "int64":
+		return map[bool]string{false: "int64(1)", true: "int64(2)"}[second]
+	case "float64":
+		return map[bool]string{false: "float64(1.5)", true: "float64(2.5)"}[second]
+	case "bool":
+		return map[bool]string{false: "true", true: "false"}[second]
+	case "time.Time":
+		return map[bool]string{false: "time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)", true: "time.Date(2020, 1, 3, 0, 0, 0, 0, time.UTC)"}[second]
+	case "json.RawMessage":
+		return map[bool]string{false: `json.RawMessage("[1]")`, true: `json.RawMessage("[2]")`}[second]
+	case "[]string":
+		return fmt.Sprintf("[]string{%s}", strconv.Quote(label))
+	case "[]int64":
+		return map[bool]string{false: "[]int64{1}", true: "[]int64{2}"}[second]
+	case "[]float64":
+		return map[bool]string{false: "[]float64{1.5}", true: "[]float64{2.5}"}[second]
+	case "[]bool":
+		return map[bool]string{false: "[]bool{true}", true: "[]bool{false}"}[second]
+	}
+
+	if field.IsEnum && len(field.EnumValues) > 0 {
+		if second {
+			return strconv.Quote(field.EnumValues[len(field.EnumValues)-1])
+		}
+
+		return strconv.Quote(field.EnumValues[0])
+	}
+
+	if label == "test" {
+		return `"test"`
+	}
+
+	return strconv.Quote(field.FieldName + " " + label)
+}
+
+// Posted form value for tests
+func (mg *ModelGenerator) GetTestFormValue(field psql.Field) string {
+	var val string
+
+	switch mg.GetGoType(field) {
+	case "int64", "[]int64":
+		val = "1"
+	case "float64", "[]float64":
+		val = "1.5"
+	case "bool", "[]bool":
+		val = "true"
+	case "time.Time":
+		val = "2020-01-02T00:00:00Z"
+	case "json.RawMessage":
+		val = "[1]"
+	case "[]string":
+		val = "test"
+	default:
+		val = field.FieldName
+
+		if field.IsEnum && len(field.EnumValues) > 0 {
+			val = field.EnumValues[0]
+		}
+	}
+
+	return url.QueryEscape(val)
+}
+
+// Comparison that fails when a and b differ (times from the DB have a different location, slices aren't comparable)
+func (mg *ModelGenerator) GetTestNotEqualCode(field psql.Field, a string, b string) string {
+
+	switch mg.GetGoType(field) {
+	case "time.Time":
+		return fmt.Sprintf("!%s.Equal(%s)", a, b)
+	case "json.RawMessage":
+		return fmt.Sprintf("string(%s) != string(%s)", a, b)
+	case "[]string", "[]int64", "[]float64", "[]bool":
+		return fmt.Sprintf("fmt.Sprint(%s) != fmt.Sprint(%s)", a, b)
+	}
+
+	return fmt.Sprintf("%s != %s", a, b)
+}
+
+//
+func (mg *ModelGenerator) GetTestNotZeroCode(field psql.Field, val string) string {
+
+	switch mg.GetGoType(field) {
+	case "time.Time":
+		return fmt.Sprintf("!%s.IsZero()", val)
+	case "json.RawMessage":
+		return fmt.Sprintf("len(%s) != 0", val)
+	}
+
+	return fmt.Sprintf("%s != %s", val, mg.GetZeroCode(field))
+}
+
+// Setter can set the field to null (see GetNullSetterCode, GetIntSetterCode, etc.)
+func (mg *ModelGenerator) HasNullSetter(field psql.Field) bool {
+
+	switch field.DataType {
+	case "sql.NullString", "sql.NullTime", "util.NullRawMessage":
+		return true
+	case "sql.NullInt64", "sql.NullFloat64":
+		return !field.NotNull
+	}
+
+	return false
 }
 
 //
@@ -885,6 +1315,7 @@ func (mg *ModelGenerator) GetTestFetchByIdCode() string {
 
 	ph["~StructAcronym~"] = mg.StructAcronym
 	ph["~ModelName~"] = mg.ModelName
+	ph["~MockId~"] = mg.GetTestIdCode("Mock" + mg.ModelName + ".GetId()")
 
 	code += util.NamedSprintf(`
 //
@@ -906,7 +1337,7 @@ func TestFetch~ModelName~ById(t *testing.T) {
 		return
 	}
 
-	~StructAcronym~, err = Fetch~ModelName~ById(jgoweb.MockCtx, Mock~ModelName~.GetId())
+	~StructAcronym~, err = Fetch~ModelName~ById(jgoweb.MockCtx, ~MockId~)
 
 	if err != nil {
 		t.Errorf("\nERROR: %%v\n", err)
@@ -933,46 +1364,63 @@ func (mg *ModelGenerator) GetTestSetterGetterCode() string {
 	code := ""
 
 	for _, field := range mg.Fields {
-		code += mg.GetTestSetterGetterByField(field.FieldName)
+		code += mg.GetTestSetterGetterByField(field)
 	}
 
 	return code
 }
 
 //
-func (mg *ModelGenerator) GetTestSetterGetterByField(fieldName string) string {
+func (mg *ModelGenerator) GetTestSetterGetterByField(field psql.Field) string {
 	var code string
 	ph := make(map[string]string)
 
 	ph["~StructAcronym~"] = mg.StructAcronym
 	ph["~ModelName~"] = mg.ModelName
-	ph["~FieldName~"] = fieldName
+	ph["~FieldName~"] = field.FieldName
+	ph["~TestVal~"] = mg.GetTestValueCode(field, "test")
+	ph["~NotEqual~"] = mg.GetTestNotEqualCode(field, "Mock"+mg.ModelName+".Get"+field.FieldName+"()", "testVal")
+	ph["~NullTest~"] = ""
+	ph["~ValidTest~"] = ""
 
-	code += util.NamedSprintf(`
-//
-func Test~ModelName~~FieldName~(t *testing.T) {
-	InitMock~ModelName~()
-	origVal := Mock~ModelName~.Get~FieldName~()
-	testVal := "test"
-
-	Mock~ModelName~.Set~FieldName~("")
+	if mg.HasNullSetter(field) {
+		ph["~NullTest~"] = util.NamedSprintf(`
+	Mock~ModelName~.Set~FieldName~(~Zero~)
 
 	if Mock~ModelName~.~FieldName~.Valid {
 		t.Errorf("ERROR: ~FieldName~ should be invalid.\n")
 	}
 
-	if Mock~ModelName~.Get~FieldName~() != "" {
-		t.Errorf("ERROR: Set ~FieldName~ failed. Should have a blank value. Got: %%s", Mock~ModelName~.Get~FieldName~())
+	if ~NotZero~ {
+		t.Errorf("ERROR: Set ~FieldName~ failed. Should have a blank value. Got: %%v", Mock~ModelName~.Get~FieldName~())
+	}
+`, map[string]string{
+			"~ModelName~": mg.ModelName,
+			"~FieldName~": field.FieldName,
+			"~Zero~":      mg.GetZeroCode(field),
+			"~NotZero~":   mg.GetTestNotZeroCode(field, "Mock"+mg.ModelName+".Get"+field.FieldName+"()"),
+		})
 	}
 
-	Mock~ModelName~.Set~FieldName~(testVal)
-
+	if strings.HasPrefix(field.DataType, "sql.") || field.DataType == "util.NullRawMessage" {
+		ph["~ValidTest~"] = util.NamedSprintf(`
 	if !Mock~ModelName~.~FieldName~.Valid {
 		t.Errorf("ERROR: ~FieldName~ should be valid.\n")
 	}
+`, map[string]string{"~ModelName~": mg.ModelName, "~FieldName~": field.FieldName})
+	}
 
-	if Mock~ModelName~.Get~FieldName~() != testVal {
-		t.Errorf("ERROR: Set ~FieldName~ failed. Expected: %%s, Got: %%s", testVal, Mock~ModelName~.Get~FieldName~())
+	code += util.NamedSprintf(`
+//
+func Test~ModelName~~FieldName~(t *testing.T) {
+	InitMock~ModelName~()
+	origVal := Mock~ModelName~.Get~FieldName~()
+	testVal := ~TestVal~
+~NullTest~
+	Mock~ModelName~.Set~FieldName~(testVal)
+~ValidTest~
+	if ~NotEqual~ {
+		t.Errorf("ERROR: Set ~FieldName~ failed. Expected: %%v, Got: %%v", testVal, Mock~ModelName~.Get~FieldName~())
 	}
 
 	Mock~ModelName~.Set~FieldName~(origVal)
@@ -997,13 +1445,14 @@ func (mg *ModelGenerator) GetTestInsertCode() string {
 	ph["~SetSaveVals~"] = ""
 	ph["~Setters~"] = ""
 	ph["~Assert~"] = fmt.Sprintf("%s == nil", mg.StructAcronym)
+	ph["~Id~"] = mg.GetTestIdCode(mg.StructAcronym + ".GetId()")
 
 	for _, field := range mg.Fields {
 
 		if !mg.IsTimestamp(field.FieldName) && field.FieldName != "Id" {
-			ph["~SetSaveVals~"] += fmt.Sprintf("%s := \"%s Insert\"\n\t", field.FieldName, field.FieldName)
+			ph["~SetSaveVals~"] += fmt.Sprintf("%s := %s\n\t", field.FieldName, mg.GetTestValueCode(field, "Insert"))
 			ph["~Setters~"] += fmt.Sprintf("%s.Set%s(%s)\n\t", mg.StructAcronym, field.FieldName, field.FieldName)
-			ph["~Assert~"] += fmt.Sprintf(" || %s.Get%s() != %s", mg.StructAcronym, field.FieldName, field.FieldName)
+			ph["~Assert~"] += " || " + mg.GetTestNotEqualCode(field, fmt.Sprintf("%s.Get%s()", mg.StructAcronym, field.FieldName), field.FieldName)
 		}
 	}
 
@@ -1030,7 +1479,7 @@ func Test~ModelName~Insert(t *testing.T) {
 	}
 
 	// verify write
-	~StructAcronym~, err = Fetch~ModelName~ById(jgoweb.MockCtx, ~StructAcronym~.GetId())
+	~StructAcronym~, err = Fetch~ModelName~ById(jgoweb.MockCtx, ~Id~)
 
 	if err != nil {
 		t.Errorf("\nERROR: %%v\n", err)
@@ -1052,6 +1501,7 @@ func (mg *ModelGenerator) GetTestUpdateCode() string {
 
 	ph["~StructAcronym~"] = mg.StructAcronym
 	ph["~ModelName~"] = mg.ModelName
+	ph["~MockId~"] = mg.GetTestIdCode("Mock" + mg.ModelName + ".GetId()")
 	ph["~SetSaveVals~"] = ""
 	ph["~Setters~"] = ""
 	ph["~Assert~"] = fmt.Sprintf("%s == nil", mg.StructAcronym)
@@ -1059,9 +1509,9 @@ func (mg *ModelGenerator) GetTestUpdateCode() string {
 	for _, field := range mg.Fields {
 
 		if !mg.IsTimestamp(field.FieldName) && field.FieldName != "Id" {
-			ph["~SetSaveVals~"] += fmt.Sprintf("%s := \"%s Update\"\n\t", field.FieldName, field.FieldName)
+			ph["~SetSaveVals~"] += fmt.Sprintf("%s := %s\n\t", field.FieldName, mg.GetTestValueCode(field, "Update"))
 			ph["~Setters~"] += fmt.Sprintf("Mock%s.Set%s(%s)\n\t", mg.ModelName, field.FieldName, field.FieldName)
-			ph["~Assert~"] += fmt.Sprintf(" || %s.Get%s() != %s", mg.StructAcronym, field.FieldName, field.FieldName)
+			ph["~Assert~"] += " || " + mg.GetTestNotEqualCode(field, fmt.Sprintf("%s.Get%s()", mg.StructAcronym, field.FieldName), field.FieldName)
 		}
 	}
 
@@ -1078,7 +1528,7 @@ func Test~ModelName~Update(t *testing.T) {
 	}
 
 	// verify write
-	~StructAcronym~, err := Fetch~ModelName~ById(jgoweb.MockCtx, Mock~ModelName~.GetId())
+	~StructAcronym~, err := Fetch~ModelName~ById(jgoweb.MockCtx, ~MockId~)
 
 	if err != nil {
 		t.Errorf("\nERROR: %%v\n", err)
@@ -1110,6 +1560,7 @@ func (mg *ModelGenerator) GetTestSoftDeleteCode() string {
 
 	ph["~StructAcronym~"] = mg.StructAcronym
 	ph["~ModelName~"] = mg.ModelName
+	ph["~MockId~"] = mg.GetTestIdCode("Mock" + mg.ModelName + ".GetId()")
 
 	code += util.NamedSprintf(`
 //
@@ -1122,7 +1573,7 @@ func Test~ModelName~Delete(t *testing.T) {
 	}
 
 	// verify write
-	~StructAcronym~, err := Fetch~ModelName~ById(jgoweb.MockCtx, Mock~ModelName~.GetId())
+	~StructAcronym~, err := Fetch~ModelName~ById(jgoweb.MockCtx, ~MockId~)
 
 	if err != nil {
 		t.Errorf("\nERROR: %%v\n", err)
@@ -1144,6 +1595,7 @@ func (mg *ModelGenerator) GetTestHardDeleteCode() string {
 
 	ph["~StructAcronym~"] = mg.StructAcronym
 	ph["~ModelName~"] = mg.ModelName
+	ph["~MockId~"] = mg.GetTestIdCode("Mock" + mg.ModelName + ".GetId()")
 
 	code += util.NamedSprintf(`
 //
@@ -1156,7 +1608,7 @@ func Test~ModelName~Delete(t *testing.T) {
 	}
 
 	// verify write
-	~StructAcronym~, err := Fetch~ModelName~ById(jgoweb.MockCtx, Mock~ModelName~.GetId())
+	~StructAcronym~, err := Fetch~ModelName~ById(jgoweb.MockCtx, ~MockId~)
 
 	if err != nil {
 		t.Errorf("\nERROR: %%v\n", err)
@@ -1181,6 +1633,7 @@ func (mg *ModelGenerator) GetTestUndeleteCode() string {
 
 	ph["~StructAcronym~"] = mg.StructAcronym
 	ph["~ModelName~"] = mg.ModelName
+	ph["~MockId~"] = mg.GetTestIdCode("Mock" + mg.ModelName + ".GetId()")
 
 	code += util.NamedSprintf(`
 //
@@ -1199,7 +1652,7 @@ func Test~ModelName~Undelete(t *testing.T) {
 	}
 
 	// verify write
-	~StructAcronym~, err := Fetch~ModelName~ById(jgoweb.MockCtx, Mock~ModelName~.GetId())
+	~StructAcronym~, err := Fetch~ModelName~ById(jgoweb.MockCtx, ~MockId~)
 
 	if err != nil {
 		t.Errorf("\nERROR: %%v\n", err)
@@ -1256,7 +1709,7 @@ func (mg *ModelGenerator) GetTestProcessSubmitCode() string {
 	for _, field := range mg.Fields {
 
 		if !mg.IsTimestamp(field.FieldName) && field.FieldName != "Id" {
-			ph["~PostVals~"] += fmt.Sprintf("&%s=%s", field.FieldName, field.FieldName)
+			ph["~PostVals~"] += fmt.Sprintf("&%s=%s", field.FieldName, mg.GetTestFormValue(field))
 		}
 	}
 
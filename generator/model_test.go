@@ -1,23 +1,132 @@
-// +build integration
+// +build unit
 
 package generator
 
 import (
-	"fmt"
-	"github.com/jschneider98/jgoweb"
+	"github.com/gocraft/dbr"
+	"github.com/jschneider98/jgomodel"
+	"github.com/jschneider98/jgoweb/db/psql"
+	"strings"
 	"testing"
 )
 
 //
-func TestGenerateTest(t *testing.T) {
-	mg, err := NewModelGenerator(jgoweb.MockCtx, "public", "users", "s")
+func getTypedTestModelGenerator() *ModelGenerator {
+	fields := []psql.Field{
+		{DbFieldName: "id", DbDataType: "bigint", DbDefault: dbr.NewNullString("nextval('public.gadgets_id_seq'::regclass)"), NotNull: true},
+		{DbFieldName: "status", DbDataType: "gadget_status", DbDefault: dbr.NewNullString("'draft'::gadget_status"), NotNull: true, IsEnum: true, EnumValues: []string{"draft", "live"}},
+		{DbFieldName: "qty", DbDataType: "integer", DbDefault: dbr.NewNullString("0"), NotNull: true},
+		{DbFieldName: "price", DbDataType: "double precision"},
+		{DbFieldName: "active", DbDataType: "boolean", NotNull: true},
+		{DbFieldName: "starts_on", DbDataType: "date"},
+		{DbFieldName: "published_at", DbDataType: "timestamp with time zone", DbDefault: dbr.NewNullString("now()"), NotNull: true},
+		{DbFieldName: "data", DbDataType: "jsonb"},
+		{DbFieldName: "tags", DbDataType: "text[]"},
+		{DbFieldName: "deleted_at", DbDataType: "timestamp with time zone"},
+	}
+
+	for key := range fields {
+		fields[key].SetStructVals()
+	}
+
+	mg := &ModelGenerator{Model: &jgomodel.Model{Schema: "public", Table: "gadgets", FullTableName: "public.gadgets", Fields: fields}, Fields: fields}
+	mg.TrimSuffix = "s"
+	mg.MakeModelName()
+	mg.MakeInstanceName()
+	mg.MakeStructInstanceName()
+
+	return mg
+}
+
+//
+func TestModelGeneratorTypedFields(t *testing.T) {
+	mg := getTypedTestModelGenerator()
+
+	file, err := NewGeneratedFile("gadget.go", mg.Generate())
 
 	if err != nil {
-		t.Errorf("\nERROR: %v\n", err)
+		t.Errorf("\nERROR: Generated model doesn't parse: %v\n", err)
 		return
 	}
 
-	code := mg.GenerateTest()
+	expected := []string{
+		`"encoding/json"`,
+		`"github.com/lib/pq"`,
+		"Id          sql.NullInt64 ",
+		"StartsOn    sql.NullTime ",
+		"PublishedAt time.Time ",
+		"Data        util.NullRawMessage ",
+		"Tags        pq.StringArray ",
+		"g.SetStatus(\"draft\")\n\tg.SetQty(0)\n\tg.SetPublishedAt(time.Now())\n",
+		"g.Qty, err = util.ParseNullInt64(req.PostFormValue(\"Qty\"))",
+		"g.SetTags(req.PostForm[\"Tags\"])",
+		"g.SetDeletedAt(time.Now())",
+		"g.SetDeletedAt(time.Time{})",
+		"func (g *Gadget) GetPrice() float64 {",
+		"func (g *Gadget) SetActive(val bool) {",
+		"func (g *Gadget) GetData() json.RawMessage {",
+		"func (g *Gadget) SetTags(val []string) {",
+		// retried transactions insert again
+		"return jgoweb.SaveInTransaction(g.Ctx, &g.Id.Valid, g.Insert, g.Update)\n",
+		// NOT NULL: zero is a value
+		"func (g *Gadget) SetQty(val int64) {\n\tg.Qty.Valid = true\n",
+	}
+
+	for _, str := range expected {
+		if !strings.Contains(file.Code, str) {
+			t.Errorf("\nERROR: Expected model code to contain:\n%s\n", str)
+		}
+	}
+}
 
-	fmt.Printf("\n%s\n", code)
+//
+func TestModelGeneratorTypedTests(t *testing.T) {
+	mg := getTypedTestModelGenerator()
+
+	file, err := NewGeneratedFile("gadget_test.go", mg.GenerateTest())
+
+	if err != nil {
+		t.Errorf("\nERROR: Generated test doesn't parse: %v\n", err)
+		return
+	}
+
+	expected := []string{
+		`"fmt"`,
+		"FetchGadgetById(jgoweb.MockCtx, fmt.Sprint(MockGadget.GetId()))",
+		"Qty := int64(1)",
+		"StartsOn := time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)",
+		"!g.GetStartsOn().Equal(StartsOn)",
+		"string(g.GetData()) != string(Data)",
+		"fmt.Sprint(g.GetTags()) != fmt.Sprint(Tags)",
+		"&Status=draft&Qty=1&Price=1.5&Active=true",
+	}
+
+	for _, str := range expected {
+		if !strings.Contains(file.Code, str) {
+			t.Errorf("\nERROR: Expected test code to contain:\n%s\n", str)
+		}
+	}
+}
+
+//
+func TestModelGeneratorStringImports(t *testing.T) {
+	mg := getTestModelGenerator()
+	code := mg.GetImportCode() + mg.GetTestImportCode()
+
+	for _, pkg := range []string{`"encoding/json"`, `"github.com/lib/pq"`, `"fmt"`} {
+		if strings.Contains(code, pkg) {
+			t.Errorf("\nERROR: Unexpected import %s:\n%s\n", pkg, code)
+		}
+	}
+}
+
+//
+func TestListViewTypedFields(t *testing.T) {
+	code := getTypedTestModelGenerator().GenerateListView()
+
+	for _, str := range []string{"item.Id.Int64", "item.Price.Float64", "item.PublishedAt }}", "item.Data.RawMessage"} {
+		if !strings.Contains(code, str) {
+			t.Errorf("\nERROR: Expected list view to contain %s\n", str)
+		}
+	}
 }
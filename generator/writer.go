@@ -0,0 +1,293 @@
+package generator
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Status of a generated file compared to what's on disk
+const (
+	GeneratedFileNew       = "new"
+	GeneratedFileUnchanged = "unchanged"
+	GeneratedFileChanged   = "changed"
+	GeneratedFileEdited    = "edited"
+)
+
+var generatedHeaderRegexp = regexp.MustCompile(`jgoweb-gen checksum: ([0-9a-f]{64})`)
+
+// Generated code and where it goes. Written files start with a header holding the checksum of the code,
+// so files that were edited by hand (checksum mismatch or no header) aren't overwritten by accident.
+type GeneratedFile struct {
+	Path string
+	Code string
+}
+
+// Go code is run through go/format
+func NewGeneratedFile(path string, code string) (*GeneratedFile, error) {
+
+	if filepath.Ext(path) == ".go" {
+		formatted, err := format.Source([]byte(code))
+
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("%s: %v", path, err))
+		}
+
+		code = string(formatted)
+	}
+
+	return &GeneratedFile{Path: path, Code: code}, nil
+}
+
+// Header and code
+func (gf *GeneratedFile) GetContent() string {
+	sum := sha256.Sum256([]byte(gf.Code))
+	header := "jgoweb-gen checksum: " + hex.EncodeToString(sum[:])
+
+	if filepath.Ext(gf.Path) == ".go" {
+		return "// " + header + "\n\n" + gf.Code
+	}
+
+	// views use [[ ]] delimiters
+	return "[[/* " + header + " */]]\n\n" + gf.Code
+}
+
+// new, unchanged, changed (generated and safe to overwrite) or edited (needs force).
+// Also returns the current content.
+func (gf *GeneratedFile) GetStatus() (string, string, error) {
+	current, err := ioutil.ReadFile(gf.Path)
+
+	if os.IsNotExist(err) {
+		return GeneratedFileNew, "", nil
+	}
+
+	if err != nil {
+		return "", "", err
+	}
+
+	return GetGeneratedFileStatus(string(current), gf.GetContent()), string(current), nil
+}
+
+//
+func GetGeneratedFileStatus(current string, content string) string {
+
+	if current == content {
+		return GeneratedFileUnchanged
+	}
+
+	lines := strings.SplitN(current, "\n", 2)
+	match := generatedHeaderRegexp.FindStringSubmatch(lines[0])
+
+	if match == nil || len(lines) < 2 {
+		return GeneratedFileEdited
+	}
+
+	// the code follows the header and a blank line
+	code := strings.TrimPrefix(lines[1], "\n")
+	sum := sha256.Sum256([]byte(code))
+
+	if hex.EncodeToString(sum[:]) != match[1] {
+		return GeneratedFileEdited
+	}
+
+	return GeneratedFileChanged
+}
+
+// Write the file (creating its dir). Edited files are only overwritten with force. Returns the status.
+func (gf *GeneratedFile) Write(force bool) (string, error) {
+	status, _, err := gf.GetStatus()
+
+	if err != nil {
+		return "", err
+	}
+
+	if status == GeneratedFileUnchanged {
+		return status, nil
+	}
+
+	if status == GeneratedFileEdited && !force {
+		return status, errors.New(fmt.Sprintf("%s was edited by hand (use force to overwrite)", gf.Path))
+	}
+
+	err = os.MkdirAll(filepath.Dir(gf.Path), 0755)
+
+	if err != nil {
+		return status, err
+	}
+
+	return status, ioutil.WriteFile(gf.Path, []byte(gf.GetContent()), 0644)
+}
+
+// Unified diff of the file on disk and the generated file ("" if unchanged)
+func (gf *GeneratedFile) Diff() (string, error) {
+	status, current, err := gf.GetStatus()
+
+	if err != nil {
+		return "", err
+	}
+
+	if status == GeneratedFileUnchanged {
+		return "", nil
+	}
+
+	from := gf.Path
+
+	if status == GeneratedFileNew {
+		from = "/dev/null"
+	}
+
+	return DiffText(from, gf.Path, current, gf.GetContent()), nil
+}
+
+// ******
+
+// Unified diff with 3 lines of context
+func DiffText(fromName string, toName string, from string, to string) string {
+	a := splitDiffLines(from)
+	b := splitDiffLines(to)
+	ops := diffLines(a, b)
+
+	var out strings.Builder
+	context := 3
+
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+
+		// hunk: context before, changes (joined while separated by <= 2*context equal lines), context after
+		start := i - context
+
+		if start < 0 {
+			start = 0
+		}
+
+		end := i
+
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+
+			next := end
+
+			for next < len(ops) && ops[next].kind == ' ' {
+				next++
+			}
+
+			if next == len(ops) || next-end > 2*context {
+				break
+			}
+
+			end = next
+		}
+
+		stop := end + context
+
+		if stop > len(ops) {
+			stop = len(ops)
+		}
+
+		aStart, bStart, aLen, bLen := ops[start].a, ops[start].b, 0, 0
+
+		for _, op := range ops[start:stop] {
+			if op.kind != '+' {
+				aLen++
+			}
+
+			if op.kind != '-' {
+				bLen++
+			}
+		}
+
+		// empty ranges start at the line before
+		if aLen > 0 {
+			aStart++
+		}
+
+		if bLen > 0 {
+			bStart++
+		}
+
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", aStart, aLen, bStart, bLen)
+
+		for _, op := range ops[start:stop] {
+			out.WriteString(string(op.kind) + op.line + "\n")
+		}
+
+		i = stop
+	}
+
+	return out.String()
+}
+
+// kind: ' ' = equal, '-' = only in a, '+' = only in b. a/b = line index in each (before the op).
+type diffOp struct {
+	kind byte
+	line string
+	a    int
+	b    int
+}
+
+//
+func splitDiffLines(text string) []string {
+
+	if text == "" {
+		return nil
+	}
+
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// Longest common subsequence (generated files are small enough for the n*m table)
+func diffLines(a []string, b []string) []diffOp {
+	var ops []diffOp
+
+	lcs := make([][]int, len(a)+1)
+
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i], i, j})
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] > lcs[i+1][j]):
+			ops = append(ops, diffOp{'+', b[j], i, j})
+			j++
+		default:
+			ops = append(ops, diffOp{'-', a[i], i, j})
+			i++
+		}
+	}
+
+	return ops
+}
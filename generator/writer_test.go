@@ -0,0 +1,140 @@
+// +build unit
+
+package generator
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+//
+func TestNewGeneratedFile(t *testing.T) {
+	gf, err := NewGeneratedFile("models/user.go", "package models\nfunc  A( ) {}\n")
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+		return
+	}
+
+	if gf.Code != "package models\n\nfunc A() {}\n" {
+		t.Errorf("\nERROR: Expected formatted code. Got:\n%s\n", gf.Code)
+	}
+
+	if !strings.HasPrefix(gf.GetContent(), "// jgoweb-gen checksum: ") {
+		t.Errorf("\nERROR: Missing header:\n%s\n", gf.GetContent())
+	}
+
+	_, err = NewGeneratedFile("models/user.go", "package models\nfunc {")
+
+	if err == nil || !strings.HasPrefix(err.Error(), "models/user.go: ") {
+		t.Errorf("\nERROR: Expected format error. Got: %v\n", err)
+	}
+
+	view, _ := NewGeneratedFile("templates/user.html", "[[define \"title\"]]User[[end]]\n")
+
+	if !strings.HasPrefix(view.GetContent(), "[[/* jgoweb-gen checksum: ") {
+		t.Errorf("\nERROR: Missing view header:\n%s\n", view.GetContent())
+	}
+}
+
+//
+func TestGeneratedFileWrite(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "models", "user.go")
+
+	gf, _ := NewGeneratedFile(path, "package models\n")
+
+	status, err := gf.Write(false)
+
+	if err != nil || status != GeneratedFileNew {
+		t.Errorf("\nERROR: Expected new file. Got: %v %v\n", status, err)
+		return
+	}
+
+	status, _ = gf.Write(false)
+
+	if status != GeneratedFileUnchanged {
+		t.Errorf("\nERROR: Expected unchanged file. Got: %v\n", status)
+	}
+
+	// regenerated (unedited) files are overwritten
+	gf, _ = NewGeneratedFile(path, "package models\n\nvar A = 1\n")
+	status, err = gf.Write(false)
+
+	if err != nil || status != GeneratedFileChanged {
+		t.Errorf("\nERROR: Expected changed file. Got: %v %v\n", status, err)
+	}
+
+	// hand edits need force
+	content, _ := ioutil.ReadFile(path)
+	ioutil.WriteFile(path, append(content, []byte("\nvar B = 2\n")...), 0644)
+
+	gf, _ = NewGeneratedFile(path, "package models\n")
+	status, err = gf.Write(false)
+
+	if err == nil || status != GeneratedFileEdited {
+		t.Errorf("\nERROR: Expected edited file to be refused. Got: %v %v\n", status, err)
+	}
+
+	diff, err := gf.Diff()
+
+	if err != nil || !strings.Contains(diff, "-var B = 2\n") || !strings.Contains(diff, "-var A = 1\n") {
+		t.Errorf("\nERROR: Unexpected diff: %v\n%s\n", err, diff)
+	}
+
+	status, err = gf.Write(true)
+
+	if err != nil || status != GeneratedFileEdited {
+		t.Errorf("\nERROR: Expected forced write. Got: %v %v\n", status, err)
+	}
+
+	content, _ = ioutil.ReadFile(path)
+
+	if string(content) != gf.GetContent() {
+		t.Errorf("\nERROR: Unexpected content:\n%s\n", content)
+	}
+
+	if GetGeneratedFileStatus("package models\n", gf.GetContent()) != GeneratedFileEdited {
+		t.Errorf("\nERROR: Files without a header should be treated as edited\n")
+	}
+}
+
+//
+func TestDiffText(t *testing.T) {
+	from := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"
+	to := "a\nb\nc\nd\nE\nf\ng\nh\ni\nj\nk\n"
+
+	expected := `--- old
++++ new
+@@ -2,9 +2,10 @@
+ b
+ c
+ d
+-e
++E
+ f
+ g
+ h
+ i
+ j
++k
+`
+
+	result := DiffText("old", "new", from, to)
+
+	if result != expected {
+		t.Errorf("\nERROR: Unexpected diff\nExpected:\n%s\nResult:\n%s\n", expected, result)
+	}
+
+	result = DiffText("/dev/null", "new", "", "a\n")
+
+	if result != "--- /dev/null\n+++ new\n@@ -0,0 +1,1 @@\n+a\n" {
+		t.Errorf("\nERROR: Unexpected new file diff:\n%s\n", result)
+	}
+
+	if DiffText("old", "new", from, from) != "--- old\n+++ new\n" {
+		t.Errorf("\nERROR: Expected empty diff\n")
+	}
+}
@@ -1,6 +1,6 @@
 module github.com/jschneider98/jgoweb
 
-go 1.12
+go 1.16
 
 require (
 	github.com/alexedwards/scs v1.4.1
@@ -17,6 +17,7 @@ require (
 	github.com/jschneider98/jgovalidator v0.0.0-20200413183512-1d90b1f4c052
 	github.com/lib/pq v1.2.0
 	github.com/prometheus/client_golang v1.2.1
+	github.com/sirupsen/logrus v1.4.2
 	golang.org/x/crypto v0.0.0-20191117063200-497ca9f6d64f
 	golang.org/x/net v0.0.0-20200202094626-16171245cfb2
 	golang.org/x/oauth2 v0.0.0-20200107190931-bf48bf16ab8d
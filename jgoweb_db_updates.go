@@ -0,0 +1,32 @@
+package jgoweb
+
+// Schema updates required by jgoweb itself. Append them to the app's updates passed to NewSystemDbUpdater.
+func GetJgowebDbUpdates() []SystemDbUpdateInterface {
+	updates := make([]SystemDbUpdateInterface, 0)
+
+	update := CreateSystemDbUpdateNoContext("jgoweb_0001_shard_placement", "Shard weight, capacity limit and accepting accounts flag")
+	update.ApplyUpdate = func(ctx ContextInterface) error {
+		_, err := ctx.UpdateBySql(`
+	ALTER TABLE system.shards
+		ADD COLUMN IF NOT EXISTS weight numeric DEFAULT 1,
+		ADD COLUMN IF NOT EXISTS capacity_limit integer,
+		ADD COLUMN IF NOT EXISTS accepting_accounts boolean DEFAULT true`).Exec()
+
+		return err
+	}
+
+	updates = append(updates, update)
+
+	update = CreateSystemDbUpdateNoContext("jgoweb_0002_shard_dsn", "Shard DSN for runtime connection reloads")
+	update.ApplyUpdate = func(ctx ContextInterface) error {
+		_, err := ctx.UpdateBySql(`
+	ALTER TABLE system.shards
+		ADD COLUMN IF NOT EXISTS dsn text`).Exec()
+
+		return err
+	}
+
+	updates = append(updates, update)
+
+	return updates
+}
@@ -82,7 +82,7 @@ func (jq *JobQueue) ProcessJobs() error {
 
 	for _, qJob := range qJobs {
 		// NOTE: Use distinct DB session per job
-		qJob.Ctx = jq.NewContext()
+		qJob.Ctx = jq.NewJobContext(qJob.Ctx)
 		go jq.processJob(qJob, jq.Debug)
 	}
 
@@ -91,8 +91,22 @@ func (jq *JobQueue) ProcessJobs() error {
 
 //
 func (jq *JobQueue) NewContext() ContextInterface {
+	return jq.NewJobContext(nil)
+}
+
+// New context on the same DB connection as jobCtx (i.e., the job's shard). Falls back to the queue's context.
+func (jq *JobQueue) NewJobContext(jobCtx ContextInterface) ContextInterface {
+
+	if jobCtx == nil || jobCtx.GetDbSession() == nil {
+		jobCtx = jq.Ctx
+	}
+
+	curSess := jobCtx.GetDbSession()
+	dbSess := curSess.Connection.NewSession(nil)
+	dbSess.Timeout = curSess.Timeout
+
 	ctx := NewContext(jq.Ctx.GetDb())
-	ctx.SetDbSession(jq.Ctx.GetDbSession().Connection.NewSession(nil))
+	ctx.SetDbSession(dbSess)
 
 	return ctx
 }
@@ -118,8 +132,8 @@ func (jq *JobQueue) processJob(sj QueueJob, debug bool) error {
 		return nil
 	}
 
-	// New job process needs it's own context
-	ctx := jq.NewContext()
+	// New job process needs it's own context (bound to the job's shard)
+	ctx := jq.NewJobContext(qJob.Ctx)
 	job, err := jq.factory.New(ctx, qJob.GetName(), params)
 
 	if err != nil {
@@ -0,0 +1,155 @@
+package jgoweb
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Job queue store that spans every shard in the db collection. Jobs are stored on their account's shard
+// and pulled from all shards using a fairness policy.
+type JobQueueClusterStore struct {
+	Ctx            ContextInterface
+	MaxConcurrency uint64
+	MaxBatch       uint64
+	MaxMem         uint64
+	Policy         JobQueueShardPolicyInterface
+}
+
+//
+func NewJobQueueClusterStore(ctx ContextInterface) (*JobQueueClusterStore, error) {
+	jqs := &JobQueueClusterStore{Ctx: ctx}
+	jqs.MaxConcurrency = 100
+	jqs.MaxBatch = 10
+	jqs.Policy = NewJobQueueRoundRobinPolicy()
+
+	// 0 = unlimited, value should be in MB
+	jqs.MaxMem = 0
+
+	return jqs, nil
+}
+
+//
+func (jqs *JobQueueClusterStore) GetNextJobs() ([]QueueJob, error) {
+	var runningJobs uint64
+	results := make([]QueueJob, 0)
+
+	if jqs.MaxBatch > jqs.MaxConcurrency {
+		jqs.MaxBatch = jqs.MaxConcurrency
+	}
+
+	shardNames := jqs.GetShardNames()
+	stores := make(map[string]*JobQueueNativeStore)
+
+	for _, shardName := range shardNames {
+		store, err := jqs.NewShardStore(shardName)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if store.IsMemExceeded() {
+			return results, nil
+		}
+
+		stores[shardName] = store
+		runningJobs += store.GetRunningJobs()
+	}
+
+	if runningJobs >= jqs.MaxConcurrency {
+		return results, nil
+	}
+
+	limit := jqs.MaxConcurrency - runningJobs
+
+	if limit > jqs.MaxBatch {
+		limit = jqs.MaxBatch
+	}
+
+	candidates := make(map[string][]QueueJob)
+
+	for _, shardName := range shardNames {
+		store := stores[shardName]
+		jobs, err := store.GetQueuedJobs(limit)
+
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("%s: %s", shardName, err))
+		}
+
+		// Jobs run against the shard they were queued on
+		for key := range jobs {
+			jobs[key].Ctx = store.Ctx
+		}
+
+		candidates[shardName] = jobs
+	}
+
+	return jqs.Policy.SelectJobs(shardNames, candidates, limit), nil
+}
+
+// Route the job to its account's shard
+func (jqs *JobQueueClusterStore) EnqueueJob(job *QueueJob) error {
+	shard, err := GetShardByAccountId(jqs.Ctx, job.GetAccountId())
+
+	if err != nil {
+		return err
+	}
+
+	ctx, err := jqs.NewShardContext(shard.GetName())
+
+	if err != nil {
+		return err
+	}
+
+	job.Ctx = ctx
+
+	return job.Save()
+}
+
+// Sorted so the fairness policy sees shards in a stable order
+func (jqs *JobQueueClusterStore) GetShardNames() []string {
+	shardNames := make([]string, 0)
+
+	for shardName := range jqs.Ctx.GetDb().GetConns() {
+		shardNames = append(shardNames, shardName)
+	}
+
+	sort.Strings(shardNames)
+
+	return shardNames
+}
+
+//
+func (jqs *JobQueueClusterStore) NewShardContext(shardName string) (ContextInterface, error) {
+	dbSess, err := jqs.Ctx.GetDb().GetSessionByName(shardName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := NewContext(jqs.Ctx.GetDb())
+	ctx.SetDbSession(dbSess)
+
+	return ctx, nil
+}
+
+//
+func (jqs *JobQueueClusterStore) NewShardStore(shardName string) (*JobQueueNativeStore, error) {
+	ctx, err := jqs.NewShardContext(shardName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := NewJobQueueNativeStore(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	store.MaxConcurrency = jqs.MaxConcurrency
+	store.MaxBatch = jqs.MaxBatch
+	store.MaxMem = jqs.MaxMem
+
+	return store, nil
+}
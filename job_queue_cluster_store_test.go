@@ -0,0 +1,57 @@
+// +build integration
+
+package jgoweb
+
+import (
+	"testing"
+)
+
+//
+func TestJobQueueClusterStoreGetNextJobs(t *testing.T) {
+	InitMockCtx()
+	jqs, err := NewJobQueueClusterStore(MockCtx)
+
+	if err != nil {
+		t.Errorf("ERROR: (%v)", err)
+	}
+
+	_, err = jqs.GetNextJobs()
+
+	if err != nil {
+		t.Errorf("ERROR: (%v)", err)
+	}
+}
+
+//
+func TestJobQueueClusterStoreEnqueueJob(t *testing.T) {
+	InitMockCtx()
+	InitMockUser()
+	jqs, err := NewJobQueueClusterStore(MockCtx)
+
+	if err != nil {
+		t.Errorf("ERROR: (%v)", err)
+	}
+
+	qJob, err := NewQueueJob(nil)
+
+	if err != nil {
+		t.Errorf("ERROR: (%v)", err)
+	}
+
+	qJob.SetAccountId(MockUser.GetAccountId())
+	qJob.SetName("test")
+	qJob.SetDescription("test")
+
+	err = jqs.EnqueueJob(qJob)
+
+	if err != nil {
+		t.Errorf("ERROR: (%v)", err)
+		return
+	}
+
+	err = qJob.Delete()
+
+	if err != nil {
+		t.Errorf("ERROR: (%v)", err)
+	}
+}
@@ -0,0 +1,62 @@
+package jgoweb
+
+import (
+	"sync"
+)
+
+// Decides which queued jobs run next when jobs are pulled from more than one shard
+type JobQueueShardPolicyInterface interface {
+	SelectJobs(shardNames []string, candidates map[string][]QueueJob, limit uint64) []QueueJob
+}
+
+// Round robin between shards. The starting shard rotates on every call so a busy shard
+// can't starve the others, and a shard with few jobs doesn't hold back the rest.
+type JobQueueRoundRobinPolicy struct {
+	next int
+	mu   sync.Mutex
+}
+
+//
+func NewJobQueueRoundRobinPolicy() *JobQueueRoundRobinPolicy {
+	return &JobQueueRoundRobinPolicy{}
+}
+
+//
+func (p *JobQueueRoundRobinPolicy) SelectJobs(shardNames []string, candidates map[string][]QueueJob, limit uint64) []QueueJob {
+	results := make([]QueueJob, 0)
+
+	if len(shardNames) == 0 || limit == 0 {
+		return results
+	}
+
+	p.mu.Lock()
+	start := p.next % len(shardNames)
+	p.next = (start + 1) % len(shardNames)
+	p.mu.Unlock()
+
+	for round := 0; ; round++ {
+		found := false
+
+		for i := range shardNames {
+			shardName := shardNames[(start+i)%len(shardNames)]
+			jobs := candidates[shardName]
+
+			if round >= len(jobs) {
+				continue
+			}
+
+			found = true
+			results = append(results, jobs[round])
+
+			if uint64(len(results)) >= limit {
+				return results
+			}
+		}
+
+		if !found {
+			break
+		}
+	}
+
+	return results
+}
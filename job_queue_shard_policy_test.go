@@ -0,0 +1,51 @@
+// +build unit
+
+package jgoweb
+
+import (
+	"testing"
+)
+
+//
+func TestJobQueueRoundRobinPolicy(t *testing.T) {
+	policy := NewJobQueueRoundRobinPolicy()
+	shardNames := []string{"a", "b"}
+	candidates := make(map[string][]QueueJob)
+
+	for _, shardName := range shardNames {
+		for i := 0; i < 3; i++ {
+			qJob := QueueJob{}
+			qJob.SetName(shardName)
+			candidates[shardName] = append(candidates[shardName], qJob)
+		}
+	}
+
+	jobs := policy.SelectJobs(shardNames, candidates, 4)
+
+	if len(jobs) != 4 {
+		t.Errorf("ERROR: Expected 4 jobs. Got: %v", len(jobs))
+	}
+
+	expected := []string{"a", "b", "a", "b"}
+
+	for key := range jobs {
+		if jobs[key].GetName() != expected[key] {
+			t.Errorf("ERROR: Expected job from shard %s. Got: %s", expected[key], jobs[key].GetName())
+		}
+	}
+
+	// Starting shard rotates
+	jobs = policy.SelectJobs(shardNames, candidates, 1)
+
+	if len(jobs) != 1 || jobs[0].GetName() != "b" {
+		t.Errorf("ERROR: Expected 2nd call to start with shard b.")
+	}
+
+	// Uneven shards
+	candidates["b"] = candidates["b"][:1]
+	jobs = policy.SelectJobs(shardNames, candidates, 10)
+
+	if len(jobs) != 4 {
+		t.Errorf("ERROR: Expected 4 jobs. Got: %v", len(jobs))
+	}
+}
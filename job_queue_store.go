@@ -30,7 +30,6 @@ func NewJobQueueNativeStore(ctx ContextInterface) (*JobQueueNativeStore, error)
 
 //
 func (jqs *JobQueueNativeStore) GetNextJobs() ([]QueueJob, error) {
-	var err error
 	results := make([]QueueJob, 0)
 
 	if jqs.IsMemExceeded() {
@@ -53,13 +52,20 @@ func (jqs *JobQueueNativeStore) GetNextJobs() ([]QueueJob, error) {
 		limit = jqs.MaxBatch
 	}
 
+	return jqs.GetQueuedJobs(limit)
+}
+
+// Queued jobs that haven't started, highest priority first
+func (jqs *JobQueueNativeStore) GetQueuedJobs(limit uint64) ([]QueueJob, error) {
+	results := make([]QueueJob, 0)
+
 	stmt := jqs.Ctx.Select("*").
 		From("queue.jobs").
 		Where("started_at IS NULL AND ended_at IS NULL").
 		OrderBy("EXTRACT(EPOCH FROM now() - queued_at)/60 + priority::numeric DESC").
 		Limit(limit)
 
-	_, err = stmt.Load(&results)
+	_, err := stmt.Load(&results)
 
 	if err != nil {
 		return nil, err
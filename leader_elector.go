@@ -0,0 +1,137 @@
+package jgoweb
+
+import (
+	"context"
+	"github.com/jschneider98/jgoweb/util"
+	"log"
+	"sync"
+	"time"
+)
+
+// Elects one leader across instances with a session scoped advisory lock.
+// OnElected runs in its own goroutine and its context is cancelled when leadership is lost.
+// If the lock's connection drops, the leader is demoted and every instance tries to take over.
+// OnDemoted runs synchronously. Callbacks must not call back into the elector.
+type LeaderElector struct {
+	Ctx       ContextInterface
+	Key       string
+	Interval  time.Duration
+	OnElected func(goCtx context.Context)
+	OnDemoted func()
+	lock      *AdvisoryLock
+	cancel    context.CancelFunc
+	stop      chan struct{}
+	stopped   bool
+	mu        sync.Mutex
+}
+
+//
+func NewLeaderElector(ctx ContextInterface, key string) *LeaderElector {
+	return &LeaderElector{Ctx: ctx, Key: key, Interval: 10 * time.Second}
+}
+
+// Run an election now and every Interval
+func (le *LeaderElector) Start() {
+	le.Stop()
+
+	stop := make(chan struct{})
+
+	le.mu.Lock()
+	le.stop = stop
+	le.stopped = false
+	le.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(le.Interval)
+		defer ticker.Stop()
+
+		for {
+			err := le.Elect()
+
+			if err != nil {
+				log.Printf("ERROR: %s %s", util.WhereAmI(), err)
+			}
+
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop running elections and resign. Elections already in flight won't take the lock.
+func (le *LeaderElector) Stop() {
+	le.mu.Lock()
+	defer le.mu.Unlock()
+
+	le.stopped = true
+
+	if le.stop != nil {
+		close(le.stop)
+		le.stop = nil
+	}
+
+	le.demote()
+}
+
+//
+func (le *LeaderElector) IsLeader() bool {
+	le.mu.Lock()
+	defer le.mu.Unlock()
+
+	return le.lock != nil
+}
+
+// One round: confirm the current lock or try to take it
+func (le *LeaderElector) Elect() error {
+	le.mu.Lock()
+	defer le.mu.Unlock()
+
+	if le.stopped {
+		return nil
+	}
+
+	if le.lock != nil {
+
+		if le.lock.Check() == nil {
+			return nil
+		}
+
+		le.demote()
+	}
+
+	lock, err := le.Ctx.TryAdvisoryLock(le.Key, 0)
+
+	if err != nil || lock == nil {
+		return err
+	}
+
+	goCtx, cancel := context.WithCancel(context.Background())
+	le.lock = lock
+	le.cancel = cancel
+
+	if le.OnElected != nil {
+		go le.OnElected(goCtx)
+	}
+
+	return nil
+}
+
+// Caller holds mu
+func (le *LeaderElector) demote() {
+
+	if le.lock == nil {
+		return
+	}
+
+	le.cancel()
+	le.lock.Unlock()
+	le.lock = nil
+	le.cancel = nil
+
+	if le.OnDemoted != nil {
+		le.OnDemoted()
+	}
+}
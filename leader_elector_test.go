@@ -0,0 +1,45 @@
+// +build unit
+
+package jgoweb
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// Hands out a lock every time (without a DB)
+type testLeaderCtx struct {
+	ContextInterface
+}
+
+//
+func (ctx *testLeaderCtx) TryAdvisoryLock(key string, timeout time.Duration) (*AdvisoryLock, error) {
+	return &AdvisoryLock{Key: key, Id: AdvisoryLockId(key)}, nil
+}
+
+//
+func TestLeaderElectorStopElectRace(t *testing.T) {
+	var wg sync.WaitGroup
+
+	le := NewLeaderElector(&testLeaderCtx{}, "test.leader")
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 100; j++ {
+				le.Elect()
+			}
+		}()
+	}
+
+	le.Stop()
+	wg.Wait()
+
+	if le.IsLeader() {
+		t.Errorf("\nERROR: Elect took the lock after Stop\n")
+	}
+}
@@ -130,6 +130,12 @@ func InitMockSystemDbUpdate() {
 	if MockSystemDbUpdate == nil {
 		var err error
 
+		err = EnsureSystemDbUpdateColumns(MockCtx)
+
+		if err != nil {
+			panic(err)
+		}
+
 		MockSystemDbUpdate, err = NewSystemDbUpdate(MockCtx)
 
 		if err != nil {
@@ -0,0 +1,174 @@
+package jgoweb
+
+import (
+	"github.com/gocraft/dbr"
+	"github.com/gocraft/health"
+	"github.com/jschneider98/jgoweb/util"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	dbQueryHistogram = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "db_query_duration_milliseconds",
+		Help: "Histogram of DB queries. Labels: shard, kind (select, exec).",
+	},
+		[]string{"shard", "kind"},
+	)
+)
+
+var sqlStringRegexp = regexp.MustCompile(`'(?:[^']|'')*'`)
+var sqlNumberRegexp = regexp.MustCompile(`\b\d+(?:\.\d+)?\b`)
+
+// dbr.EventReceiver that reports queries to the health stream (or the request's job), metrics and the slow query log
+type QueryEventReceiver struct {
+	ShardName string
+	Ctx       *WebContext
+}
+
+// Installed for every pool in db.Collection
+func NewQueryEventReceiver(shardName string) dbr.EventReceiver {
+	return &QueryEventReceiver{ShardName: shardName}
+}
+
+// Copy bound to a request (for the endpoint and health job)
+func (qer *QueryEventReceiver) WithContext(ctx *WebContext) *QueryEventReceiver {
+	clone := *qer
+	clone.Ctx = ctx
+
+	return &clone
+}
+
+// dbr interpolates values into the SQL, so strip literals before logging
+func RedactSql(query string) string {
+	query = sqlStringRegexp.ReplaceAllString(query, "'?'")
+
+	return sqlNumberRegexp.ReplaceAllString(query, "?")
+}
+
+//
+func (qer *QueryEventReceiver) getJob() health.EventReceiver {
+
+	if qer.Ctx != nil && qer.Ctx.Job != nil {
+		return qer.Ctx.Job
+	}
+
+	return healthStream
+}
+
+//
+func (qer *QueryEventReceiver) getKvs(kvs map[string]string) map[string]string {
+	merged := map[string]string{"shard": qer.ShardName}
+
+	if qer.Ctx != nil && qer.Ctx.EndPoint != "" {
+		merged["endpoint"] = qer.Ctx.EndPoint
+	}
+
+	for key, val := range kvs {
+		if key == "sql" {
+			val = RedactSql(val)
+		}
+
+		merged[key] = val
+	}
+
+	return merged
+}
+
+//
+func (qer *QueryEventReceiver) Event(eventName string) {
+	qer.getJob().EventKv(eventName, qer.getKvs(nil))
+}
+
+//
+func (qer *QueryEventReceiver) EventKv(eventName string, kvs map[string]string) {
+	qer.getJob().EventKv(eventName, qer.getKvs(kvs))
+}
+
+//
+func (qer *QueryEventReceiver) EventErr(eventName string, err error) error {
+	return qer.getJob().EventErrKv(eventName, err, qer.getKvs(nil))
+}
+
+//
+func (qer *QueryEventReceiver) EventErrKv(eventName string, err error, kvs map[string]string) error {
+	return qer.getJob().EventErrKv(eventName, err, qer.getKvs(kvs))
+}
+
+//
+func (qer *QueryEventReceiver) Timing(eventName string, nanoseconds int64) {
+	qer.TimingKv(eventName, nanoseconds, nil)
+}
+
+// dbr reports "dbr.select" and "dbr.exec" timings with the interpolated SQL
+func (qer *QueryEventReceiver) TimingKv(eventName string, nanoseconds int64, kvs map[string]string) {
+	kind := strings.TrimPrefix(eventName, "dbr.")
+	ms := float64(nanoseconds) / 1000000
+
+	// SQL isn't sent to the health stream to keep its key cardinality down
+	timingKvs := qer.getKvs(nil)
+	qer.getJob().TimingKv(eventName, nanoseconds, timingKvs)
+
+	dbQueryHistogram.WithLabelValues(qer.ShardName, kind).Observe(ms)
+
+	if appConfig == nil || ms < float64(appConfig.QueryLog.SlowThreshold) {
+		return
+	}
+
+	fields := logrus.Fields{"shard": qer.ShardName, "kind": kind, "duration_ms": ms, "sql": RedactSql(kvs["sql"])}
+
+	if endPoint, ok := timingKvs["endpoint"]; ok {
+		fields["endpoint"] = endPoint
+	}
+
+	util.Log().WithFields(fields).Warn("slow query")
+
+	if appConfig.QueryLog.Explain && appConfig.Server.Mode == "dev" && kind == "select" && kvs["sql"] != "" {
+		go qer.Explain(kvs["sql"])
+	}
+}
+
+// Log the plan for a (slow) select. Dev only: the logged plan isn't redacted.
+func (qer *QueryEventReceiver) Explain(query string) {
+	var plan []string
+
+	if db == nil {
+		return
+	}
+
+	dbConn, err := db.GetConnByName(qer.ShardName)
+
+	if err != nil {
+		return
+	}
+
+	// no receiver, or the explain would be instrumented too
+	dbSess := dbConn.NewSession(&dbr.NullEventReceiver{})
+	dbSess.Timeout = 5 * time.Second
+
+	_, err = dbSess.SelectBySql("EXPLAIN " + query).Load(&plan)
+
+	if err != nil {
+		util.Log().WithFields(logrus.Fields{"shard": qer.ShardName, "error": err.Error()}).Warn("slow query explain failed")
+		return
+	}
+
+	util.Log().WithFields(logrus.Fields{"shard": qer.ShardName, "sql": RedactSql(query)}).Warn("slow query plan:\n" + strings.Join(plan, "\n"))
+}
+
+// Bind a session's receiver to the request. Sessions without a QueryEventReceiver are left alone.
+func (ctx *WebContext) instrumentSession(dbSess *dbr.Session) *dbr.Session {
+
+	if dbSess == nil {
+		return nil
+	}
+
+	if qer, ok := dbSess.EventReceiver.(*QueryEventReceiver); ok && qer.Ctx != ctx {
+		dbSess.EventReceiver = qer.WithContext(ctx)
+	}
+
+	return dbSess
+}
@@ -0,0 +1,82 @@
+// +build unit
+
+package jgoweb
+
+import (
+	"github.com/gocraft/dbr"
+	"testing"
+)
+
+//
+func TestRedactSql(t *testing.T) {
+	tests := map[string]string{
+		"SELECT * FROM public.users WHERE email = 'bob@example.com'": "SELECT * FROM public.users WHERE email = '?'",
+		"SELECT * FROM t WHERE name = 'O''Brien' AND id = 42":        "SELECT * FROM t WHERE name = '?' AND id = ?",
+		"UPDATE t SET amount = 12.50 WHERE t1_id = 3":                "UPDATE t SET amount = ? WHERE t1_id = ?",
+	}
+
+	for query, expected := range tests {
+		result := RedactSql(query)
+
+		if result != expected {
+			t.Errorf("\nERROR: RedactSql(%s)\nExpected: %s\nResult: %s\n", query, expected, result)
+		}
+	}
+}
+
+//
+func TestQueryEventReceiverKvs(t *testing.T) {
+	qer := NewQueryEventReceiver("shard_1").(*QueryEventReceiver)
+
+	kvs := qer.getKvs(map[string]string{"sql": "SELECT 1"})
+
+	if kvs["shard"] != "shard_1" || kvs["sql"] != "SELECT ?" {
+		t.Errorf("\nERROR: Unexpected kvs: %v\n", kvs)
+	}
+
+	if _, ok := kvs["endpoint"]; ok {
+		t.Errorf("\nERROR: Endpoint set without a web context: %v\n", kvs)
+	}
+
+	if qer.getJob() != healthStream {
+		t.Errorf("\nERROR: Expected the health stream without a web context\n")
+	}
+
+	ctx := &WebContext{EndPoint: "api_users_get"}
+	ctx.Job = healthStream.NewJob("test")
+	kvs = qer.WithContext(ctx).getKvs(nil)
+
+	if kvs["endpoint"] != "api_users_get" {
+		t.Errorf("\nERROR: Expected endpoint kv: %v\n", kvs)
+	}
+
+	if qer.WithContext(ctx).getJob() != ctx.Job {
+		t.Errorf("\nERROR: Expected the request's job\n")
+	}
+}
+
+//
+func TestInstrumentSession(t *testing.T) {
+	qer := &QueryEventReceiver{ShardName: "shard_1"}
+	conn := &dbr.Connection{EventReceiver: qer}
+	ctx := &WebContext{}
+
+	ctx.SetDbSession(conn.NewSession(nil))
+
+	result, ok := ctx.DbSess.EventReceiver.(*QueryEventReceiver)
+
+	if !ok || result.Ctx != ctx || result.ShardName != "shard_1" {
+		t.Errorf("\nERROR: Session receiver not bound to the context: %v\n", ctx.DbSess.EventReceiver)
+	}
+
+	// the pool's receiver is shared, so it must not be modified
+	if qer.Ctx != nil {
+		t.Errorf("\nERROR: Pool receiver was modified\n")
+	}
+
+	ctx.SetDbSession(conn.NewSession(&dbr.NullEventReceiver{}))
+
+	if _, ok := ctx.DbSess.EventReceiver.(*dbr.NullEventReceiver); !ok {
+		t.Errorf("\nERROR: Non query receiver was replaced\n")
+	}
+}
@@ -1,14 +1,25 @@
 package jgoweb
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/gocraft/web"
 	"github.com/jschneider98/jgoweb/util"
 	"net/url"
 	"time"
 )
 
+// Job states (derived from the timestamps and error)
+const (
+	QueueJobQueued  = "queued"
+	QueueJobRunning = "running"
+	QueueJobFailed  = "failed"
+	QueueJobDone    = "done"
+)
+
 // QueueJob
 type QueueJob struct {
 	Id          sql.NullString   `json:"Id" validate:"omitempty,uuid"`
@@ -66,7 +77,7 @@ func FetchQueueJobById(ctx ContextInterface, id string) (*QueueJob, error) {
 		Where("id = ?", id).
 		Limit(1)
 
-	_, err := stmt.Load(&qj)
+	_, err := stmt.LoadContext(ctx.GetGoContext(), &qj)
 
 	if err != nil {
 		return nil, err
@@ -141,11 +152,7 @@ func (qj *QueueJob) Save() error {
 		return err
 	}
 
-	if !qj.Id.Valid {
-		return qj.Insert()
-	} else {
-		return qj.Update()
-	}
+	return SaveInTransaction(qj.Ctx, &qj.Id.Valid, qj.Insert, qj.Update)
 }
 
 // Insert a new record
@@ -591,3 +598,88 @@ func (qj *QueueJob) Checkin(status string) error {
 
 	return qj.Save()
 }
+
+// Queue the job again (i.e., after it failed). Clears the run timestamps and error.
+func (qj *QueueJob) Retry() error {
+	qj.SetStatus("")
+	qj.SetError("")
+	qj.SetStartedAt("")
+	qj.SetCheckinAt("")
+	qj.SetEndedAt("")
+	qj.SetQueuedAt((time.Now()).Format(time.RFC3339))
+
+	return qj.Save()
+}
+
+//
+func (qj *QueueJob) GetState() string {
+
+	switch {
+	case !qj.StartedAt.Valid && !qj.EndedAt.Valid:
+		return QueueJobQueued
+	case !qj.EndedAt.Valid:
+		return QueueJobRunning
+	case qj.Error.Valid:
+		return QueueJobFailed
+	}
+
+	return QueueJobDone
+}
+
+// Newest first. state "" = every job.
+func GetQueueJobsByState(ctx ContextInterface, state string, limit uint64) ([]QueueJob, error) {
+	qj := make([]QueueJob, 0)
+
+	stmt := ctx.Select("*").
+		From("queue.jobs").
+		OrderBy("queued_at DESC").
+		Limit(limit)
+
+	switch state {
+	case "":
+	case QueueJobQueued:
+		stmt.Where("started_at IS NULL AND ended_at IS NULL")
+	case QueueJobRunning:
+		stmt.Where("started_at IS NOT NULL AND ended_at IS NULL")
+	case QueueJobFailed:
+		stmt.Where("ended_at IS NOT NULL AND error IS NOT NULL")
+	case QueueJobDone:
+		stmt.Where("ended_at IS NOT NULL AND error IS NULL")
+	default:
+		return nil, errors.New(fmt.Sprintf("Invalid job state: %s", state))
+	}
+
+	_, err := stmt.LoadContext(ctx.GetGoContext(), &qj)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for key := range qj {
+		qj[key].Ctx = ctx
+	}
+
+	return qj, nil
+}
+
+// Find a job on any shard. The job's context is bound to its shard (nil if the job doesn't exist).
+func ClusterFetchQueueJobById(ctx ContextInterface, id string) (*QueueJob, error) {
+
+	results := NewClusterExecutor(ctx.GetDb()).Run(context.Background(), func(goCtx context.Context, shardCtx ContextInterface) (interface{}, error) {
+		return FetchQueueJobById(shardCtx, id)
+	})
+
+	err := results.Err()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, result := range results {
+		if job := result.Value.(*QueueJob); job != nil {
+			return job, nil
+		}
+	}
+
+	return nil, nil
+}
@@ -3,6 +3,7 @@
 package jgoweb
 
 import (
+	"errors"
 	"github.com/gocraft/web"
 	"net/http"
 	"strings"
@@ -568,3 +569,41 @@ func TestQueueJobProcessSubmit(t *testing.T) {
 		t.Errorf("\nERROR: %v", msg)
 	}
 }
+
+//
+func TestQueueJobRetry(t *testing.T) {
+	InitMockCtx()
+	InitMockQueueJob()
+
+	qj, err := ClusterFetchQueueJobById(MockCtx, MockQueueJob.GetId())
+
+	if err != nil || qj == nil {
+		t.Errorf("\nERROR: Should have found QueueJob with Id: %v Error: %v\n", MockQueueJob.GetId(), err)
+		return
+	}
+
+	err = qj.Fail(errors.New("test failure"))
+
+	if err != nil || qj.GetState() != QueueJobFailed {
+		t.Errorf("\nERROR: Expected failed job. State: %v Error: %v\n", qj.GetState(), err)
+		return
+	}
+
+	failed, err := GetQueueJobsByState(MockCtx, QueueJobFailed, 100)
+
+	if err != nil || len(failed) == 0 {
+		t.Errorf("\nERROR: Expected failed jobs. Error: %v\n", err)
+	}
+
+	err = qj.Retry()
+
+	if err != nil || qj.GetState() != QueueJobQueued || qj.GetError() != "" {
+		t.Errorf("\nERROR: Expected queued job. State: %v Error: %v\n", qj.GetState(), err)
+	}
+
+	_, err = GetQueueJobsByState(MockCtx, "bogus", 1)
+
+	if err == nil {
+		t.Errorf("\nERROR: Expected invalid state error\n")
+	}
+}
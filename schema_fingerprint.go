@@ -0,0 +1,173 @@
+package jgoweb
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	SchemaDriftMissing  = "missing"
+	SchemaDriftExtra    = "extra"
+	SchemaDriftConflict = "conflict"
+)
+
+// Structure of one DB. Object (i.e., "column public.users.email") => definition.
+type SchemaFingerprint struct {
+	Objects map[string]string
+}
+
+// Schema object that differs from the source shard
+type SchemaDrift struct {
+	ShardName string `json:"shardName"`
+	Object    string `json:"object"`
+	Kind      string `json:"kind"`
+	Source    string `json:"source"`
+	Target    string `json:"target"`
+}
+
+//
+type schemaFingerprintRow struct {
+	Object     sql.NullString `db:"object"`
+	Definition sql.NullString `db:"definition"`
+}
+
+// Columns, indexes and constraints outside the system schemas
+func FetchSchemaFingerprint(ctx ContextInterface) (*SchemaFingerprint, error) {
+	var rows []schemaFingerprintRow
+
+	_, err := ctx.SelectBySql(`
+	SELECT 'column ' || table_schema || '.' || table_name || '.' || column_name AS object,
+		data_type || ' ' || is_nullable || ' ' || coalesce(column_default, '') AS definition
+	FROM information_schema.columns
+	WHERE table_schema NOT IN ('pg_catalog', 'information_schema')
+		AND table_schema NOT LIKE 'pg\_%'
+	UNION ALL
+	SELECT 'index ' || schemaname || '.' || indexname, indexdef
+	FROM pg_indexes
+	WHERE schemaname NOT IN ('pg_catalog', 'information_schema')
+		AND schemaname NOT LIKE 'pg\_%'
+	UNION ALL
+	SELECT 'constraint ' || n.nspname || '.' || c.relname || '.' || con.conname, pg_get_constraintdef(con.oid)
+	FROM pg_constraint con
+	JOIN pg_class c ON c.oid = con.conrelid
+	JOIN pg_namespace n ON n.oid = c.relnamespace
+	WHERE n.nspname NOT IN ('pg_catalog', 'information_schema')
+		AND n.nspname NOT LIKE 'pg\_%'`).LoadContext(ctx.GetGoContext(), &rows)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sf := &SchemaFingerprint{Objects: make(map[string]string)}
+
+	for _, row := range rows {
+		sf.Objects[row.Object.String] = row.Definition.String
+	}
+
+	return sf, nil
+}
+
+// Hash of every object and definition. Equal hashes = same structure.
+func (sf *SchemaFingerprint) Hash() string {
+	var objects []string
+
+	for object, definition := range sf.Objects {
+		objects = append(objects, object+"\t"+definition)
+	}
+
+	sort.Strings(objects)
+	sum := sha256.Sum256([]byte(strings.Join(objects, "\n")))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// Compare every shard's schema to sourceShard's
+func (sdu *SystemDbUpdater) VerifySchema(sourceShard string) ([]SchemaDrift, error) {
+	all := make(map[string]*SchemaFingerprint)
+
+	results := NewClusterExecutor(sdu.Db).Run(context.Background(), func(goCtx context.Context, ctx ContextInterface) (interface{}, error) {
+		return FetchSchemaFingerprint(ctx)
+	})
+
+	err := results.Err()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, result := range results {
+		all[result.ShardName] = result.Value.(*SchemaFingerprint)
+	}
+
+	if _, ok := all[sourceShard]; !ok {
+		return nil, errors.New(fmt.Sprintf("Source shard %s does not exist", sourceShard))
+	}
+
+	return CompareSchemaFingerprints(sourceShard, all), nil
+}
+
+// Results are sorted by shard and object. Shards with the source's hash are skipped.
+func CompareSchemaFingerprints(sourceShard string, all map[string]*SchemaFingerprint) []SchemaDrift {
+	drift := make([]SchemaDrift, 0)
+	source := all[sourceShard]
+
+	for shardName, target := range all {
+		if shardName == sourceShard || target.Hash() == source.Hash() {
+			continue
+		}
+
+		for object, definition := range source.Objects {
+			targetDefinition, ok := target.Objects[object]
+
+			if !ok {
+				drift = append(drift, SchemaDrift{shardName, object, SchemaDriftMissing, definition, ""})
+			} else if targetDefinition != definition {
+				drift = append(drift, SchemaDrift{shardName, object, SchemaDriftConflict, definition, targetDefinition})
+			}
+		}
+
+		for object, definition := range target.Objects {
+			if _, ok := source.Objects[object]; !ok {
+				drift = append(drift, SchemaDrift{shardName, object, SchemaDriftExtra, "", definition})
+			}
+		}
+	}
+
+	sort.Slice(drift, func(i, j int) bool {
+		if drift[i].ShardName != drift[j].ShardName {
+			return drift[i].ShardName < drift[j].ShardName
+		}
+
+		return drift[i].Object < drift[j].Object
+	})
+
+	return drift
+}
+
+// Human readable drift report
+func FormatSchemaDrift(drift []SchemaDrift) string {
+	var lines []string
+
+	if len(drift) == 0 {
+		return "Schemas are consistent.\n"
+	}
+
+	for _, row := range drift {
+		switch row.Kind {
+		case SchemaDriftMissing:
+			lines = append(lines, fmt.Sprintf("%s %s: missing\n\t+ %s", row.ShardName, row.Object, row.Source))
+		case SchemaDriftExtra:
+			lines = append(lines, fmt.Sprintf("%s %s: extra\n\t- %s", row.ShardName, row.Object, row.Target))
+		default:
+			lines = append(lines, fmt.Sprintf("%s %s: conflict\n\t- %s\n\t+ %s", row.ShardName, row.Object, row.Target, row.Source))
+		}
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
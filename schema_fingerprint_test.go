@@ -0,0 +1,53 @@
+// +build unit
+
+package jgoweb
+
+import (
+	"testing"
+)
+
+//
+func TestCompareSchemaFingerprints(t *testing.T) {
+	source := &SchemaFingerprint{Objects: map[string]string{
+		"column public.users.email": "text NO ",
+		"index public.users_email":  "CREATE UNIQUE INDEX users_email ON public.users USING btree (email)",
+	}}
+
+	same := &SchemaFingerprint{Objects: map[string]string{
+		"column public.users.email": "text NO ",
+		"index public.users_email":  "CREATE UNIQUE INDEX users_email ON public.users USING btree (email)",
+	}}
+
+	drifted := &SchemaFingerprint{Objects: map[string]string{
+		"column public.users.email": "text YES ",
+		"column public.users.extra": "integer YES ",
+	}}
+
+	if source.Hash() != same.Hash() || source.Hash() == drifted.Hash() {
+		t.Errorf("\nERROR: Unexpected fingerprint hashes\n")
+	}
+
+	all := map[string]*SchemaFingerprint{"shard_1": source, "shard_2": same, "shard_3": drifted}
+	drift := CompareSchemaFingerprints("shard_1", all)
+
+	expected := []SchemaDrift{
+		{"shard_3", "column public.users.email", SchemaDriftConflict, "text NO ", "text YES "},
+		{"shard_3", "column public.users.extra", SchemaDriftExtra, "", "integer YES "},
+		{"shard_3", "index public.users_email", SchemaDriftMissing, source.Objects["index public.users_email"], ""},
+	}
+
+	if len(drift) != len(expected) {
+		t.Errorf("\nERROR: Expected %d drift rows. Got: %v\n", len(expected), drift)
+		return
+	}
+
+	for i := range expected {
+		if drift[i] != expected[i] {
+			t.Errorf("\nERROR: Row %d\nExpected: %v\nResult: %v\n", i, expected[i], drift[i])
+		}
+	}
+
+	if FormatSchemaDrift(nil) != "Schemas are consistent.\n" {
+		t.Errorf("\nERROR: Unexpected empty report\n")
+	}
+}
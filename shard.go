@@ -1,6 +1,7 @@
 package jgoweb
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -12,13 +13,17 @@ import (
 
 // Shard
 type Shard struct {
-	Id           sql.NullString   `json:"Id" validate:"omitempty,int"`
-	Name         sql.NullString   `json:"Name" validate:"required"`
-	AccountCount sql.NullString   `json:"AccountCount" validate:"required,int"`
-	CreatedAt    sql.NullString   `json:"CreatedAt" validate:"omitempty,rfc3339"`
-	UpdatedAt    sql.NullString   `json:"UpdatedAt" validate:"omitempty,rfc3339"`
-	DeletedAt    sql.NullString   `json:"DeletedAt" validate:"omitempty,rfc3339"`
-	Ctx          ContextInterface `json:"-" validate:"-"`
+	Id                sql.NullString   `json:"Id" validate:"omitempty,int"`
+	Name              sql.NullString   `json:"Name" validate:"required"`
+	AccountCount      sql.NullString   `json:"AccountCount" validate:"required,int"`
+	Weight            sql.NullString   `json:"Weight" validate:"omitempty,numeric"`
+	CapacityLimit     sql.NullString   `json:"CapacityLimit" validate:"omitempty,int"`
+	AcceptingAccounts sql.NullString   `json:"AcceptingAccounts" validate:"omitempty,oneof=true false"`
+	Dsn               sql.NullString   `json:"-" validate:"omitempty"`
+	CreatedAt         sql.NullString   `json:"CreatedAt" validate:"omitempty,rfc3339"`
+	UpdatedAt         sql.NullString   `json:"UpdatedAt" validate:"omitempty,rfc3339"`
+	DeletedAt         sql.NullString   `json:"DeletedAt" validate:"omitempty,rfc3339"`
+	Ctx               ContextInterface `json:"-" validate:"-"`
 }
 
 // Empty new model
@@ -31,6 +36,8 @@ func NewShard(ctx ContextInterface) (*Shard, error) {
 
 // Set defaults
 func (s *Shard) SetDefaults() {
+	s.SetWeight("1")
+	s.SetAcceptingAccounts("true")
 	s.SetCreatedAt(time.Now().Format(time.RFC3339))
 	s.SetUpdatedAt(time.Now().Format(time.RFC3339))
 }
@@ -96,6 +103,12 @@ func (s *Shard) ProcessSubmit(req *web.Request) (string, bool, error) {
 		return "", false, err
 	}
 
+	err = PublishShardRouteChange(s.Ctx.GetDb(), "*")
+
+	if err != nil {
+		return "", false, err
+	}
+
 	return "Shard saved.", true, nil
 }
 
@@ -110,6 +123,10 @@ func (s *Shard) Hydrate(req *web.Request) error {
 	s.SetId(req.PostFormValue("Id"))
 	s.SetName(req.PostFormValue("Name"))
 	s.SetAccountCount(req.PostFormValue("AccountCount"))
+	s.SetWeight(req.PostFormValue("Weight"))
+	s.SetCapacityLimit(req.PostFormValue("CapacityLimit"))
+	s.SetAcceptingAccounts(req.PostFormValue("AcceptingAccounts"))
+	s.SetDsn(req.PostFormValue("Dsn"))
 	s.SetCreatedAt(req.PostFormValue("CreatedAt"))
 	s.SetUpdatedAt(req.PostFormValue("UpdatedAt"))
 	s.SetDeletedAt(req.PostFormValue("DeletedAt"))
@@ -130,11 +147,7 @@ func (s *Shard) Save() error {
 		return err
 	}
 
-	if !s.Id.Valid {
-		return s.Insert()
-	} else {
-		return s.Update()
-	}
+	return SaveInTransaction(s.Ctx, &s.Id.Valid, s.Insert, s.Update)
 }
 
 // Insert a new record
@@ -144,8 +157,12 @@ func (s *Shard) Insert() error {
 INSERT INTO
 system.shards (name,
 	account_count,
+	weight,
+	capacity_limit,
+	accepting_accounts,
+	dsn,
 	deleted_at)
-VALUES ($1,$2,$3)
+VALUES ($1,$2,$3,$4,$5,$6,$7)
 RETURNING id
 `
 
@@ -159,6 +176,10 @@ RETURNING id
 
 	err = stmt.QueryRow(s.Name,
 		s.AccountCount,
+		s.Weight,
+		s.CapacityLimit,
+		s.AcceptingAccounts,
+		s.Dsn,
 		s.DeletedAt).Scan(&s.Id)
 
 	if err != nil {
@@ -181,6 +202,10 @@ func (s *Shard) Update() error {
 		Set("id", s.Id).
 		Set("name", s.Name).
 		Set("account_count", s.AccountCount).
+		Set("weight", s.Weight).
+		Set("capacity_limit", s.CapacityLimit).
+		Set("accepting_accounts", s.AcceptingAccounts).
+		Set("dsn", s.Dsn).
 		Set("updated_at", s.UpdatedAt).
 		Set("deleted_at", s.DeletedAt).
 		Where("id = ?", s.Id).
@@ -307,6 +332,102 @@ func (s *Shard) SetAccountCount(val string) {
 	s.AccountCount.String = val
 }
 
+//
+func (s *Shard) GetWeight() string {
+
+	if s.Weight.Valid {
+		return s.Weight.String
+	}
+
+	return ""
+}
+
+//
+func (s *Shard) SetWeight(val string) {
+
+	if val == "" {
+		s.Weight.Valid = false
+		s.Weight.String = ""
+
+		return
+	}
+
+	s.Weight.Valid = true
+	s.Weight.String = val
+}
+
+//
+func (s *Shard) GetCapacityLimit() string {
+
+	if s.CapacityLimit.Valid {
+		return s.CapacityLimit.String
+	}
+
+	return ""
+}
+
+//
+func (s *Shard) SetCapacityLimit(val string) {
+
+	if val == "" {
+		s.CapacityLimit.Valid = false
+		s.CapacityLimit.String = ""
+
+		return
+	}
+
+	s.CapacityLimit.Valid = true
+	s.CapacityLimit.String = val
+}
+
+//
+func (s *Shard) GetAcceptingAccounts() string {
+
+	if s.AcceptingAccounts.Valid {
+		return s.AcceptingAccounts.String
+	}
+
+	return ""
+}
+
+//
+func (s *Shard) SetAcceptingAccounts(val string) {
+
+	if val == "" {
+		s.AcceptingAccounts.Valid = false
+		s.AcceptingAccounts.String = ""
+
+		return
+	}
+
+	s.AcceptingAccounts.Valid = true
+	s.AcceptingAccounts.String = val
+}
+
+//
+func (s *Shard) GetDsn() string {
+
+	if s.Dsn.Valid {
+		return s.Dsn.String
+	}
+
+	return ""
+}
+
+//
+func (s *Shard) SetDsn(val string) {
+
+	if val == "" {
+		s.Dsn.Valid = false
+		s.Dsn.String = ""
+
+		return
+	}
+
+	s.Dsn.Valid = true
+	s.Dsn.String = val
+}
+
 //
 func (s *Shard) GetCreatedAt() string {
 
@@ -396,88 +517,19 @@ func (s *Shard) NewWebContext() (ContextInterface, error) {
 	return curCtx, nil
 }
 
-//
-func FetchBestShard(ctx ContextInterface) (*Shard, error) {
-	var shard *Shard
-
-	// Shard data is stored on every DB
-	dbConn, err := ctx.GetDb().GetRandomConn()
-
-	if err != nil {
-		return nil, err
-	}
-
-	dbSess := dbConn.NewSession(nil)
-
-	stmt := dbSess.SelectBySql(`
-SELECT s.*, main.count
-FROM shards s
-JOIN (
-	SELECT
-		shard_id,
-		count(DISTINCT account_id)
-	FROM system.shard_map
-	WHERE deleted_at IS NULL
-	GROUP BY shard_id
-) as main ON main.shard_id = s.id
-WHERE s.deleted_at IS NULL
-ORDER BY count, shard_id
-LIMIT 1`)
-
-	_, err = stmt.Load(&shard)
-
-	if err != nil {
-		return nil, err
-	}
-
-	shard.Ctx = ctx
+// Does the shard take new accounts
+func (s *Shard) IsAcceptingAccounts() bool {
+	return !s.DeletedAt.Valid && s.GetAcceptingAccounts() != "false"
+}
 
-	return shard, nil
+// Pick a shard for a new account using the current placement strategy
+func FetchBestShard(ctx ContextInterface) (*Shard, error) {
+	return GetShardPlacement().SelectShard(ctx)
 }
 
 //
 func FetchShardByAccountId(ctx ContextInterface, accountId string) (*Shard, error) {
-	var shards []Shard
-
-	// Shard data is stored on every DB
-	dbConn, err := ctx.GetDb().GetRandomConn()
-
-	if err != nil {
-		return nil, err
-	}
-
-	dbSess := dbConn.NewSession(nil)
-
-	stmt := dbSess.SelectBySql(`
-	SELECT
-		s.*
-	FROM system.shard_map sm
-	JOIN system.shards s ON s.id = sm.shard_id
-	WHERE sm.account_id = ?
-	LIMIT 1`,
-		accountId)
-
-	_, err = stmt.Load(&shards)
-
-	if err != nil {
-		return nil, err
-	}
-
-	if len(shards) == 0 {
-		return nil, nil
-	}
-
-	// Set db session for this shard
-	dbSess, err = ctx.GetDb().GetSessionByName(shards[0].GetName())
-
-	if err != nil {
-		return nil, err
-	}
-
-	ctx.SetDbSession(dbSess)
-	shards[0].Ctx = ctx
-
-	return &shards[0], nil
+	return fetchShardByRoute(ctx, shardRouteKey("account", accountId), "account_id", accountId)
 }
 
 // Does not alter web context... These fetches/gets are a bit confusing :/ Be careful
@@ -563,95 +615,94 @@ func CreateShardByName(ctx ContextInterface, shardName string) (*Shard, error) {
 
 //
 func FetchShardByEmail(ctx ContextInterface, email string) (*Shard, error) {
-	var shards []Shard
-
-	// Shard data is stored on every DB
-	dbConn, err := ctx.GetDb().GetRandomConn()
+	shard, err := fetchShardByRoute(ctx, shardRouteKey("email", email), "domain", email)
 
 	if err != nil {
 		return nil, err
 	}
 
-	dbSess := dbConn.NewSession(nil)
+	if shard == nil {
+		return FetchShardByDomain(ctx, email)
+	}
 
-	stmt := dbSess.SelectBySql(`
-	SELECT
-		s.*
-	FROM system.shard_map sm
-	JOIN system.shards s ON s.id = sm.shard_id
-	WHERE sm.domain = ?
-	LIMIT 1`,
-		email)
+	return shard, nil
+}
 
-	_, err = stmt.Load(&shards)
+//
+func FetchShardByDomain(ctx ContextInterface, email string) (*Shard, error) {
+	emailParts := strings.Split(email, "@")
+	domain := emailParts[len(emailParts)-1]
 
-	if err != nil {
-		return nil, err
-	}
+	return fetchShardByRoute(ctx, shardRouteKey("domain", domain), "domain", domain)
+}
 
-	if len(shards) == 0 {
-		return FetchShardByDomain(ctx, email)
-	}
+// Account + shard for an exact shard_map domain (i.e., a custom host name). Sets the db session.
+func FetchShardRouteByDomain(ctx ContextInterface, domain string) (*ShardRoute, error) {
+	return fetchShardRoute(ctx, shardRouteKey("domain", domain), "domain", domain)
+}
 
-	// Set db session for this shard
-	dbSess, err = ctx.GetDb().GetSessionByName(shards[0].GetName())
+//
+func fetchShardByRoute(ctx ContextInterface, key string, column string, val string) (*Shard, error) {
+	route, err := fetchShardRoute(ctx, key, column, val)
 
-	if err != nil {
+	if err != nil || route == nil {
 		return nil, err
 	}
 
-	ctx.SetDbSession(dbSess)
-	shards[0].Ctx = ctx
-
-	return &shards[0], nil
+	return &route.Shard, nil
 }
 
-//
-func FetchShardByDomain(ctx ContextInterface, email string) (*Shard, error) {
-	var shards []Shard
+// Look up a route via system.shard_map (cached if enabled) and set the db session for its shard
+func fetchShardRoute(ctx ContextInterface, key string, column string, val string) (*ShardRoute, error) {
+	var rows []shardRouteRow
 
-	// Shard data is stored on every DB
-	dbConn, err := ctx.GetDb().GetRandomConn()
+	route := getCachedShardRoute(key)
 
-	if err != nil {
-		return nil, err
-	}
+	if route == nil {
+		// Shard data is stored on every DB
+		dbConn, err := ctx.GetDb().GetRandomConn()
 
-	dbSess := dbConn.NewSession(nil)
+		if err != nil {
+			return nil, err
+		}
 
-	emailParts := strings.Split(email, "@")
-	domain := emailParts[len(emailParts)-1]
+		dbSess := dbConn.NewSession(nil)
 
-	stmt := dbSess.SelectBySql(`
+		stmt := dbSess.SelectBySql(`
 	SELECT
-		s.*
+		s.*,
+		sm.account_id AS route_account_id
 	FROM system.shard_map sm
 	JOIN system.shards s ON s.id = sm.shard_id
-	WHERE sm.domain = ?
+	WHERE sm.`+column+` = ?
 	LIMIT 1`,
-		domain)
+			val)
 
-	_, err = stmt.Load(&shards)
+		_, err = stmt.Load(&rows)
 
-	if err != nil {
-		return nil, err
-	}
+		if err != nil {
+			return nil, err
+		}
 
-	if len(shards) == 0 {
-		return nil, nil
+		if len(rows) == 0 {
+			return nil, nil
+		}
+
+		route = &ShardRoute{AccountId: rows[0].RouteAccountId.String, Shard: rows[0].Shard}
+		setCachedShardRoute(key, route.AccountId, &route.Shard)
 	}
 
 	// Set db session for this shard
-	dbSess, err = ctx.GetDb().GetSessionByName(shards[0].GetName())
+	dbSess, err := ctx.GetDb().GetSessionByName(route.Shard.GetName())
 
 	if err != nil {
 		return nil, err
 	}
 
 	ctx.SetDbSession(dbSess)
-	shards[0].Ctx = ctx
+	route.Shard.Ctx = ctx
 
-	return &shards[0], nil
+	return route, nil
 }
 
 //
@@ -683,7 +734,7 @@ func GetAllShards(ctx ContextInterface) ([]Shard, error) {
 		From("system.shards").
 		OrderBy("account_count, name")
 
-	_, err := stmt.Load(&s)
+	_, err := stmt.LoadContext(ctx.GetGoContext(), &s)
 
 	if err != nil {
 		return nil, err
@@ -698,18 +749,20 @@ func GetAllShards(ctx ContextInterface) ([]Shard, error) {
 
 // Get shard data for all DBs
 func ClusterGetShards(ctx ContextInterface) (map[string][]Shard, error) {
-	var err error
 	shards := make(map[string][]Shard)
 
-	for dbName, dbConn := range ctx.GetDb().GetConns() {
-		curCtx := NewContext(ctx.GetDb())
-		curCtx.SetDbSession(dbConn.NewSession(nil))
+	results := NewClusterExecutor(ctx.GetDb()).Run(context.Background(), func(goCtx context.Context, shardCtx ContextInterface) (interface{}, error) {
+		return GetAllShards(shardCtx)
+	})
 
-		shards[dbName], err = GetAllShards(curCtx)
+	err := results.Err()
 
-		if err != nil {
-			return nil, err
-		}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, result := range results {
+		shards[result.ShardName] = result.Value.([]Shard)
 	}
 
 	return shards, nil
@@ -717,96 +770,108 @@ func ClusterGetShards(ctx ContextInterface) (map[string][]Shard, error) {
 
 //
 func ClusterAddShard(ctx ContextInterface, shardName string) error {
-	var err error
-	var shard *Shard
-	var msg string
+	return ClusterAddShardWithDsn(ctx, shardName, "")
+}
 
-	for dbName, dbConn := range ctx.GetDb().GetConns() {
-		curCtx := NewContext(ctx.GetDb())
-		curCtx.SetDbSession(dbConn.NewSession(nil))
+// With a DSN, nodes using the "shards" reload source connect to the new shard without a restart
+func ClusterAddShardWithDsn(ctx ContextInterface, shardName string, dsn string) error {
+	ct := NewClusterTransaction(ctx.GetDb())
+	ct.NotifyShardRouteChange("*")
 
-		shard, err = CreateShardByName(curCtx, shardName)
+	return ct.Run(func(dbName string, curCtx ContextInterface) error {
+		err := CheckShardColumns(curCtx)
 
 		if err != nil {
-			msg += dbName + ": " + err.Error() + "\n"
-		} else {
-			err = shard.Save()
-
-			if err != nil {
-				msg += dbName + ": " + err.Error() + "\n"
-			}
+			return errors.New(dbName + ": " + err.Error())
 		}
-	}
 
-	if msg != "" {
-		err = errors.New(msg)
+		shard, err := CreateShardByName(curCtx, shardName)
 
-		return err
-	}
+		if err != nil {
+			return err
+		}
 
-	return nil
+		if dsn != "" {
+			shard.SetDsn(dsn)
+		}
+
+		return shard.Save()
+	})
 }
 
 //
 func ClusterDeleteShard(ctx ContextInterface, shardName string) error {
-	var err error
-	var shard *Shard
-	var msg string
+	ct := NewClusterTransaction(ctx.GetDb())
+	ct.NotifyShardRouteChange("*")
 
-	for dbName, dbConn := range ctx.GetDb().GetConns() {
-		curCtx := NewContext(ctx.GetDb())
-		curCtx.SetDbSession(dbConn.NewSession(nil))
-
-		shard, err = FetchShardByName(curCtx, shardName)
+	return ct.Run(func(dbName string, curCtx ContextInterface) error {
+		shard, err := FetchShardByName(curCtx, shardName)
 
 		if err != nil {
-			msg += dbName + ": " + err.Error() + "\n"
-		} else if shard != nil {
-			err = shard.Delete()
-
-			if err != nil {
-				msg += dbName + ": " + err.Error() + "\n"
-			}
+			return err
 		}
-	}
-
-	if msg != "" {
-		err = errors.New(msg)
 
-		return err
-	}
+		if shard == nil {
+			return nil
+		}
 
-	return nil
+		return shard.Delete()
+	})
 }
 
 //
 func ClusterUndeleteShard(ctx ContextInterface, shardName string) error {
-	var err error
-	var shard *Shard
-	var msg string
+	ct := NewClusterTransaction(ctx.GetDb())
+	ct.NotifyShardRouteChange("*")
 
-	for dbName, dbConn := range ctx.GetDb().GetConns() {
-		curCtx := NewContext(ctx.GetDb())
-		curCtx.SetDbSession(dbConn.NewSession(nil))
-
-		shard, err = FetchShardByName(curCtx, shardName)
+	return ct.Run(func(dbName string, curCtx ContextInterface) error {
+		shard, err := FetchShardByName(curCtx, shardName)
 
 		if err != nil {
-			msg += dbName + ": " + err.Error() + "\n"
-		} else if shard != nil {
-			err = shard.Undelete()
+			return err
+		}
 
-			if err != nil {
-				msg += dbName + ": " + err.Error() + "\n"
-			}
+		if shard == nil {
+			return nil
 		}
-	}
 
-	if msg != "" {
-		err = errors.New(msg)
+		return shard.Undelete()
+	})
+}
+
+// Added to system.shards by GetJgowebDbUpdates
+var jgowebShardColumns = []string{"weight", "capacity_limit", "accepting_accounts", "dsn"}
+
+// Shard writes need the columns added by jgoweb's own DB updates
+func CheckShardColumns(ctx ContextInterface) error {
+	var columns []string
+
+	_, err := ctx.Select("column_name").
+		From("information_schema.columns").
+		Where("table_schema = 'system' AND table_name = 'shards' AND column_name IN ?", jgowebShardColumns).
+		LoadContext(ctx.GetGoContext(), &columns)
 
+	if err != nil {
 		return err
 	}
 
+	var missing []string
+	found := make(map[string]bool)
+
+	for _, column := range columns {
+		found[column] = true
+	}
+
+	for _, column := range jgowebShardColumns {
+
+		if !found[column] {
+			missing = append(missing, column)
+		}
+	}
+
+	if len(missing) > 0 {
+		return errors.New(fmt.Sprintf("system.shards is missing column(s) %s. Run the jgoweb DB updates (GetJgowebDbUpdates, or jgoweb migrations run) first.", strings.Join(missing, ", ")))
+	}
+
 	return nil
 }
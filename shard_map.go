@@ -2,7 +2,6 @@ package jgoweb
 
 import (
 	"database/sql"
-	"errors"
 	"github.com/gocraft/dbr"
 	"github.com/gocraft/web"
 	"github.com/jschneider98/jgoweb/util"
@@ -96,6 +95,12 @@ func (sm *ShardMap) ProcessSubmit(req *web.Request) (string, bool, error) {
 		return "", false, err
 	}
 
+	err = PublishShardRouteChange(sm.Ctx.GetDb(), sm.GetAccountId())
+
+	if err != nil {
+		return "", false, err
+	}
+
 	return "Shard Map saved.", true, nil
 }
 
@@ -131,11 +136,7 @@ func (sm *ShardMap) Save() error {
 		return err
 	}
 
-	if !sm.Id.Valid {
-		return sm.Insert()
-	} else {
-		return sm.Update()
-	}
+	return SaveInTransaction(sm.Ctx, &sm.Id.Valid, sm.Insert, sm.Update)
 }
 
 // Insert a new record
@@ -509,32 +510,16 @@ func GetAllShardMaps(ctx ContextInterface) ([]ShardMap, error) {
 
 //
 func ClusterAddShardMap(ctx ContextInterface, shardId string, domain string, accountId string) error {
-	var err error
-	var shardMap *ShardMap
-	var msg string
-
-	for dbName, dbConn := range ctx.GetDb().GetConns() {
-		curCtx := NewContext(ctx.GetDb())
-		curCtx.SetDbSession(dbConn.NewSession(nil))
+	ct := NewClusterTransaction(ctx.GetDb())
+	ct.NotifyShardRouteChange(accountId)
 
-		shardMap, err = CreateShardMap(curCtx, shardId, domain, accountId)
+	return ct.Run(func(dbName string, curCtx ContextInterface) error {
+		shardMap, err := CreateShardMap(curCtx, shardId, domain, accountId)
 
 		if err != nil {
-			msg += dbName + ": " + err.Error() + "\n"
-		} else {
-			err = shardMap.Save()
-
-			if err != nil {
-				msg += dbName + ": " + err.Error() + "\n"
-			}
+			return err
 		}
-	}
 
-	if msg != "" {
-		err = errors.New(msg)
-
-		return err
-	}
-
-	return nil
+		return shardMap.Save()
+	})
 }
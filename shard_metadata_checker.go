@@ -0,0 +1,485 @@
+package jgoweb
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	jgoWebDb "github.com/jschneider98/jgoweb/db"
+	"sort"
+	"strings"
+)
+
+const (
+	ShardMetadataMissing  = "missing"
+	ShardMetadataExtra    = "extra"
+	ShardMetadataConflict = "conflict"
+)
+
+const ShardMetadataRepairLock = "jgoweb.shard_metadata_repair"
+
+// Difference between the source of truth and one DB's copy of the shard metadata
+type ShardMetadataDiff struct {
+	ShardName string `json:"shardName"`
+	Table     string `json:"table"`
+	Key       string `json:"key"`
+	Kind      string `json:"kind"`
+	Source    string `json:"source"`
+	Target    string `json:"target"`
+}
+
+// Shard metadata as stored in a single DB
+type ShardMetadata struct {
+	Shards    map[string]ShardMetadataShard
+	ShardMaps map[string]ShardMetadataShardMap
+}
+
+//
+type ShardMetadataShard struct {
+	Id                sql.NullString `db:"id"`
+	Name              sql.NullString `db:"name"`
+	AccountCount      sql.NullString `db:"account_count"`
+	Weight            sql.NullString `db:"weight"`
+	CapacityLimit     sql.NullString `db:"capacity_limit"`
+	AcceptingAccounts sql.NullString `db:"accepting_accounts"`
+	Dsn               sql.NullString `db:"dsn"`
+	DeletedAt         sql.NullString `db:"deleted_at"`
+}
+
+//
+type ShardMetadataShardMap struct {
+	ShardId   sql.NullString `db:"shard_id"`
+	Domain    sql.NullString `db:"domain"`
+	AccountId sql.NullString `db:"account_id"`
+	DeletedAt sql.NullString `db:"deleted_at"`
+}
+
+// Compares system.shards and system.shard_map across every DB in the collection and
+// optionally reconciles every copy from a source of truth.
+type ShardMetadataChecker struct {
+	Db          *jgoWebDb.Collection
+	SourceShard string
+	DryRun      bool
+}
+
+//
+func NewShardMetadataChecker(db *jgoWebDb.Collection, sourceShard string) *ShardMetadataChecker {
+	return &ShardMetadataChecker{Db: db, SourceShard: sourceShard}
+}
+
+// Load shard metadata from one DB
+func FetchShardMetadata(ctx ContextInterface) (*ShardMetadata, error) {
+	var shards []ShardMetadataShard
+	var shardMaps []ShardMetadataShardMap
+
+	md := &ShardMetadata{}
+	md.Shards = make(map[string]ShardMetadataShard)
+	md.ShardMaps = make(map[string]ShardMetadataShardMap)
+
+	_, err := ctx.Select("id", "name", "account_count", "weight", "capacity_limit", "accepting_accounts", "dsn", "deleted_at").
+		From("system.shards").
+		LoadContext(ctx.GetGoContext(), &shards)
+
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = ctx.Select("shard_id", "domain", "account_id", "deleted_at").
+		From("system.shard_map").
+		LoadContext(ctx.GetGoContext(), &shardMaps)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range shards {
+		md.Shards[row.Key()] = row
+	}
+
+	for _, row := range shardMaps {
+		md.ShardMaps[row.Key()] = row
+	}
+
+	return md, nil
+}
+
+//
+func (row ShardMetadataShard) Key() string {
+	return row.Name.String
+}
+
+// The dsn is shown as a hash so reports don't leak credentials
+func (row ShardMetadataShard) String() string {
+	dsn := ""
+
+	if row.Dsn.Valid {
+		sum := sha256.Sum256([]byte(row.Dsn.String))
+		dsn = hex.EncodeToString(sum[:])[:12]
+	}
+
+	return fmt.Sprintf(
+		"id=%s account_count=%s weight=%s capacity_limit=%s accepting_accounts=%s dsn=%s deleted_at=%s",
+		row.Id.String,
+		row.AccountCount.String,
+		row.Weight.String,
+		row.CapacityLimit.String,
+		row.AcceptingAccounts.String,
+		dsn,
+		row.DeletedAt.String,
+	)
+}
+
+//
+func (row ShardMetadataShardMap) Key() string {
+	return row.AccountId.String + "/" + row.Domain.String
+}
+
+//
+func (row ShardMetadataShardMap) String() string {
+	return fmt.Sprintf("shard_id=%s deleted_at=%s", row.ShardId.String, row.DeletedAt.String)
+}
+
+// Load metadata from every DB
+func (smc *ShardMetadataChecker) FetchAll() (map[string]*ShardMetadata, error) {
+	all := make(map[string]*ShardMetadata)
+
+	results := NewClusterExecutor(smc.Db).Run(context.Background(), func(goCtx context.Context, ctx ContextInterface) (interface{}, error) {
+		return FetchShardMetadata(ctx)
+	})
+
+	err := results.Err()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, result := range results {
+		all[result.ShardName] = result.Value.(*ShardMetadata)
+	}
+
+	if _, ok := all[smc.SourceShard]; !ok {
+		return nil, errors.New(fmt.Sprintf("Source shard %s does not exist", smc.SourceShard))
+	}
+
+	return all, nil
+}
+
+// Report differences between the source of truth and every other DB
+func (smc *ShardMetadataChecker) Check() ([]ShardMetadataDiff, error) {
+	all, err := smc.FetchAll()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return smc.Compare(all), nil
+}
+
+// Compare loaded metadata. Results are sorted by shard, table and key.
+func (smc *ShardMetadataChecker) Compare(all map[string]*ShardMetadata) []ShardMetadataDiff {
+	diffs := make([]ShardMetadataDiff, 0)
+	source := all[smc.SourceShard]
+
+	for shardName, target := range all {
+		if shardName == smc.SourceShard {
+			continue
+		}
+
+		for key, srcRow := range source.Shards {
+			row, ok := target.Shards[key]
+
+			if !ok {
+				diffs = append(diffs, ShardMetadataDiff{shardName, "system.shards", key, ShardMetadataMissing, srcRow.String(), ""})
+			} else if row.String() != srcRow.String() {
+				diffs = append(diffs, ShardMetadataDiff{shardName, "system.shards", key, ShardMetadataConflict, srcRow.String(), row.String()})
+			}
+		}
+
+		for key, row := range target.Shards {
+			if _, ok := source.Shards[key]; !ok {
+				diffs = append(diffs, ShardMetadataDiff{shardName, "system.shards", key, ShardMetadataExtra, "", row.String()})
+			}
+		}
+
+		for key, srcRow := range source.ShardMaps {
+			row, ok := target.ShardMaps[key]
+
+			if !ok {
+				diffs = append(diffs, ShardMetadataDiff{shardName, "system.shard_map", key, ShardMetadataMissing, srcRow.String(), ""})
+			} else if row.String() != srcRow.String() {
+				diffs = append(diffs, ShardMetadataDiff{shardName, "system.shard_map", key, ShardMetadataConflict, srcRow.String(), row.String()})
+			}
+		}
+
+		for key, row := range target.ShardMaps {
+			if _, ok := source.ShardMaps[key]; !ok {
+				diffs = append(diffs, ShardMetadataDiff{shardName, "system.shard_map", key, ShardMetadataExtra, "", row.String()})
+			}
+		}
+	}
+
+	sort.Slice(diffs, func(i, j int) bool {
+		if diffs[i].ShardName != diffs[j].ShardName {
+			return diffs[i].ShardName < diffs[j].ShardName
+		}
+
+		if diffs[i].Table != diffs[j].Table {
+			return diffs[i].Table < diffs[j].Table
+		}
+
+		return diffs[i].Key < diffs[j].Key
+	})
+
+	return diffs
+}
+
+// Reconcile every copy from the source of truth (atomically, across the cluster).
+// In dry run mode nothing is changed and the diffs that would be applied are returned.
+// Only one repair runs at a time (locked on the source shard).
+func (smc *ShardMetadataChecker) Repair() ([]ShardMetadataDiff, error) {
+	dbSess, err := smc.Db.GetSessionByName(smc.SourceShard)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := NewContext(smc.Db)
+	ctx.SetDbSession(dbSess)
+
+	lock, err := ctx.TryAdvisoryLock(ShardMetadataRepairLock, 0)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if lock == nil {
+		return nil, errors.New("Shard metadata repair is already running.")
+	}
+
+	defer lock.Unlock()
+
+	all, err := smc.FetchAll()
+
+	if err != nil {
+		return nil, err
+	}
+
+	diffs := smc.Compare(all)
+
+	if smc.DryRun || len(diffs) == 0 {
+		return diffs, nil
+	}
+
+	source := all[smc.SourceShard]
+
+	ct := NewClusterTransaction(smc.Db)
+	ct.NotifyShardRouteChange("*")
+
+	err = ct.Run(func(shardName string, ctx ContextInterface) error {
+
+		if shardName == smc.SourceShard {
+			return nil
+		}
+
+		return smc.RepairShard(ctx, source, all[shardName])
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return diffs, nil
+}
+
+// Changes needed to make one DB's copy match the source
+type ShardMetadataRepairPlan struct {
+	DeleteShardMaps []ShardMetadataShardMap
+	DeleteShards    []ShardMetadataShard
+	InsertShards    []ShardMetadataShard
+	UpdateShards    []ShardMetadataShard
+	InsertShardMaps []ShardMetadataShardMap
+	UpdateShardMaps []ShardMetadataShardMap
+}
+
+// Plan a repair. Target shards that are extra, or that hold a different id than the source, are deleted
+// (along with any shard_map rows pointing at them) and re-inserted from the source. Everything that's
+// left after the deletes matches the source's ids and keys, so the inserts can't collide with it.
+func PlanShardMetadataRepair(source *ShardMetadata, target *ShardMetadata) *ShardMetadataRepairPlan {
+	plan := &ShardMetadataRepairPlan{}
+	deletedIds := make(map[string]bool)
+
+	for key, row := range target.Shards {
+		srcRow, ok := source.Shards[key]
+
+		if !ok || srcRow.Id != row.Id {
+			plan.DeleteShards = append(plan.DeleteShards, row)
+			deletedIds[row.Id.String] = true
+		}
+	}
+
+	for key, srcRow := range source.Shards {
+		row, ok := target.Shards[key]
+
+		if !ok || row.Id != srcRow.Id {
+			plan.InsertShards = append(plan.InsertShards, srcRow)
+		} else if row.String() != srcRow.String() {
+			plan.UpdateShards = append(plan.UpdateShards, srcRow)
+		}
+	}
+
+	for key, row := range target.ShardMaps {
+		_, ok := source.ShardMaps[key]
+
+		if !ok || deletedIds[row.ShardId.String] {
+			plan.DeleteShardMaps = append(plan.DeleteShardMaps, row)
+		}
+	}
+
+	for key, srcRow := range source.ShardMaps {
+		row, ok := target.ShardMaps[key]
+
+		if !ok || deletedIds[row.ShardId.String] {
+			plan.InsertShardMaps = append(plan.InsertShardMaps, srcRow)
+		} else if row.String() != srcRow.String() {
+			plan.UpdateShardMaps = append(plan.UpdateShardMaps, srcRow)
+		}
+	}
+
+	plan.sort()
+
+	return plan
+}
+
+// Deterministic order (maps are unordered)
+func (plan *ShardMetadataRepairPlan) sort() {
+	for _, rows := range [][]ShardMetadataShard{plan.DeleteShards, plan.InsertShards, plan.UpdateShards} {
+		sort.Slice(rows, func(i, j int) bool { return rows[i].Key() < rows[j].Key() })
+	}
+
+	for _, rows := range [][]ShardMetadataShardMap{plan.DeleteShardMaps, plan.InsertShardMaps, plan.UpdateShardMaps} {
+		sort.Slice(rows, func(i, j int) bool { return rows[i].Key() < rows[j].Key() })
+	}
+}
+
+// Make target match source. Order matters because shard_map references shards and ids/names are unique:
+// deletes run first, then shards are written, then shard_map.
+func (smc *ShardMetadataChecker) RepairShard(ctx ContextInterface, source *ShardMetadata, target *ShardMetadata) error {
+	var seq []string
+
+	plan := PlanShardMetadataRepair(source, target)
+
+	for _, row := range plan.DeleteShardMaps {
+		_, err := ctx.DeleteFrom("system.shard_map").
+			Where("account_id = ?", row.AccountId).
+			Where("domain = ?", row.Domain).
+			ExecContext(ctx.GetGoContext())
+
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, row := range plan.DeleteShards {
+		_, err := ctx.DeleteFrom("system.shards").
+			Where("name = ?", row.Name).
+			ExecContext(ctx.GetGoContext())
+
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, row := range plan.InsertShards {
+		_, err := ctx.InsertInto("system.shards").
+			Pair("id", row.Id).
+			Pair("name", row.Name).
+			Pair("account_count", row.AccountCount).
+			Pair("weight", row.Weight).
+			Pair("capacity_limit", row.CapacityLimit).
+			Pair("accepting_accounts", row.AcceptingAccounts).
+			Pair("dsn", row.Dsn).
+			Pair("deleted_at", row.DeletedAt).
+			ExecContext(ctx.GetGoContext())
+
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, row := range plan.UpdateShards {
+		_, err := ctx.Update("system.shards").
+			Set("account_count", row.AccountCount).
+			Set("weight", row.Weight).
+			Set("capacity_limit", row.CapacityLimit).
+			Set("accepting_accounts", row.AcceptingAccounts).
+			Set("dsn", row.Dsn).
+			Set("deleted_at", row.DeletedAt).
+			Where("name = ?", row.Name).
+			ExecContext(ctx.GetGoContext())
+
+		if err != nil {
+			return err
+		}
+	}
+
+	// Ids were copied from the source, so keep the sequence ahead of them
+	_, err := ctx.SelectBySql(`
+	SELECT setval(pg_get_serial_sequence('system.shards', 'id'), GREATEST(max(id), 1))::text
+	FROM system.shards`).LoadContext(ctx.GetGoContext(), &seq)
+
+	if err != nil {
+		return err
+	}
+
+	for _, row := range plan.InsertShardMaps {
+		_, err := ctx.InsertInto("system.shard_map").
+			Pair("shard_id", row.ShardId).
+			Pair("domain", row.Domain).
+			Pair("account_id", row.AccountId).
+			Pair("deleted_at", row.DeletedAt).
+			ExecContext(ctx.GetGoContext())
+
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, row := range plan.UpdateShardMaps {
+		_, err := ctx.Update("system.shard_map").
+			Set("shard_id", row.ShardId).
+			Set("deleted_at", row.DeletedAt).
+			Where("account_id = ?", row.AccountId).
+			Where("domain = ?", row.Domain).
+			ExecContext(ctx.GetGoContext())
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Human readable diff report
+func FormatShardMetadataDiffs(diffs []ShardMetadataDiff) string {
+	var lines []string
+
+	if len(diffs) == 0 {
+		return "Shard metadata is consistent.\n"
+	}
+
+	for _, diff := range diffs {
+		switch diff.Kind {
+		case ShardMetadataMissing:
+			lines = append(lines, fmt.Sprintf("%s %s [%s]: missing\n\t+ %s", diff.ShardName, diff.Table, diff.Key, diff.Source))
+		case ShardMetadataExtra:
+			lines = append(lines, fmt.Sprintf("%s %s [%s]: extra\n\t- %s", diff.ShardName, diff.Table, diff.Key, diff.Target))
+		default:
+			lines = append(lines, fmt.Sprintf("%s %s [%s]: conflict\n\t- %s\n\t+ %s", diff.ShardName, diff.Table, diff.Key, diff.Target, diff.Source))
+		}
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
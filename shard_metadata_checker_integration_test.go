@@ -0,0 +1,108 @@
+// +build integration
+
+package jgoweb
+
+import (
+	"database/sql"
+	"strconv"
+	"testing"
+)
+
+//
+func TestShardMetadataCheckerCheck(t *testing.T) {
+	InitMockCtx()
+
+	smc := NewShardMetadataChecker(MockCtx.GetDb(), appConfig.Integration.ShardName)
+
+	_, err := smc.Check()
+
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+	}
+}
+
+//
+func TestShardMetadataCheckerRepairDryRun(t *testing.T) {
+	InitMockCtx()
+
+	smc := NewShardMetadataChecker(MockCtx.GetDb(), appConfig.Integration.ShardName)
+	smc.DryRun = true
+
+	_, err := smc.Repair()
+
+	if err != nil {
+		t.Errorf("ERROR: %v", err)
+	}
+}
+
+// Source's id is held by an extra target row, so the extra has to be deleted before the insert
+func TestShardMetadataCheckerRepairShard(t *testing.T) {
+	InitMockCtx()
+
+	smc := NewShardMetadataChecker(MockCtx.GetDb(), appConfig.Integration.ShardName)
+	maxId := 0
+
+	source, err := FetchShardMetadata(MockCtx)
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+		return
+	}
+
+	for _, row := range source.Shards {
+		id, _ := strconv.Atoi(row.Id.String)
+
+		if id > maxId {
+			maxId = id
+		}
+	}
+
+	// copy an existing row so values come back formatted the same way
+	row := source.Shards[MockShard.GetName()]
+	row.Id = sql.NullString{String: strconv.Itoa(maxId + 1), Valid: true}
+	row.Name = sql.NullString{String: "repair_test", Valid: true}
+
+	source.Shards[row.Key()] = row
+
+	_, err = MockCtx.InsertInto("system.shards").
+		Pair("id", row.Id).
+		Pair("name", "repair_test_extra").
+		Pair("account_count", row.AccountCount).
+		Pair("weight", row.Weight).
+		Pair("capacity_limit", row.CapacityLimit).
+		Pair("accepting_accounts", row.AcceptingAccounts).
+		Pair("dsn", row.Dsn).
+		Exec()
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+		return
+	}
+
+	target, err := FetchShardMetadata(MockCtx)
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+		return
+	}
+
+	err = smc.RepairShard(MockCtx, source, target)
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+		return
+	}
+
+	target, err = FetchShardMetadata(MockCtx)
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+		return
+	}
+
+	diffs := smc.Compare(map[string]*ShardMetadata{smc.SourceShard: source, "repaired": target})
+
+	if len(diffs) != 0 {
+		t.Errorf("\nERROR: Expected no differences after repair. Got:\n%s\n", FormatShardMetadataDiffs(diffs))
+	}
+}
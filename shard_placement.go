@@ -0,0 +1,207 @@
+package jgoweb
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+)
+
+var shardPlacement ShardPlacementInterface = NewShardPlacementWeighted()
+
+// Chooses the shard for a new account
+type ShardPlacementInterface interface {
+	SelectShard(ctx ContextInterface) (*Shard, error)
+}
+
+// Shard plus the load metrics used for placement
+type ShardPlacementCandidate struct {
+	Shard       Shard
+	NumAccounts int64
+	DbSize      int64
+}
+
+//
+func SetShardPlacement(sp ShardPlacementInterface) {
+	shardPlacement = sp
+}
+
+//
+func GetShardPlacement() ShardPlacementInterface {
+	return shardPlacement
+}
+
+// Least loaded shard relative to its weight. Load is the number of accounts, or the DB size
+// (pg_database_size) when UseDbSize is set. Shards that are deleted, closed to new accounts or
+// at their capacity limit are skipped.
+type ShardPlacementWeighted struct {
+	UseDbSize bool
+}
+
+//
+func NewShardPlacementWeighted() *ShardPlacementWeighted {
+	return &ShardPlacementWeighted{}
+}
+
+//
+func (sp *ShardPlacementWeighted) SelectShard(ctx ContextInterface) (*Shard, error) {
+	candidates, err := GetShardPlacementCandidates(ctx, sp.UseDbSize)
+
+	if err != nil {
+		return nil, err
+	}
+
+	shard, err := sp.Choose(candidates)
+
+	if err != nil {
+		return nil, err
+	}
+
+	shard.Ctx = ctx
+
+	return shard, nil
+}
+
+//
+func (sp *ShardPlacementWeighted) Choose(candidates []ShardPlacementCandidate) (*Shard, error) {
+	var best *Shard
+	var bestScore float64
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Shard.GetName() < candidates[j].Shard.GetName()
+	})
+
+	for key := range candidates {
+		candidate := &candidates[key]
+
+		if !candidate.Shard.IsAcceptingAccounts() {
+			continue
+		}
+
+		limit, err := strconv.ParseInt(candidate.Shard.GetCapacityLimit(), 10, 64)
+
+		if err == nil && candidate.NumAccounts >= limit {
+			continue
+		}
+
+		weight, err := strconv.ParseFloat(candidate.Shard.GetWeight(), 64)
+
+		if err != nil {
+			weight = 1
+		}
+
+		if weight <= 0 {
+			continue
+		}
+
+		load := float64(candidate.NumAccounts)
+
+		if sp.UseDbSize {
+			load = float64(candidate.DbSize)
+		}
+
+		score := load / weight
+
+		if best == nil || score < bestScore {
+			best = &candidate.Shard
+			bestScore = score
+		}
+	}
+
+	if best == nil {
+		return nil, errors.New("No shard is accepting new accounts")
+	}
+
+	return best, nil
+}
+
+// Active shards with a connection in the DB collection, along with their account counts (and optionally DB size)
+func GetShardPlacementCandidates(ctx ContextInterface, withDbSize bool) ([]ShardPlacementCandidate, error) {
+	var shards []Shard
+	var counts []struct {
+		ShardId     string `db:"shard_id"`
+		NumAccounts int64  `db:"num_accounts"`
+	}
+
+	candidates := make([]ShardPlacementCandidate, 0)
+
+	// Shard data is stored on every DB
+	dbConn, err := ctx.GetDb().GetRandomConn()
+
+	if err != nil {
+		return nil, err
+	}
+
+	dbSess := dbConn.NewSession(nil)
+
+	_, err = dbSess.Select("*").
+		From("system.shards").
+		Where("deleted_at IS NULL").
+		OrderBy("name").
+		Load(&shards)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// LEFT JOIN so empty shards are included
+	_, err = dbSess.SelectBySql(`
+	SELECT
+		s.id AS shard_id,
+		count(DISTINCT sm.account_id) AS num_accounts
+	FROM system.shards s
+	LEFT JOIN system.shard_map sm ON sm.shard_id = s.id AND sm.deleted_at IS NULL
+	WHERE s.deleted_at IS NULL
+	GROUP BY s.id`).Load(&counts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	numAccounts := make(map[string]int64)
+
+	for _, count := range counts {
+		numAccounts[count.ShardId] = count.NumAccounts
+	}
+
+	shardNames := make([]string, 0)
+
+	for _, shard := range shards {
+		if _, err := ctx.GetDb().GetConnByName(shard.GetName()); err != nil {
+			continue
+		}
+
+		shardNames = append(shardNames, shard.GetName())
+		candidates = append(candidates, ShardPlacementCandidate{Shard: shard, NumAccounts: numAccounts[shard.GetId()]})
+	}
+
+	if !withDbSize || len(shardNames) == 0 {
+		return candidates, nil
+	}
+
+	results := NewClusterExecutor(ctx.GetDb()).SetShardNames(shardNames...).Run(context.Background(), func(goCtx context.Context, shardCtx ContextInterface) (interface{}, error) {
+		var size []int64
+
+		_, err := shardCtx.SelectBySql("SELECT pg_database_size(current_database())").LoadContext(goCtx, &size)
+
+		if err != nil || len(size) == 0 {
+			return int64(0), err
+		}
+
+		return size[0], nil
+	})
+
+	err = results.Err()
+
+	if err != nil {
+		return nil, err
+	}
+
+	sizes := results.Values()
+
+	for key := range candidates {
+		candidates[key].DbSize = sizes[candidates[key].Shard.GetName()].(int64)
+	}
+
+	return candidates, nil
+}
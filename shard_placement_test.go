@@ -0,0 +1,91 @@
+// +build unit
+
+package jgoweb
+
+import (
+	"testing"
+)
+
+//
+func getTestShardPlacementCandidate(name string, numAccounts int64) ShardPlacementCandidate {
+	shard := Shard{}
+	shard.SetName(name)
+	shard.SetWeight("1")
+	shard.SetAcceptingAccounts("true")
+
+	return ShardPlacementCandidate{Shard: shard, NumAccounts: numAccounts}
+}
+
+//
+func TestShardPlacementWeightedChoose(t *testing.T) {
+	sp := NewShardPlacementWeighted()
+
+	candidates := []ShardPlacementCandidate{
+		getTestShardPlacementCandidate("a", 10),
+		getTestShardPlacementCandidate("b", 0),
+		getTestShardPlacementCandidate("c", 5),
+	}
+
+	shard, err := sp.Choose(candidates)
+
+	if err != nil || shard.GetName() != "b" {
+		t.Errorf("ERROR: Expected empty shard b. Got: %v %v", shard, err)
+	}
+
+	// Closed to new accounts
+	candidates[1].Shard.SetAcceptingAccounts("false")
+
+	shard, err = sp.Choose(candidates)
+
+	if err != nil || shard.GetName() != "c" {
+		t.Errorf("ERROR: Expected shard c. Got: %v %v", shard, err)
+	}
+
+	// At capacity
+	candidates[2].Shard.SetCapacityLimit("5")
+
+	shard, err = sp.Choose(candidates)
+
+	if err != nil || shard.GetName() != "a" {
+		t.Errorf("ERROR: Expected shard a. Got: %v %v", shard, err)
+	}
+
+	// Weight
+	candidates[2].Shard.SetCapacityLimit("")
+	candidates[0].Shard.SetWeight("4")
+
+	shard, err = sp.Choose(candidates)
+
+	if err != nil || shard.GetName() != "a" {
+		t.Errorf("ERROR: Expected weighted shard a. Got: %v %v", shard, err)
+	}
+
+	candidates[0].Shard.SetAcceptingAccounts("false")
+	candidates[2].Shard.SetAcceptingAccounts("false")
+
+	_, err = sp.Choose(candidates)
+
+	if err == nil {
+		t.Errorf("ERROR: Expected error when no shard accepts accounts.")
+	}
+}
+
+//
+func TestShardPlacementUseDbSize(t *testing.T) {
+	sp := NewShardPlacementWeighted()
+	sp.UseDbSize = true
+
+	candidates := []ShardPlacementCandidate{
+		getTestShardPlacementCandidate("a", 0),
+		getTestShardPlacementCandidate("b", 10),
+	}
+
+	candidates[0].DbSize = 1000
+	candidates[1].DbSize = 10
+
+	shard, err := sp.Choose(candidates)
+
+	if err != nil || shard.GetName() != "b" {
+		t.Errorf("ERROR: Expected smaller shard b. Got: %v %v", shard, err)
+	}
+}
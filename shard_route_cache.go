@@ -0,0 +1,346 @@
+package jgoweb
+
+import (
+	"container/list"
+	"database/sql"
+	"errors"
+	"fmt"
+	jgoWebDb "github.com/jschneider98/jgoweb/db"
+	"github.com/jschneider98/jgoweb/util"
+	"github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
+	"log"
+	"sync"
+	"time"
+)
+
+// NOTIFY channel used to invalidate routing caches cluster-wide. Payload is an account id (or "*" for everything).
+const ShardRouteChannel = "jgoweb_shard_route"
+
+var shardRouteCache *ShardRouteCache
+
+var (
+	shardRouteCacheCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "shard_route_cache_requests_total",
+		Help: "Shard route cache lookups. Labels: result (hit, miss).",
+	},
+		[]string{"result"},
+	)
+)
+
+// Cached routing info for an account
+type ShardRoute struct {
+	AccountId string
+	Shard     Shard
+}
+
+// Shard row + owning account from system.shard_map
+type shardRouteRow struct {
+	Shard
+	RouteAccountId sql.NullString `db:"route_account_id"`
+}
+
+//
+type shardRouteEntry struct {
+	key       string
+	route     ShardRoute
+	expiresAt time.Time
+}
+
+// Cache stats
+type ShardRouteCacheStats struct {
+	Size      int    `json:"size"`
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+}
+
+// In-process LRU cache for account => shard and email/domain => account routing
+type ShardRouteCache struct {
+	Ttl      time.Duration
+	MaxSize  int
+	entries  map[string]*list.Element
+	lru      *list.List
+	stats    ShardRouteCacheStats
+	listener *pq.Listener
+	mu       sync.Mutex
+}
+
+//
+func NewShardRouteCache(ttl time.Duration, maxSize int) *ShardRouteCache {
+	src := &ShardRouteCache{Ttl: ttl, MaxSize: maxSize}
+	src.entries = make(map[string]*list.Element)
+	src.lru = list.New()
+
+	return src
+}
+
+// Init the routing cache (if enabled in the config)
+func InitShardRouteCache() {
+	InitConfig()
+
+	options := appConfig.ShardRouteCache
+
+	if !options.Enabled || shardRouteCache != nil {
+		return
+	}
+
+	cache := NewShardRouteCache(time.Duration(options.Ttl)*time.Second, options.MaxSize)
+
+	err := cache.Listen(GetDbCollection())
+
+	if err != nil {
+		log.Printf("ERROR: %s %s", util.WhereAmI(), err)
+		return
+	}
+
+	SetShardRouteCache(cache)
+}
+
+// nil disables caching
+func SetShardRouteCache(cache *ShardRouteCache) {
+	shardRouteCache = cache
+}
+
+//
+func GetShardRouteCache() *ShardRouteCache {
+	return shardRouteCache
+}
+
+//
+func (src *ShardRouteCache) Get(key string) (*ShardRoute, bool) {
+	src.mu.Lock()
+	defer src.mu.Unlock()
+
+	elem, ok := src.entries[key]
+
+	if ok && time.Now().After(elem.Value.(*shardRouteEntry).expiresAt) {
+		src.removeElement(elem)
+		ok = false
+	}
+
+	if !ok {
+		src.stats.Misses++
+		shardRouteCacheCounter.WithLabelValues("miss").Inc()
+
+		return nil, false
+	}
+
+	src.lru.MoveToFront(elem)
+	src.stats.Hits++
+	shardRouteCacheCounter.WithLabelValues("hit").Inc()
+
+	route := elem.Value.(*shardRouteEntry).route
+
+	return &route, true
+}
+
+//
+func (src *ShardRouteCache) Set(key string, accountId string, shard *Shard) {
+	src.mu.Lock()
+	defer src.mu.Unlock()
+
+	route := ShardRoute{AccountId: accountId, Shard: *shard}
+	route.Shard.Ctx = nil
+
+	entry := &shardRouteEntry{key: key, route: route, expiresAt: time.Now().Add(src.Ttl)}
+
+	if elem, ok := src.entries[key]; ok {
+		elem.Value = entry
+		src.lru.MoveToFront(elem)
+
+		return
+	}
+
+	src.entries[key] = src.lru.PushFront(entry)
+
+	for src.MaxSize > 0 && src.lru.Len() > src.MaxSize {
+		src.removeElement(src.lru.Back())
+		src.stats.Evictions++
+	}
+}
+
+// Remove every route for an account
+func (src *ShardRouteCache) InvalidateAccount(accountId string) {
+	src.mu.Lock()
+	defer src.mu.Unlock()
+
+	for _, elem := range src.entries {
+		if elem.Value.(*shardRouteEntry).route.AccountId == accountId {
+			src.removeElement(elem)
+		}
+	}
+}
+
+//
+func (src *ShardRouteCache) Purge() {
+	src.mu.Lock()
+	defer src.mu.Unlock()
+
+	src.entries = make(map[string]*list.Element)
+	src.lru.Init()
+}
+
+//
+func (src *ShardRouteCache) Stats() ShardRouteCacheStats {
+	src.mu.Lock()
+	defer src.mu.Unlock()
+
+	stats := src.stats
+	stats.Size = src.lru.Len()
+
+	return stats
+}
+
+//
+func (src *ShardRouteCache) removeElement(elem *list.Element) {
+	delete(src.entries, elem.Value.(*shardRouteEntry).key)
+	src.lru.Remove(elem)
+}
+
+// Listen for cluster-wide invalidations on the channel DB (see GetShardRouteChannelShard)
+func (src *ShardRouteCache) Listen(db *jgoWebDb.Collection) error {
+	shardName, err := GetShardRouteChannelShard()
+
+	if err != nil {
+		return err
+	}
+
+	dsn, err := db.GetDsnByName(shardName)
+
+	if err != nil {
+		return err
+	}
+
+	listener := pq.NewListener(dsn, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("ERROR: %s %s", util.WhereAmI(), err)
+		}
+	})
+
+	err = listener.Listen(ShardRouteChannel)
+
+	if err != nil {
+		listener.Close()
+		return err
+	}
+
+	src.listener = listener
+
+	go func() {
+		for notification := range listener.Notify {
+			// nil = reconnected. Notifications may have been missed.
+			if notification == nil || notification.Extra == "*" {
+				src.Purge()
+				continue
+			}
+
+			src.InvalidateAccount(notification.Extra)
+		}
+	}()
+
+	return nil
+}
+
+//
+func (src *ShardRouteCache) Close() error {
+
+	if src.listener == nil {
+		return nil
+	}
+
+	return src.listener.Close()
+}
+
+// ******
+
+// DB that route invalidations are sent and received on (config shardRouteCache.shardName). Nodes only
+// listen on this DB, so a NOTIFY sent anywhere else is missed.
+func GetShardRouteChannelShard() (string, error) {
+	InitConfig()
+
+	if appConfig.ShardRouteCache.ShardName == "" {
+		return "", errors.New("Empty DB Config")
+	}
+
+	return appConfig.ShardRouteCache.ShardName, nil
+}
+
+// Invalidate cached routes for an account on every node. Sends on the channel DB, outside of any transaction,
+// so call it after the change is committed. accountId = "*" invalidates everything.
+func PublishShardRouteChange(db *jgoWebDb.Collection, accountId string) error {
+	shardName, err := GetShardRouteChannelShard()
+
+	if err != nil {
+		return err
+	}
+
+	dbSess, err := db.GetSessionByName(shardName)
+
+	if err != nil {
+		return err
+	}
+
+	ctx := NewContext(db)
+	ctx.SetDbSession(dbSess)
+
+	return NotifyShardRouteChange(ctx, accountId)
+}
+
+// Invalidate the local cache and NOTIFY on ctx's DB (sent on commit if ctx is in a transaction).
+// Other nodes only see it if ctx is on the channel DB, so prefer PublishShardRouteChange.
+// Postgres can't prepare a transaction that has sent a NOTIFY: use ClusterTransaction.NotifyShardRouteChange.
+func NotifyShardRouteChange(ctx ContextInterface, accountId string) error {
+	var result []string
+
+	if shardRouteCache != nil {
+		if accountId == "*" {
+			shardRouteCache.Purge()
+		} else {
+			shardRouteCache.InvalidateAccount(accountId)
+		}
+	}
+
+	_, err := ctx.SelectBySql("SELECT pg_notify(?, ?)", ShardRouteChannel, accountId).Load(&result)
+
+	return err
+}
+
+// Publish once the cluster transaction has committed on every DB
+func (ct *ClusterTransaction) NotifyShardRouteChange(accountId string) {
+
+	ct.OnCommit(func() error {
+		return PublishShardRouteChange(ct.Db, accountId)
+	})
+}
+
+//
+func getCachedShardRoute(key string) *ShardRoute {
+
+	if shardRouteCache == nil {
+		return nil
+	}
+
+	route, ok := shardRouteCache.Get(key)
+
+	if !ok {
+		return nil
+	}
+
+	return route
+}
+
+//
+func setCachedShardRoute(key string, accountId string, shard *Shard) {
+
+	if shardRouteCache == nil || shard == nil {
+		return
+	}
+
+	shardRouteCache.Set(key, accountId, shard)
+}
+
+//
+func shardRouteKey(kind string, val string) string {
+	return fmt.Sprintf("%s:%s", kind, val)
+}
@@ -0,0 +1,106 @@
+// +build integration
+
+package jgoweb
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// NOTIFY can't run in a prepared transaction, so it's sent after the cluster transaction commits
+func TestClusterDeleteShardNotifiesRouteCache(t *testing.T) {
+	InitMockCtx()
+
+	shardName := fmt.Sprintf("route_cache_test_%d", time.Now().UnixNano())
+
+	cache := NewShardRouteCache(time.Minute, 100)
+	err := cache.Listen(MockCtx.GetDb())
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+		return
+	}
+
+	defer cache.Close()
+
+	SetShardRouteCache(cache)
+	defer SetShardRouteCache(nil)
+
+	// not the global cache, so it's only purged by the notification
+	remote := NewShardRouteCache(time.Minute, 100)
+	err = remote.Listen(MockCtx.GetDb())
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+		return
+	}
+
+	defer remote.Close()
+
+	defer NewClusterTransaction(MockCtx.GetDb()).Run(func(dbName string, ctx ContextInterface) error {
+		_, err := ctx.DeleteFrom("system.shards").Where("name = ?", shardName).Exec()
+
+		return err
+	})
+
+	err = ClusterAddShard(MockCtx, shardName)
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+		return
+	}
+
+	shard := &Shard{}
+	shard.SetName(shardName)
+	remote.Set("account:test", "test", shard)
+
+	err = ClusterDeleteShard(MockCtx, shardName)
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+		return
+	}
+
+	for start := time.Now(); remote.Stats().Size > 0 && time.Since(start) < 2*time.Second; {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if remote.Stats().Size != 0 {
+		t.Errorf("\nERROR: Route cache wasn't invalidated after the cluster transaction committed.\n")
+	}
+}
+
+// Admin form edits publish on the channel DB, whichever DB the request's session is on
+func TestPublishShardRouteChange(t *testing.T) {
+	InitMockCtx()
+
+	remote := NewShardRouteCache(time.Minute, 100)
+	err := remote.Listen(MockCtx.GetDb())
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+		return
+	}
+
+	defer remote.Close()
+
+	shard := &Shard{}
+	shard.SetName("test")
+	remote.Set("account:test", "test", shard)
+
+	err = PublishShardRouteChange(MockCtx.GetDb(), "test")
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+		return
+	}
+
+	for start := time.Now(); remote.Stats().Size > 0 && time.Since(start) < 2*time.Second; {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if remote.Stats().Size != 0 {
+		t.Errorf("\nERROR: Route cache wasn't invalidated by the published change.\n")
+	}
+}
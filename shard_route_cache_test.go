@@ -0,0 +1,112 @@
+// +build unit
+
+package jgoweb
+
+import (
+	"testing"
+	"time"
+)
+
+//
+func getTestShardRouteShard(name string) *Shard {
+	shard := &Shard{}
+	shard.SetId("1")
+	shard.SetName(name)
+
+	return shard
+}
+
+//
+func TestShardRouteCacheGetSet(t *testing.T) {
+	cache := NewShardRouteCache(time.Minute, 10)
+
+	_, ok := cache.Get(shardRouteKey("account", "1"))
+
+	if ok {
+		t.Errorf("\nERROR: Empty cache should miss.\n")
+	}
+
+	cache.Set(shardRouteKey("account", "1"), "1", getTestShardRouteShard("shard_1"))
+
+	route, ok := cache.Get(shardRouteKey("account", "1"))
+
+	if !ok {
+		t.Errorf("\nERROR: Cache should hit.\n")
+		return
+	}
+
+	if route.AccountId != "1" || route.Shard.GetName() != "shard_1" {
+		t.Errorf("\nERROR: Route mismatch. Got: %v\n", route)
+	}
+
+	stats := cache.Stats()
+
+	if stats.Hits != 1 || stats.Misses != 1 || stats.Size != 1 {
+		t.Errorf("\nERROR: Unexpected stats: %+v\n", stats)
+	}
+}
+
+//
+func TestShardRouteCacheTtl(t *testing.T) {
+	cache := NewShardRouteCache(time.Millisecond, 10)
+	cache.Set(shardRouteKey("account", "1"), "1", getTestShardRouteShard("shard_1"))
+
+	time.Sleep(5 * time.Millisecond)
+
+	_, ok := cache.Get(shardRouteKey("account", "1"))
+
+	if ok {
+		t.Errorf("\nERROR: Expired route should miss.\n")
+	}
+
+	if cache.Stats().Size != 0 {
+		t.Errorf("\nERROR: Expired route should be removed.\n")
+	}
+}
+
+//
+func TestShardRouteCacheEviction(t *testing.T) {
+	cache := NewShardRouteCache(time.Minute, 2)
+	cache.Set(shardRouteKey("account", "1"), "1", getTestShardRouteShard("shard_1"))
+	cache.Set(shardRouteKey("account", "2"), "2", getTestShardRouteShard("shard_1"))
+
+	// touch 1 so 2 is least recently used
+	cache.Get(shardRouteKey("account", "1"))
+	cache.Set(shardRouteKey("account", "3"), "3", getTestShardRouteShard("shard_1"))
+
+	if _, ok := cache.Get(shardRouteKey("account", "2")); ok {
+		t.Errorf("\nERROR: Least recently used route should have been evicted.\n")
+	}
+
+	if _, ok := cache.Get(shardRouteKey("account", "1")); !ok {
+		t.Errorf("\nERROR: Recently used route should not have been evicted.\n")
+	}
+
+	if cache.Stats().Evictions != 1 {
+		t.Errorf("\nERROR: Expected 1 eviction. Got: %v\n", cache.Stats().Evictions)
+	}
+}
+
+//
+func TestShardRouteCacheInvalidate(t *testing.T) {
+	cache := NewShardRouteCache(time.Minute, 10)
+	cache.Set(shardRouteKey("account", "1"), "1", getTestShardRouteShard("shard_1"))
+	cache.Set(shardRouteKey("domain", "example.com"), "1", getTestShardRouteShard("shard_1"))
+	cache.Set(shardRouteKey("account", "2"), "2", getTestShardRouteShard("shard_2"))
+
+	cache.InvalidateAccount("1")
+
+	if cache.Stats().Size != 1 {
+		t.Errorf("\nERROR: Expected only account 2 to remain. Size: %v\n", cache.Stats().Size)
+	}
+
+	if _, ok := cache.Get(shardRouteKey("domain", "example.com")); ok {
+		t.Errorf("\nERROR: Domain route for account 1 should be invalidated.\n")
+	}
+
+	cache.Purge()
+
+	if cache.Stats().Size != 0 {
+		t.Errorf("\nERROR: Purge should empty the cache.\n")
+	}
+}
@@ -133,6 +133,122 @@ func TestShardAccountCount(t *testing.T) {
 	MockShard.SetAccountCount(origVal)
 }
 
+//
+func TestShardWeight(t *testing.T) {
+	InitMockShard()
+	origVal := MockShard.GetWeight()
+	testVal := "2"
+
+	MockShard.SetWeight("")
+
+	if MockShard.Weight.Valid {
+		t.Errorf("ERROR: Weight should be invalid.\n")
+	}
+
+	if MockShard.GetWeight() != "" {
+		t.Errorf("ERROR: Set Weight failed. Should have a blank value. Got: %s", MockShard.GetWeight())
+	}
+
+	MockShard.SetWeight(testVal)
+
+	if !MockShard.Weight.Valid {
+		t.Errorf("ERROR: Weight should be valid.\n")
+	}
+
+	if MockShard.GetWeight() != testVal {
+		t.Errorf("ERROR: Set Weight failed. Expected: %s, Got: %s", testVal, MockShard.GetWeight())
+	}
+
+	MockShard.SetWeight(origVal)
+}
+
+//
+func TestShardCapacityLimit(t *testing.T) {
+	InitMockShard()
+	origVal := MockShard.GetCapacityLimit()
+	testVal := "100"
+
+	MockShard.SetCapacityLimit("")
+
+	if MockShard.CapacityLimit.Valid {
+		t.Errorf("ERROR: CapacityLimit should be invalid.\n")
+	}
+
+	if MockShard.GetCapacityLimit() != "" {
+		t.Errorf("ERROR: Set CapacityLimit failed. Should have a blank value. Got: %s", MockShard.GetCapacityLimit())
+	}
+
+	MockShard.SetCapacityLimit(testVal)
+
+	if !MockShard.CapacityLimit.Valid {
+		t.Errorf("ERROR: CapacityLimit should be valid.\n")
+	}
+
+	if MockShard.GetCapacityLimit() != testVal {
+		t.Errorf("ERROR: Set CapacityLimit failed. Expected: %s, Got: %s", testVal, MockShard.GetCapacityLimit())
+	}
+
+	MockShard.SetCapacityLimit(origVal)
+}
+
+//
+func TestShardAcceptingAccounts(t *testing.T) {
+	InitMockShard()
+	origVal := MockShard.GetAcceptingAccounts()
+	testVal := "false"
+
+	MockShard.SetAcceptingAccounts("")
+
+	if MockShard.AcceptingAccounts.Valid {
+		t.Errorf("ERROR: AcceptingAccounts should be invalid.\n")
+	}
+
+	if MockShard.GetAcceptingAccounts() != "" {
+		t.Errorf("ERROR: Set AcceptingAccounts failed. Should have a blank value. Got: %s", MockShard.GetAcceptingAccounts())
+	}
+
+	MockShard.SetAcceptingAccounts(testVal)
+
+	if !MockShard.AcceptingAccounts.Valid {
+		t.Errorf("ERROR: AcceptingAccounts should be valid.\n")
+	}
+
+	if MockShard.GetAcceptingAccounts() != testVal {
+		t.Errorf("ERROR: Set AcceptingAccounts failed. Expected: %s, Got: %s", testVal, MockShard.GetAcceptingAccounts())
+	}
+
+	MockShard.SetAcceptingAccounts(origVal)
+}
+
+//
+func TestShardDsn(t *testing.T) {
+	InitMockShard()
+	origVal := MockShard.GetDsn()
+	testVal := "env:TEST_SHARD_DSN"
+
+	MockShard.SetDsn("")
+
+	if MockShard.Dsn.Valid {
+		t.Errorf("ERROR: Dsn should be invalid.\n")
+	}
+
+	if MockShard.GetDsn() != "" {
+		t.Errorf("ERROR: Set Dsn failed. Should have a blank value. Got: %s", MockShard.GetDsn())
+	}
+
+	MockShard.SetDsn(testVal)
+
+	if !MockShard.Dsn.Valid {
+		t.Errorf("ERROR: Dsn should be valid.\n")
+	}
+
+	if MockShard.GetDsn() != testVal {
+		t.Errorf("ERROR: Set Dsn failed. Expected: %s, Got: %s", testVal, MockShard.GetDsn())
+	}
+
+	MockShard.SetDsn(origVal)
+}
+
 //
 func TestShardCreatedAt(t *testing.T) {
 	InitMockShard()
@@ -484,3 +600,58 @@ func TestClusterGetShards(t *testing.T) {
 		t.Errorf("\nERROR: %v\n", err)
 	}
 }
+
+//
+func TestFetchBestShard(t *testing.T) {
+	InitMockCtx()
+
+	_, err := FetchBestShard(MockCtx)
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+	}
+}
+
+//
+func TestCheckShardColumns(t *testing.T) {
+	InitMockCtx()
+
+	sdu := NewSystemDbUpdater(MockCtx.Db, GetJgowebDbUpdates(), false)
+	err := sdu.RunByDbSession(MockCtx.DbSess, appConfig.Integration.ShardName)
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+		return
+	}
+
+	err = CheckShardColumns(MockCtx)
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+	}
+
+	ctx := NewContext(MockCtx.Db)
+	ctx.SetDbSession(MockCtx.DbSess)
+
+	_, err = ctx.Begin()
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+		return
+	}
+
+	defer ctx.Rollback()
+
+	_, err = ctx.UpdateBySql("ALTER TABLE system.shards DROP COLUMN dsn").Exec()
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+		return
+	}
+
+	err = CheckShardColumns(ctx)
+
+	if err == nil || !strings.Contains(err.Error(), "dsn") {
+		t.Errorf("\nERROR: Expected missing dsn column error. Got: %v\n", err)
+	}
+}
@@ -0,0 +1,299 @@
+package jgoweb
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// i.e., "0042_add_jobs_index.up.sql"
+var sqlDbUpdateFileRegexp = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+var dbUpdateVersionRegexp = regexp.MustCompile(`^(\d+)_`)
+
+// i.e., "-- jgoweb:tx none" in the file's header comments (see DbUpdateTxNone)
+var sqlDbUpdateTxModeRegexp = regexp.MustCompile(`^--\s*jgoweb:tx\s+(\S+)\s*$`)
+
+// Leading version of an update name (i.e., "0042_add_jobs_index" = 42). 0 if the name isn't versioned.
+func GetSystemDbUpdateVersion(updateName string) int64 {
+	digits := dbUpdateVersionRegexp.FindStringSubmatch(updateName)
+
+	if digits == nil {
+		return 0
+	}
+
+	version, err := strconv.ParseInt(digits[1], 10, 64)
+
+	if err != nil {
+		return 0
+	}
+
+	return version
+}
+
+// Update loaded from a .sql file. The update name is the file name without ".up.sql".
+// DownSql is loaded from the matching ".down.sql" file (if any).
+type SqlSystemDbUpdate struct {
+	*SystemDbUpdate
+	Path    string
+	Sql     string
+	DownSql string
+}
+
+//
+func NewSqlSystemDbUpdate(updateName string, desc string, filePath string, query string) *SqlSystemDbUpdate {
+	ssdu := &SqlSystemDbUpdate{SystemDbUpdate: CreateSystemDbUpdateNoContext(updateName, desc), Path: filePath, Sql: query}
+	ssdu.SetChecksum(ComputeDbUpdateChecksum(query))
+
+	return ssdu
+}
+
+// Non-transactional files run one statement at a time (a multi-statement query is an implicit transaction)
+// and resume after the last completed statement.
+func (ssdu *SqlSystemDbUpdate) Run() error {
+
+	if ssdu.GetTxMode() != DbUpdateTxNone {
+		return ssdu.execSql(ssdu.Sql)
+	}
+
+	statements := SplitSqlStatements(ssdu.Sql)
+	done, _ := strconv.Atoi(ssdu.GetProgress())
+
+	for i := done; i < len(statements); i++ {
+		err := ssdu.execSql(statements[i])
+
+		if err != nil {
+			return err
+		}
+
+		if ssdu.Id.Valid {
+			err = ssdu.SaveProgress(strconv.Itoa(i + 1))
+
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+//
+func (ssdu *SqlSystemDbUpdate) IsReversible() bool {
+	return ssdu.DownSql != ""
+}
+
+//
+func (ssdu *SqlSystemDbUpdate) Rollback() error {
+
+	if ssdu.DownSql == "" {
+		return errors.New(fmt.Sprintf("DB update %s is irreversible (no .down.sql file)", ssdu.GetUpdateName()))
+	}
+
+	return ssdu.execSql(ssdu.DownSql)
+}
+
+// The file may have several statements. ? isn't a placeholder (i.e., jsonb operators).
+func (ssdu *SqlSystemDbUpdate) execSql(query string) error {
+	_, err := ssdu.Ctx.UpdateBySql(strings.Replace(query, "?", "??", -1)).Exec()
+
+	return err
+}
+
+//
+func (ssdu *SqlSystemDbUpdate) Clone() SystemDbUpdateInterface {
+	clone := *ssdu
+	clone.SystemDbUpdate = ssdu.SystemDbUpdate.Clone().(*SystemDbUpdate)
+
+	return &clone
+}
+
+// ******
+
+// Load "<version>_<name>.up.sql" files from dir, ordered by version. Other files are ignored.
+// Descriptions come from the file's leading "--" comments (or the name if there aren't any).
+// An optional "<version>_<name>.down.sql" file makes the update reversible.
+// A "-- jgoweb:tx own" or "-- jgoweb:tx none" header comment sets the transaction mode.
+func LoadSqlDbUpdates(fsys fs.FS, dir string) ([]SystemDbUpdateInterface, error) {
+	var sqlUpdates []*SqlSystemDbUpdate
+	versions := make(map[int64]string)
+	downSql := make(map[string]string)
+
+	entries, err := fs.ReadDir(fsys, dir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+
+		if entry.IsDir() || !sqlDbUpdateFileRegexp.MatchString(entry.Name()) {
+			continue
+		}
+
+		filePath := path.Join(dir, entry.Name())
+		content, err := fs.ReadFile(fsys, filePath)
+
+		if err != nil {
+			return nil, err
+		}
+
+		query := strings.TrimSpace(string(content))
+
+		if query == "" {
+			return nil, errors.New(fmt.Sprintf("Empty DB update file: %s", filePath))
+		}
+
+		if strings.HasSuffix(entry.Name(), ".down.sql") {
+			downSql[strings.TrimSuffix(entry.Name(), ".down.sql")] = query
+			continue
+		}
+
+		updateName := strings.TrimSuffix(entry.Name(), ".up.sql")
+		version := GetSystemDbUpdateVersion(updateName)
+
+		if other, ok := versions[version]; ok {
+			return nil, errors.New(fmt.Sprintf("Duplicate DB update version %d: %s and %s", version, other, entry.Name()))
+		}
+
+		versions[version] = entry.Name()
+
+		update := NewSqlSystemDbUpdate(updateName, GetSqlDbUpdateDescription(updateName, query), filePath, query)
+		update.TxMode = GetSqlDbUpdateTxMode(query)
+
+		err = ValidateDbUpdateTxMode(update.TxMode)
+
+		if err != nil {
+			return nil, errors.New(filePath + ": " + err.Error())
+		}
+
+		sqlUpdates = append(sqlUpdates, update)
+	}
+
+	for _, update := range sqlUpdates {
+		update.DownSql = downSql[update.GetUpdateName()]
+		delete(downSql, update.GetUpdateName())
+	}
+
+	if len(downSql) > 0 {
+		var orphans []string
+
+		for updateName := range downSql {
+			orphans = append(orphans, updateName)
+		}
+
+		sort.Strings(orphans)
+
+		return nil, errors.New(fmt.Sprintf("DB update(s) with a .down.sql file but no .up.sql file: %s", strings.Join(orphans, ", ")))
+	}
+
+	sort.Slice(sqlUpdates, func(i, j int) bool {
+		return GetSystemDbUpdateVersion(sqlUpdates[i].GetUpdateName()) < GetSystemDbUpdateVersion(sqlUpdates[j].GetUpdateName())
+	})
+
+	updates := make([]SystemDbUpdateInterface, 0)
+
+	for _, update := range sqlUpdates {
+		updates = append(updates, update)
+	}
+
+	return updates, nil
+}
+
+//
+func LoadSqlDbUpdatesFromDir(dir string) ([]SystemDbUpdateInterface, error) {
+	return LoadSqlDbUpdates(os.DirFS(dir), ".")
+}
+
+// Leading "--" comment lines joined (max 255 chars). Falls back to the update name w/o its version.
+func GetSqlDbUpdateDescription(updateName string, query string) string {
+	var lines []string
+
+	for _, line := range strings.Split(query, "\n") {
+		line = strings.TrimSpace(line)
+
+		if line == "" {
+			continue
+		}
+
+		if !strings.HasPrefix(line, "--") {
+			break
+		}
+
+		if sqlDbUpdateTxModeRegexp.MatchString(line) {
+			continue
+		}
+
+		line = strings.TrimSpace(strings.TrimLeft(line, "-"))
+
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	desc := strings.Join(lines, " ")
+
+	if desc == "" {
+		desc = strings.Replace(dbUpdateVersionRegexp.ReplaceAllString(updateName, ""), "_", " ", -1)
+	}
+
+	if len(desc) > 255 {
+		desc = desc[:255]
+	}
+
+	return desc
+}
+
+// Transaction mode from the file's header comments ("" if it isn't set)
+func GetSqlDbUpdateTxMode(query string) string {
+
+	for _, line := range strings.Split(query, "\n") {
+		line = strings.TrimSpace(line)
+
+		if line == "" {
+			continue
+		}
+
+		if !strings.HasPrefix(line, "--") {
+			break
+		}
+
+		matches := sqlDbUpdateTxModeRegexp.FindStringSubmatch(line)
+
+		if matches != nil {
+			return matches[1]
+		}
+	}
+
+	return ""
+}
+
+// Combine Go and SQL updates into one ordered set. Sorted by version (stable, so unversioned updates
+// like "jgoweb_0001_shard_placement" keep their order and run first). Update names must be unique.
+func MergeSystemDbUpdates(sets ...[]SystemDbUpdateInterface) ([]SystemDbUpdateInterface, error) {
+	updates := make([]SystemDbUpdateInterface, 0)
+	names := make(map[string]bool)
+
+	for _, set := range sets {
+		for _, update := range set {
+
+			if names[update.GetUpdateName()] {
+				return nil, errors.New(fmt.Sprintf("Duplicate DB update: %s", update.GetUpdateName()))
+			}
+
+			names[update.GetUpdateName()] = true
+			updates = append(updates, update)
+		}
+	}
+
+	sort.SliceStable(updates, func(i, j int) bool {
+		return GetSystemDbUpdateVersion(updates[i].GetUpdateName()) < GetSystemDbUpdateVersion(updates[j].GetUpdateName())
+	})
+
+	return updates, nil
+}
@@ -0,0 +1,146 @@
+// +build unit
+
+package jgoweb
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+//
+func TestGetSystemDbUpdateVersion(t *testing.T) {
+	tests := map[string]int64{
+		"0042_add_jobs_index":         42,
+		"7_seed":                      7,
+		"jgoweb_0001_shard_placement": 0,
+		"Test SystemDbUpdate":         0,
+	}
+
+	for name, expected := range tests {
+		result := GetSystemDbUpdateVersion(name)
+
+		if result != expected {
+			t.Errorf("\nERROR: GetSystemDbUpdateVersion(%s)\nExpected: %d\nResult: %d\n", name, expected, result)
+		}
+	}
+}
+
+//
+func TestGetSqlDbUpdateDescription(t *testing.T) {
+	desc := GetSqlDbUpdateDescription("0042_add_jobs_index", "-- Index jobs\n-- by status\n\nCREATE INDEX ...;\n-- not part of it")
+
+	if desc != "Index jobs by status" {
+		t.Errorf("\nERROR: Unexpected description: %s\n", desc)
+	}
+
+	desc = GetSqlDbUpdateDescription("0042_add_jobs_index", "CREATE INDEX ...;")
+
+	if desc != "add jobs index" {
+		t.Errorf("\nERROR: Unexpected description: %s\n", desc)
+	}
+
+	desc = GetSqlDbUpdateDescription("0042_x", "-- "+strings.Repeat("a", 300)+"\nSELECT 1;")
+
+	if len(desc) != 255 {
+		t.Errorf("\nERROR: Description should be truncated to 255 chars. Got: %d\n", len(desc))
+	}
+}
+
+//
+func TestLoadSqlDbUpdates(t *testing.T) {
+	fsys := fstest.MapFS{
+		"updates/0010_second.up.sql":  {Data: []byte("-- Second update\nSELECT 2;")},
+		"updates/0002_first.up.sql":   {Data: []byte("SELECT 1;")},
+		"updates/0002_first.down.sql": {Data: []byte("SELECT -1;")},
+		"updates/README.md":           {Data: []byte("docs")},
+	}
+
+	updates, err := LoadSqlDbUpdates(fsys, "updates")
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+		return
+	}
+
+	if len(updates) != 2 {
+		t.Errorf("\nERROR: Expected 2 updates. Got: %d\n", len(updates))
+		return
+	}
+
+	if updates[0].GetUpdateName() != "0002_first" || updates[1].GetUpdateName() != "0010_second" {
+		t.Errorf("\nERROR: Unexpected order: %s, %s\n", updates[0].GetUpdateName(), updates[1].GetUpdateName())
+	}
+
+	if !updates[0].(*SqlSystemDbUpdate).IsReversible() || updates[1].(*SqlSystemDbUpdate).IsReversible() {
+		t.Errorf("\nERROR: Only the first update has a .down.sql file\n")
+	}
+
+	if updates[1].GetDescription() != "Second update" {
+		t.Errorf("\nERROR: Unexpected description: %s\n", updates[1].GetDescription())
+	}
+
+	clone := updates[1].Clone().(*SqlSystemDbUpdate)
+
+	if clone.Sql != "-- Second update\nSELECT 2;" || clone.SystemDbUpdate == updates[1].(*SqlSystemDbUpdate).SystemDbUpdate {
+		t.Errorf("\nERROR: Bad clone: %v\n", clone)
+	}
+
+	fsys["updates/0010_dupe.up.sql"] = &fstest.MapFile{Data: []byte("SELECT 3;")}
+
+	_, err = LoadSqlDbUpdates(fsys, "updates")
+
+	if err == nil {
+		t.Errorf("\nERROR: Expected duplicate version error\n")
+	}
+
+	fsys = fstest.MapFS{"0001_orphan.down.sql": {Data: []byte("SELECT 1;")}}
+
+	_, err = LoadSqlDbUpdates(fsys, ".")
+
+	if err == nil {
+		t.Errorf("\nERROR: Expected orphan .down.sql error\n")
+	}
+
+	fsys = fstest.MapFS{"0001_empty.up.sql": {Data: []byte(" \n")}}
+
+	_, err = LoadSqlDbUpdates(fsys, ".")
+
+	if err == nil {
+		t.Errorf("\nERROR: Expected empty file error\n")
+	}
+}
+
+//
+func TestMergeSystemDbUpdates(t *testing.T) {
+	goUpdates := []SystemDbUpdateInterface{
+		CreateSystemDbUpdateNoContext("jgoweb_0001_shard_placement", "framework"),
+		CreateSystemDbUpdateNoContext("0005_backfill", "go update"),
+	}
+
+	sqlUpdates := []SystemDbUpdateInterface{
+		NewSqlSystemDbUpdate("0003_table", "sql", "0003_table.up.sql", "SELECT 1;"),
+		NewSqlSystemDbUpdate("0007_index", "sql", "0007_index.up.sql", "SELECT 1;"),
+	}
+
+	updates, err := MergeSystemDbUpdates(goUpdates, sqlUpdates)
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+		return
+	}
+
+	expected := []string{"jgoweb_0001_shard_placement", "0003_table", "0005_backfill", "0007_index"}
+
+	for i, name := range expected {
+		if updates[i].GetUpdateName() != name {
+			t.Errorf("\nERROR: Position %d\nExpected: %s\nResult: %s\n", i, name, updates[i].GetUpdateName())
+		}
+	}
+
+	_, err = MergeSystemDbUpdates(goUpdates, goUpdates)
+
+	if err == nil {
+		t.Errorf("\nERROR: Expected duplicate update error\n")
+	}
+}
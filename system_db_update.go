@@ -3,6 +3,7 @@ package jgoweb
 import (
 	"database/sql"
 	"errors"
+	"fmt"
 	"github.com/gocraft/web"
 	"github.com/jschneider98/jgoweb/util"
 	"time"
@@ -19,14 +20,32 @@ type SystemDbUpdateInterface interface {
 	Clone() SystemDbUpdateInterface
 }
 
-// SystemDbUpdate
+// Optional. Updates that can be rolled back (see SystemDbUpdater.RollbackTo).
+type SystemDbUpdateRollbackInterface interface {
+	SystemDbUpdateInterface
+	IsReversible() bool
+	Rollback() error
+	SetIncomplete() error
+}
+
+// SystemDbUpdate. Non-transactional updates can use ApplyWithProgress (instead of ApplyUpdate)
+// to checkpoint with SaveProgress and resume from GetProgress.
 type SystemDbUpdate struct {
-	ApplyUpdate func(ctx ContextInterface) error `json:"-" validate:"-"`
-	Id          sql.NullString                   `json:"Id" validate:"omitempty,int"`
-	UpdateName  sql.NullString                   `json:"UpdateName" validate:"required,min=1,max=255"`
-	Description sql.NullString                   `json:"Description" validate:"required,min=1,max=255"`
-	CreatedAt   sql.NullString                   `json:"CreatedAt" validate:"omitempty,rfc3339"`
-	Ctx         ContextInterface                 `json:"-" validate:"-"`
+	ApplyUpdate       func(ctx ContextInterface) error                      `json:"-" validate:"-"`
+	ApplyWithProgress func(ctx ContextInterface, sdu *SystemDbUpdate) error `json:"-" validate:"-"`
+	RevertUpdate      func(ctx ContextInterface) error                      `json:"-" validate:"-"`
+	TxMode            string                                                `json:"-" validate:"-"`
+	Id                sql.NullString                                        `json:"Id" validate:"omitempty,int"`
+	UpdateName        sql.NullString                                        `json:"UpdateName" validate:"required,min=1,max=255"`
+	Description       sql.NullString                                        `json:"Description" validate:"required,min=1,max=255"`
+	CreatedAt         sql.NullString                                        `json:"CreatedAt" validate:"omitempty,rfc3339"`
+	Checksum          sql.NullString                                        `json:"Checksum" validate:"omitempty,max=64"`
+	AppliedBy         sql.NullString                                        `json:"AppliedBy" validate:"omitempty,max=255"`
+	DurationMs        sql.NullString                                        `json:"DurationMs" validate:"omitempty,int"`
+	Version           sql.NullString                                        `json:"Version" validate:"omitempty,max=255"`
+	Status            sql.NullString                                        `json:"Status" validate:"omitempty,oneof=started applied"`
+	Progress          sql.NullString                                        `json:"Progress" validate:"omitempty,max=255"`
+	Ctx               ContextInterface                                      `json:"-" validate:"-"`
 }
 
 // Empty new model
@@ -93,7 +112,7 @@ func FetchSystemDbUpdateByUpdateName(ctx ContextInterface, updateName string) (*
 		Where("update_name = ?", updateName).
 		Limit(1)
 
-	_, err := stmt.Load(&sdu)
+	_, err := stmt.LoadContext(ctx.GetGoContext(), &sdu)
 
 	if err != nil {
 		return nil, err
@@ -166,6 +185,12 @@ func (sdu *SystemDbUpdate) Hydrate(req *web.Request) error {
 	sdu.SetUpdateName(req.PostFormValue("UpdateName"))
 	sdu.SetDescription(req.PostFormValue("Description"))
 	sdu.SetCreatedAt(req.PostFormValue("CreatedAt"))
+	sdu.SetChecksum(req.PostFormValue("Checksum"))
+	sdu.SetAppliedBy(req.PostFormValue("AppliedBy"))
+	sdu.SetDurationMs(req.PostFormValue("DurationMs"))
+	sdu.SetVersion(req.PostFormValue("Version"))
+	sdu.SetStatus(req.PostFormValue("Status"))
+	sdu.SetProgress(req.PostFormValue("Progress"))
 
 	return nil
 }
@@ -183,11 +208,7 @@ func (sdu *SystemDbUpdate) Save() error {
 		return err
 	}
 
-	if !sdu.Id.Valid {
-		return sdu.Insert()
-	} else {
-		return sdu.Update()
-	}
+	return SaveInTransaction(sdu.Ctx, &sdu.Id.Valid, sdu.Insert, sdu.Update)
 }
 
 // Insert a new record
@@ -196,8 +217,14 @@ func (sdu *SystemDbUpdate) Insert() error {
 	query := `
 INSERT INTO
 system.db_updates (update_name,
-	description)
-VALUES ($1,$2)
+	description,
+	checksum,
+	applied_by,
+	duration_ms,
+	version,
+	status,
+	progress)
+VALUES ($1,$2,$3,$4,$5,$6,$7,$8)
 RETURNING id
 `
 
@@ -210,7 +237,13 @@ RETURNING id
 	defer stmt.Close()
 
 	err = stmt.QueryRow(sdu.UpdateName,
-		sdu.Description).Scan(&sdu.Id)
+		sdu.Description,
+		sdu.Checksum,
+		sdu.AppliedBy,
+		sdu.DurationMs,
+		sdu.Version,
+		sdu.Status,
+		sdu.Progress).Scan(&sdu.Id)
 
 	if err != nil {
 		return err
@@ -230,6 +263,12 @@ func (sdu *SystemDbUpdate) Update() error {
 		Set("id", sdu.Id).
 		Set("update_name", sdu.UpdateName).
 		Set("description", sdu.Description).
+		Set("checksum", sdu.Checksum).
+		Set("applied_by", sdu.AppliedBy).
+		Set("duration_ms", sdu.DurationMs).
+		Set("version", sdu.Version).
+		Set("status", sdu.Status).
+		Set("progress", sdu.Progress).
 		Where("id = ?", sdu.Id).
 		Exec()
 
@@ -354,6 +393,150 @@ func (sdu *SystemDbUpdate) SetCreatedAt(val string) {
 	sdu.CreatedAt.String = val
 }
 
+//
+func (sdu *SystemDbUpdate) GetChecksum() string {
+
+	if sdu.Checksum.Valid {
+		return sdu.Checksum.String
+	}
+
+	return ""
+}
+
+//
+func (sdu *SystemDbUpdate) SetChecksum(val string) {
+
+	if val == "" {
+		sdu.Checksum.Valid = false
+		sdu.Checksum.String = ""
+
+		return
+	}
+
+	sdu.Checksum.Valid = true
+	sdu.Checksum.String = val
+}
+
+//
+func (sdu *SystemDbUpdate) GetAppliedBy() string {
+
+	if sdu.AppliedBy.Valid {
+		return sdu.AppliedBy.String
+	}
+
+	return ""
+}
+
+//
+func (sdu *SystemDbUpdate) SetAppliedBy(val string) {
+
+	if val == "" {
+		sdu.AppliedBy.Valid = false
+		sdu.AppliedBy.String = ""
+
+		return
+	}
+
+	sdu.AppliedBy.Valid = true
+	sdu.AppliedBy.String = val
+}
+
+//
+func (sdu *SystemDbUpdate) GetDurationMs() string {
+
+	if sdu.DurationMs.Valid {
+		return sdu.DurationMs.String
+	}
+
+	return ""
+}
+
+//
+func (sdu *SystemDbUpdate) SetDurationMs(val string) {
+
+	if val == "" {
+		sdu.DurationMs.Valid = false
+		sdu.DurationMs.String = ""
+
+		return
+	}
+
+	sdu.DurationMs.Valid = true
+	sdu.DurationMs.String = val
+}
+
+//
+func (sdu *SystemDbUpdate) GetVersion() string {
+
+	if sdu.Version.Valid {
+		return sdu.Version.String
+	}
+
+	return ""
+}
+
+//
+func (sdu *SystemDbUpdate) SetVersion(val string) {
+
+	if val == "" {
+		sdu.Version.Valid = false
+		sdu.Version.String = ""
+
+		return
+	}
+
+	sdu.Version.Valid = true
+	sdu.Version.String = val
+}
+
+//
+func (sdu *SystemDbUpdate) GetStatus() string {
+
+	if sdu.Status.Valid {
+		return sdu.Status.String
+	}
+
+	return ""
+}
+
+//
+func (sdu *SystemDbUpdate) SetStatus(val string) {
+
+	if val == "" {
+		sdu.Status.Valid = false
+		sdu.Status.String = ""
+
+		return
+	}
+
+	sdu.Status.Valid = true
+	sdu.Status.String = val
+}
+
+//
+func (sdu *SystemDbUpdate) GetProgress() string {
+
+	if sdu.Progress.Valid {
+		return sdu.Progress.String
+	}
+
+	return ""
+}
+
+//
+func (sdu *SystemDbUpdate) SetProgress(val string) {
+
+	if val == "" {
+		sdu.Progress.Valid = false
+		sdu.Progress.String = ""
+
+		return
+	}
+
+	sdu.Progress.Valid = true
+	sdu.Progress.String = val
+}
+
 // ****** Interface Methods ******
 
 // Empty new update
@@ -371,37 +554,104 @@ func (sdu *SystemDbUpdate) SetContext(ctx ContextInterface) {
 	sdu.Ctx = ctx
 }
 
-//
+// Started (but not applied) updates need to run again. They keep their record and progress.
 func (sdu *SystemDbUpdate) NeedsToRun() (bool, error) {
-	var err error
-	updateName := sdu.UpdateName.String
-	description := sdu.Description
 
 	if sdu.Ctx == nil {
 		err := errors.New("Context not set in SystemDbUpdate.NeedsToRun()")
 		return false, err
 	}
 
-	sdu, err = CreateSystemDbUpdateByUpdateName(sdu.Ctx, updateName)
-	sdu.Description = description
+	applied, err := FetchSystemDbUpdateByUpdateName(sdu.Ctx, sdu.GetUpdateName())
 
 	if err != nil {
 		return false, err
 	}
 
-	return !sdu.Id.Valid, nil
+	if applied == nil {
+		return true, nil
+	}
+
+	if applied.GetStatus() == DbUpdateStatusStarted {
+		sdu.Id = applied.Id
+		sdu.Progress = applied.Progress
+
+		return true, nil
+	}
+
+	return false, nil
 }
 
 //
 func (sdu *SystemDbUpdate) Run() error {
+
+	if sdu.ApplyWithProgress != nil {
+		return sdu.ApplyWithProgress(sdu.Ctx, sdu)
+	}
+
 	return sdu.ApplyUpdate(sdu.Ctx)
 }
 
 //
 func (sdu *SystemDbUpdate) SetComplete() error {
+	sdu.SetStatus(DbUpdateStatusApplied)
+
+	return sdu.Save()
+}
+
+// Record a non-transactional update before it runs
+func (sdu *SystemDbUpdate) SetStarted() error {
+	sdu.SetStatus(DbUpdateStatusStarted)
+
+	return sdu.Save()
+}
+
+// Checkpoint for a started update (i.e., the last backfilled id). Read it with GetProgress to resume.
+func (sdu *SystemDbUpdate) SaveProgress(val string) error {
+	sdu.SetProgress(val)
+
 	return sdu.Save()
 }
 
+//
+func (sdu *SystemDbUpdate) GetTxMode() string {
+
+	if sdu.TxMode == "" {
+		return DbUpdateTxBatch
+	}
+
+	return sdu.TxMode
+}
+
+//
+func (sdu *SystemDbUpdate) IsReversible() bool {
+	return sdu.RevertUpdate != nil
+}
+
+//
+func (sdu *SystemDbUpdate) Rollback() error {
+
+	if sdu.RevertUpdate == nil {
+		return errors.New(fmt.Sprintf("DB update %s is irreversible", sdu.GetUpdateName()))
+	}
+
+	return sdu.RevertUpdate(sdu.Ctx)
+}
+
+// Remove the applied record
+func (sdu *SystemDbUpdate) SetIncomplete() error {
+
+	if sdu.Ctx == nil {
+		return errors.New("Context not set in SystemDbUpdate.SetIncomplete()")
+	}
+
+	_, err := sdu.Ctx.DeleteFrom("system.db_updates").
+		Where("update_name = ?", sdu.GetUpdateName()).
+		Exec()
+
+	return err
+}
+
 //
 func (sdu *SystemDbUpdate) Clone() SystemDbUpdateInterface {
 	clone := *sdu
@@ -0,0 +1,197 @@
+package jgoweb
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	DbUpdateModified = "modified"
+	DbUpdateMissing  = "missing"
+	DbUpdateUnknown  = "unknown"
+)
+
+// Optional. Updates that record who/when/how long when they're applied (see SystemDbUpdater.Run).
+type SystemDbUpdateAuditInterface interface {
+	GetChecksum() string
+	SetAppliedBy(val string)
+	SetDurationMs(val string)
+	SetVersion(val string)
+}
+
+// Applied update that doesn't match the update set
+type DbUpdateDrift struct {
+	ShardName  string `json:"shardName"`
+	UpdateName string `json:"updateName"`
+	Kind       string `json:"kind"`
+	Expected   string `json:"expected"`
+	Actual     string `json:"actual"`
+}
+
+// Checksum of an update's content (i.e., its SQL). Go updates can set one with SetChecksum.
+func ComputeDbUpdateChecksum(content string) string {
+	sum := sha256.Sum256([]byte(content))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// Older system.db_updates tables don't have the audit and status columns
+func EnsureSystemDbUpdateColumns(ctx ContextInterface) error {
+	_, err := ctx.UpdateBySql(`
+	ALTER TABLE system.db_updates
+		ADD COLUMN IF NOT EXISTS checksum text,
+		ADD COLUMN IF NOT EXISTS applied_by text,
+		ADD COLUMN IF NOT EXISTS duration_ms integer,
+		ADD COLUMN IF NOT EXISTS version text,
+		ADD COLUMN IF NOT EXISTS status text,
+		ADD COLUMN IF NOT EXISTS progress text`).Exec()
+
+	return err
+}
+
+// Every applied update on one DB
+func FetchSystemDbUpdates(ctx ContextInterface) ([]SystemDbUpdate, error) {
+	var sdu []SystemDbUpdate
+
+	_, err := ctx.Select("*").
+		From("system.db_updates").
+		OrderBy("id").
+		LoadContext(ctx.GetGoContext(), &sdu)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range sdu {
+		sdu[i].Ctx = ctx
+	}
+
+	return sdu, nil
+}
+
+// Applied updates by shard and update name
+func (sdu *SystemDbUpdater) FetchApplied() (map[string]map[string]SystemDbUpdate, error) {
+	applied := make(map[string]map[string]SystemDbUpdate)
+
+	results := NewClusterExecutor(sdu.Db).Run(context.Background(), func(goCtx context.Context, ctx ContextInterface) (interface{}, error) {
+		return FetchSystemDbUpdates(ctx)
+	})
+
+	err := results.Err()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, result := range results {
+		applied[result.ShardName] = make(map[string]SystemDbUpdate)
+
+		for _, update := range result.Value.([]SystemDbUpdate) {
+			applied[result.ShardName][update.GetUpdateName()] = update
+		}
+	}
+
+	return applied, nil
+}
+
+// Report modified, missing and unknown updates on every shard
+func (sdu *SystemDbUpdater) Verify() ([]DbUpdateDrift, error) {
+	applied, err := sdu.FetchApplied()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return sdu.CompareApplied(applied), nil
+}
+
+// modified = checksum differs from the update set (untracked checksums are skipped)
+// missing = applied on another shard, but not this one
+// unknown = applied, but not in the update set
+func (sdu *SystemDbUpdater) CompareApplied(applied map[string]map[string]SystemDbUpdate) []DbUpdateDrift {
+	drift := make([]DbUpdateDrift, 0)
+	known := make(map[string]bool)
+
+	for _, update := range sdu.DbUpdates {
+		known[update.GetUpdateName()] = true
+	}
+
+	for shardName, shardApplied := range applied {
+		for _, update := range sdu.DbUpdates {
+			name := update.GetUpdateName()
+			row, ok := shardApplied[name]
+
+			if !ok {
+				if sdu.isAppliedElsewhere(applied, name) {
+					drift = append(drift, DbUpdateDrift{shardName, name, DbUpdateMissing, "applied", ""})
+				}
+
+				continue
+			}
+
+			audited, ok := update.(SystemDbUpdateAuditInterface)
+
+			if !ok || audited.GetChecksum() == "" || row.GetChecksum() == "" {
+				continue
+			}
+
+			if audited.GetChecksum() != row.GetChecksum() {
+				drift = append(drift, DbUpdateDrift{shardName, name, DbUpdateModified, audited.GetChecksum(), row.GetChecksum()})
+			}
+		}
+
+		for name := range shardApplied {
+			if !known[name] {
+				drift = append(drift, DbUpdateDrift{shardName, name, DbUpdateUnknown, "", "applied"})
+			}
+		}
+	}
+
+	sort.Slice(drift, func(i, j int) bool {
+		if drift[i].ShardName != drift[j].ShardName {
+			return drift[i].ShardName < drift[j].ShardName
+		}
+
+		return drift[i].UpdateName < drift[j].UpdateName
+	})
+
+	return drift
+}
+
+//
+func (sdu *SystemDbUpdater) isAppliedElsewhere(applied map[string]map[string]SystemDbUpdate, updateName string) bool {
+
+	for _, shardApplied := range applied {
+		if _, ok := shardApplied[updateName]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Human readable drift report
+func FormatDbUpdateDrift(drift []DbUpdateDrift) string {
+	var lines []string
+
+	if len(drift) == 0 {
+		return "DB updates are consistent.\n"
+	}
+
+	for _, row := range drift {
+		switch row.Kind {
+		case DbUpdateModified:
+			lines = append(lines, fmt.Sprintf("%s '%s': modified since applied\n\t- %s\n\t+ %s", row.ShardName, row.UpdateName, row.Actual, row.Expected))
+		case DbUpdateMissing:
+			lines = append(lines, fmt.Sprintf("%s '%s': missing (applied on other shards)", row.ShardName, row.UpdateName))
+		default:
+			lines = append(lines, fmt.Sprintf("%s '%s': unknown (not in the update set)", row.ShardName, row.UpdateName))
+		}
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
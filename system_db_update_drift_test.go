@@ -0,0 +1,83 @@
+// +build unit
+
+package jgoweb
+
+import (
+	"strings"
+	"testing"
+)
+
+//
+func getTestAppliedUpdate(name string, checksum string) SystemDbUpdate {
+	update := CreateSystemDbUpdateNoContext(name, name)
+	update.SetChecksum(checksum)
+
+	return *update
+}
+
+//
+func TestComputeDbUpdateChecksum(t *testing.T) {
+	checksum := ComputeDbUpdateChecksum("SELECT 1;")
+
+	if len(checksum) != 64 || checksum != ComputeDbUpdateChecksum("SELECT 1;") {
+		t.Errorf("\nERROR: Bad checksum: %s\n", checksum)
+	}
+
+	if checksum == ComputeDbUpdateChecksum("SELECT 2;") {
+		t.Errorf("\nERROR: Checksums should differ by content\n")
+	}
+
+	update := NewSqlSystemDbUpdate("0001_test", "test", "0001_test.up.sql", "SELECT 1;")
+
+	if update.GetChecksum() != checksum {
+		t.Errorf("\nERROR: SQL update checksum not set\n")
+	}
+}
+
+//
+func TestCompareApplied(t *testing.T) {
+	goUpdate := CreateSystemDbUpdateNoContext("0001_go", "go")
+	sqlUpdate := NewSqlSystemDbUpdate("0002_sql", "sql", "0002_sql.up.sql", "SELECT 1;")
+
+	sdu := NewSystemDbUpdater(nil, []SystemDbUpdateInterface{goUpdate, sqlUpdate}, true)
+
+	applied := map[string]map[string]SystemDbUpdate{
+		"shard_1": {
+			"0001_go":  getTestAppliedUpdate("0001_go", ""),
+			"0002_sql": getTestAppliedUpdate("0002_sql", sqlUpdate.GetChecksum()),
+		},
+		"shard_2": {
+			"0002_sql":    getTestAppliedUpdate("0002_sql", "old"),
+			"0003_manual": getTestAppliedUpdate("0003_manual", ""),
+		},
+	}
+
+	drift := sdu.CompareApplied(applied)
+
+	expected := []DbUpdateDrift{
+		{"shard_2", "0001_go", DbUpdateMissing, "applied", ""},
+		{"shard_2", "0002_sql", DbUpdateModified, sqlUpdate.GetChecksum(), "old"},
+		{"shard_2", "0003_manual", DbUpdateUnknown, "", "applied"},
+	}
+
+	if len(drift) != len(expected) {
+		t.Errorf("\nERROR: Expected %d drift rows. Got: %v\n", len(expected), drift)
+		return
+	}
+
+	for i := range expected {
+		if drift[i] != expected[i] {
+			t.Errorf("\nERROR: Row %d\nExpected: %v\nResult: %v\n", i, expected[i], drift[i])
+		}
+	}
+
+	report := FormatDbUpdateDrift(drift)
+
+	if !strings.Contains(report, "shard_2 '0002_sql': modified") {
+		t.Errorf("\nERROR: Unexpected report: %s\n", report)
+	}
+
+	if FormatDbUpdateDrift(nil) != "DB updates are consistent.\n" {
+		t.Errorf("\nERROR: Unexpected empty report\n")
+	}
+}
@@ -0,0 +1,307 @@
+package jgoweb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/gocraft/web"
+	"html/template"
+	"net/http"
+	"sort"
+	"text/tabwriter"
+)
+
+// Report statuses (see DbUpdateStatusStarted/DbUpdateStatusApplied for the stored ones)
+const (
+	DbUpdateStatusPending = "pending"
+	DbUpdateStatusFailed  = "failed"
+	DbUpdateStatusDrifted = "drifted"
+)
+
+// One update on one shard.
+// failed = a non-transactional update that started but didn't finish (it resumes on the next run).
+// drifted = applied, but modified since, or not in the update set (see CompareApplied).
+type DbUpdateStatusRow struct {
+	ShardName  string `json:"shardName"`
+	UpdateName string `json:"updateName"`
+	Status     string `json:"status"`
+	AppliedAt  string `json:"appliedAt"`
+	Version    string `json:"version"`
+	AppliedBy  string `json:"appliedBy"`
+	Progress   string `json:"progress"`
+	Detail     string `json:"detail"`
+}
+
+// Sorted by shard, then update set order (unknown updates last)
+type DbUpdateStatusReport []DbUpdateStatusRow
+
+// An update RunAll would execute on a shard. Sql is set for SQL file updates.
+type DbUpdatePlanStep struct {
+	ShardName   string `json:"shardName"`
+	UpdateName  string `json:"updateName"`
+	Description string `json:"description"`
+	TxMode      string `json:"txMode"`
+	Resume      string `json:"resume"`
+	Sql         string `json:"sql"`
+}
+
+// In execution order per shard
+type DbUpdatePlan []DbUpdatePlanStep
+
+// Status of every update on the selected shards (see GetShardNames)
+func (sdu *SystemDbUpdater) Status() (DbUpdateStatusReport, error) {
+	applied, err := sdu.FetchApplied()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return sdu.BuildStatus(applied), nil
+}
+
+// What RunAll would execute on the selected shards. Read only (nothing is run or locked).
+func (sdu *SystemDbUpdater) Plan() (DbUpdatePlan, error) {
+	applied, err := sdu.FetchApplied()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return sdu.BuildPlan(applied), nil
+}
+
+//
+func (sdu *SystemDbUpdater) BuildStatus(applied map[string]map[string]SystemDbUpdate) DbUpdateStatusReport {
+	report := make(DbUpdateStatusReport, 0)
+	drift := make(map[string]DbUpdateDrift)
+	known := make(map[string]bool)
+
+	for _, row := range sdu.CompareApplied(applied) {
+		drift[row.ShardName+"\x00"+row.UpdateName] = row
+	}
+
+	for _, update := range sdu.DbUpdates {
+		known[update.GetUpdateName()] = true
+	}
+
+	for _, shardName := range sdu.selectedShards(applied) {
+		shardApplied := applied[shardName]
+
+		for _, update := range sdu.DbUpdates {
+			row := DbUpdateStatusRow{ShardName: shardName, UpdateName: update.GetUpdateName(), Status: DbUpdateStatusPending}
+
+			if stored, ok := shardApplied[row.UpdateName]; ok {
+				setDbUpdateStatusRow(&row, stored, drift[shardName+"\x00"+row.UpdateName])
+			}
+
+			report = append(report, row)
+		}
+
+		var unknown []string
+
+		for name := range shardApplied {
+			if !known[name] {
+				unknown = append(unknown, name)
+			}
+		}
+
+		sort.Strings(unknown)
+
+		for _, name := range unknown {
+			row := DbUpdateStatusRow{ShardName: shardName, UpdateName: name}
+			setDbUpdateStatusRow(&row, shardApplied[name], drift[shardName+"\x00"+name])
+
+			report = append(report, row)
+		}
+	}
+
+	return report
+}
+
+//
+func setDbUpdateStatusRow(row *DbUpdateStatusRow, stored SystemDbUpdate, drift DbUpdateDrift) {
+	row.Status = DbUpdateStatusApplied
+	row.AppliedAt = stored.GetCreatedAt()
+	row.Version = stored.GetVersion()
+	row.AppliedBy = stored.GetAppliedBy()
+	row.Progress = stored.GetProgress()
+
+	switch {
+	case stored.GetStatus() == DbUpdateStatusStarted:
+		row.Status = DbUpdateStatusFailed
+		row.Detail = "started but not finished"
+	case drift.Kind == DbUpdateModified:
+		row.Status = DbUpdateStatusDrifted
+		row.Detail = "modified since applied"
+	case drift.Kind == DbUpdateUnknown:
+		row.Status = DbUpdateStatusDrifted
+		row.Detail = "not in the update set"
+	}
+}
+
+// Same rules as NeedsToRun: not applied, or started but not finished (resumed from its progress)
+func (sdu *SystemDbUpdater) BuildPlan(applied map[string]map[string]SystemDbUpdate) DbUpdatePlan {
+	plan := make(DbUpdatePlan, 0)
+
+	for _, shardName := range sdu.selectedShards(applied) {
+		for _, update := range sdu.DbUpdates {
+			step := DbUpdatePlanStep{ShardName: shardName, UpdateName: update.GetUpdateName(), Description: update.GetDescription()}
+			step.TxMode = GetDbUpdateTxMode(update)
+
+			if stored, ok := applied[shardName][step.UpdateName]; ok {
+				if stored.GetStatus() != DbUpdateStatusStarted {
+					continue
+				}
+
+				step.Resume = stored.GetProgress()
+			}
+
+			if sqlUpdate, ok := update.(*SqlSystemDbUpdate); ok {
+				step.Sql = sqlUpdate.Sql
+			}
+
+			plan = append(plan, step)
+		}
+	}
+
+	return plan
+}
+
+// Shards in applied that RunAll would update
+func (sdu *SystemDbUpdater) selectedShards(applied map[string]map[string]SystemDbUpdate) []string {
+	shardNames := make([]string, 0)
+
+	for _, shardName := range sdu.GetShardNames() {
+		if _, ok := applied[shardName]; ok {
+			shardNames = append(shardNames, shardName)
+		}
+	}
+
+	return shardNames
+}
+
+// ******
+
+//
+func (report DbUpdateStatusReport) Json() (string, error) {
+	out, err := json.MarshalIndent(report, "", "\t")
+
+	return string(out), err
+}
+
+// Plain text table
+func (report DbUpdateStatusReport) Table() string {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(w, "SHARD\tUPDATE\tSTATUS\tAPPLIED AT\tVERSION\tDETAIL")
+
+	for _, row := range report {
+		detail := row.Detail
+
+		if row.Progress != "" {
+			detail += fmt.Sprintf(" (progress: %s)", row.Progress)
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", row.ShardName, row.UpdateName, row.Status, row.AppliedAt, row.Version, detail)
+	}
+
+	w.Flush()
+
+	return buf.String()
+}
+
+// Counts by status (i.e., for deploy checks)
+func (report DbUpdateStatusReport) Counts() map[string]int {
+	counts := make(map[string]int)
+
+	for _, row := range report {
+		counts[row.Status]++
+	}
+
+	return counts
+}
+
+//
+func (plan DbUpdatePlan) Json() (string, error) {
+	out, err := json.MarshalIndent(plan, "", "\t")
+
+	return string(out), err
+}
+
+// Steps by shard, with the SQL of SQL file updates
+func (plan DbUpdatePlan) Table() string {
+	var buf bytes.Buffer
+
+	if len(plan) == 0 {
+		return "Nothing to run.\n"
+	}
+
+	for _, step := range plan {
+		fmt.Fprintf(&buf, "%s: %s [%s]", step.ShardName, step.UpdateName, step.TxMode)
+
+		if step.Resume != "" {
+			fmt.Fprintf(&buf, " (resume from: %s)", step.Resume)
+		}
+
+		fmt.Fprintf(&buf, "\n\t%s\n", step.Description)
+
+		if step.Sql != "" {
+			fmt.Fprintf(&buf, "\n%s\n\n", step.Sql)
+		}
+	}
+
+	return buf.String()
+}
+
+// ******
+
+const dbUpdateStatusPage = `<html><head><title>DB Updates</title></head><body>
+<h1>DB Updates</h1>
+{{if .Error}}<p><strong>{{.Error}}</strong></p>{{end}}
+<table border="1" cellpadding="4">
+<tr><th>Shard</th><th>Update</th><th>Status</th><th>Applied At</th><th>Version</th><th>Detail</th></tr>
+{{range .Report}}<tr><td>{{.ShardName}}</td><td>{{.UpdateName}}</td><td>{{.Status}}</td><td>{{.AppliedAt}}</td><td>{{.Version}}</td><td>{{.Detail}}</td></tr>
+{{end}}</table>
+<h2>Plan</h2>
+{{range .Plan}}<h3>{{.ShardName}}: {{.UpdateName}} [{{.TxMode}}]</h3><p>{{.Description}}</p>{{if .Sql}}<pre>{{.Sql}}</pre>{{end}}
+{{else}}<p>Nothing to run.</p>
+{{end}}</body></html>`
+
+// Admin page handler (i.e., router.Get("/admin/db_updates", NewDbUpdateStatusHandler(sdu))).
+// ?format=json returns {"status": ..., "plan": ...}. Mount it behind the app's admin auth middleware.
+func NewDbUpdateStatusHandler(sdu *SystemDbUpdater) func(ctx *WebContext, rw web.ResponseWriter, req *web.Request) {
+	tmpl := template.Must(template.New("db_updates").Parse(dbUpdateStatusPage))
+
+	return func(ctx *WebContext, rw web.ResponseWriter, req *web.Request) {
+		applied, err := sdu.FetchApplied()
+
+		if err != nil {
+			if req.URL.Query().Get("format") == "json" {
+				ctx.JsonErrorResponse(rw, http.StatusInternalServerError, err)
+				return
+			}
+
+			rw.WriteHeader(http.StatusInternalServerError)
+			tmpl.Execute(rw, map[string]interface{}{"Error": err.Error()})
+			return
+		}
+
+		report := sdu.BuildStatus(applied)
+		plan := sdu.BuildPlan(applied)
+
+		if req.URL.Query().Get("format") == "json" {
+			out, err := json.Marshal(map[string]interface{}{"status": report, "plan": plan})
+
+			if err != nil {
+				ctx.JsonErrorResponse(rw, http.StatusInternalServerError, err)
+				return
+			}
+
+			ctx.JsonResponse(rw, http.StatusOK, string(out))
+			return
+		}
+
+		tmpl.Execute(rw, map[string]interface{}{"Report": report, "Plan": plan})
+	}
+}
@@ -0,0 +1,117 @@
+// +build unit
+
+package jgoweb
+
+import (
+	"github.com/jschneider98/jgoweb/config"
+	jgowebDb "github.com/jschneider98/jgoweb/db"
+	"strings"
+	"testing"
+)
+
+//
+func getTestStatusUpdater() (*SystemDbUpdater, map[string]map[string]SystemDbUpdate) {
+	db := &jgowebDb.Collection{Config: []config.DbConnOptions{{ShardName: "shard_1"}, {ShardName: "shard_2"}}}
+
+	one := NewSqlSystemDbUpdate("0001_one", "one", "0001_one.up.sql", "SELECT 1;")
+	two := NewSqlSystemDbUpdate("0002_two", "two", "0002_two.up.sql", "SELECT 2;")
+	two.TxMode = DbUpdateTxNone
+	three := NewSqlSystemDbUpdate("0003_three", "three", "0003_three.up.sql", "SELECT 3;")
+
+	applied1 := getTestAppliedUpdate("0001_one", one.GetChecksum())
+	applied1.SetCreatedAt("2020-01-01T00:00:00Z")
+
+	started := getTestAppliedUpdate("0002_two", two.GetChecksum())
+	started.SetStatus(DbUpdateStatusStarted)
+	started.SetProgress("1")
+
+	applied := map[string]map[string]SystemDbUpdate{
+		"shard_1": {"0001_one": applied1, "0002_two": started, "0000_old": getTestAppliedUpdate("0000_old", "")},
+		"shard_2": {"0001_one": getTestAppliedUpdate("0001_one", "changed")},
+	}
+
+	updates := []SystemDbUpdateInterface{one, two, three}
+
+	return NewSystemDbUpdater(db, updates, false), applied
+}
+
+//
+func TestSystemDbUpdaterBuildStatus(t *testing.T) {
+	sdu, applied := getTestStatusUpdater()
+
+	var result []string
+
+	for _, row := range sdu.BuildStatus(applied) {
+		result = append(result, row.ShardName+" "+row.UpdateName+" "+row.Status)
+	}
+
+	expected := strings.Join([]string{
+		"shard_1 0001_one applied",
+		"shard_1 0002_two failed",
+		"shard_1 0003_three pending",
+		"shard_1 0000_old drifted",
+		"shard_2 0001_one drifted",
+		"shard_2 0002_two pending",
+		"shard_2 0003_three pending",
+	}, "\n")
+
+	if strings.Join(result, "\n") != expected {
+		t.Errorf("\nERROR: Unexpected status\nExpected:\n%s\nResult:\n%s\n", expected, strings.Join(result, "\n"))
+	}
+
+	report := sdu.BuildStatus(applied)
+
+	if report[0].AppliedAt != "2020-01-01T00:00:00Z" || report.Counts()[DbUpdateStatusPending] != 3 {
+		t.Errorf("\nERROR: Unexpected report: %v\n", report)
+	}
+
+	table := report.Table()
+
+	if !strings.HasPrefix(table, "SHARD") || !strings.Contains(table, "(progress: 1)") {
+		t.Errorf("\nERROR: Unexpected table:\n%s\n", table)
+	}
+
+	out, err := report.Json()
+
+	if err != nil || !strings.Contains(out, `"status": "failed"`) {
+		t.Errorf("\nERROR: Unexpected JSON: %s Error: %v\n", out, err)
+	}
+
+	sdu.ExcludeShards = []string{"shard_1"}
+
+	if len(sdu.BuildStatus(applied)) != 3 {
+		t.Errorf("\nERROR: Expected excluded shard to be skipped\n")
+	}
+}
+
+//
+func TestSystemDbUpdaterBuildPlan(t *testing.T) {
+	sdu, applied := getTestStatusUpdater()
+
+	plan := sdu.BuildPlan(applied)
+
+	var result []string
+
+	for _, step := range plan {
+		result = append(result, step.ShardName+" "+step.UpdateName+" "+step.TxMode+" "+step.Resume)
+	}
+
+	expected := strings.Join([]string{
+		"shard_1 0002_two none 1",
+		"shard_1 0003_three batch ",
+		"shard_2 0002_two none ",
+		"shard_2 0003_three batch ",
+	}, "\n")
+
+	if strings.Join(result, "\n") != expected {
+		t.Errorf("\nERROR: Unexpected plan\nExpected:\n%s\nResult:\n%s\n", expected, strings.Join(result, "\n"))
+	}
+
+	if plan[1].Sql != "SELECT 3;" || !strings.Contains(plan.Table(), "SELECT 3;") {
+		t.Errorf("\nERROR: Expected SQL text in the plan:\n%s\n", plan.Table())
+	}
+
+	if (DbUpdatePlan{}).Table() != "Nothing to run.\n" {
+		t.Errorf("\nERROR: Unexpected empty plan\n")
+	}
+}
@@ -162,6 +162,180 @@ func TestSystemDbUpdateCreatedAt(t *testing.T) {
 	MockSystemDbUpdate.SetCreatedAt(origVal)
 }
 
+//
+func TestSystemDbUpdateChecksum(t *testing.T) {
+	InitMockSystemDbUpdate()
+	origVal := MockSystemDbUpdate.GetChecksum()
+	testVal := "test"
+
+	MockSystemDbUpdate.SetChecksum("")
+
+	if MockSystemDbUpdate.Checksum.Valid {
+		t.Errorf("ERROR: Checksum should be invalid.\n")
+	}
+
+	if MockSystemDbUpdate.GetChecksum() != "" {
+		t.Errorf("ERROR: Set Checksum failed. Should have a blank value. Got: %s", MockSystemDbUpdate.GetChecksum())
+	}
+
+	MockSystemDbUpdate.SetChecksum(testVal)
+
+	if !MockSystemDbUpdate.Checksum.Valid {
+		t.Errorf("ERROR: Checksum should be valid.\n")
+	}
+
+	if MockSystemDbUpdate.GetChecksum() != testVal {
+		t.Errorf("ERROR: Set Checksum failed. Expected: %s, Got: %s", testVal, MockSystemDbUpdate.GetChecksum())
+	}
+
+	MockSystemDbUpdate.SetChecksum(origVal)
+}
+
+//
+func TestSystemDbUpdateAppliedBy(t *testing.T) {
+	InitMockSystemDbUpdate()
+	origVal := MockSystemDbUpdate.GetAppliedBy()
+	testVal := "test"
+
+	MockSystemDbUpdate.SetAppliedBy("")
+
+	if MockSystemDbUpdate.AppliedBy.Valid {
+		t.Errorf("ERROR: AppliedBy should be invalid.\n")
+	}
+
+	if MockSystemDbUpdate.GetAppliedBy() != "" {
+		t.Errorf("ERROR: Set AppliedBy failed. Should have a blank value. Got: %s", MockSystemDbUpdate.GetAppliedBy())
+	}
+
+	MockSystemDbUpdate.SetAppliedBy(testVal)
+
+	if !MockSystemDbUpdate.AppliedBy.Valid {
+		t.Errorf("ERROR: AppliedBy should be valid.\n")
+	}
+
+	if MockSystemDbUpdate.GetAppliedBy() != testVal {
+		t.Errorf("ERROR: Set AppliedBy failed. Expected: %s, Got: %s", testVal, MockSystemDbUpdate.GetAppliedBy())
+	}
+
+	MockSystemDbUpdate.SetAppliedBy(origVal)
+}
+
+//
+func TestSystemDbUpdateDurationMs(t *testing.T) {
+	InitMockSystemDbUpdate()
+	origVal := MockSystemDbUpdate.GetDurationMs()
+	testVal := "test"
+
+	MockSystemDbUpdate.SetDurationMs("")
+
+	if MockSystemDbUpdate.DurationMs.Valid {
+		t.Errorf("ERROR: DurationMs should be invalid.\n")
+	}
+
+	if MockSystemDbUpdate.GetDurationMs() != "" {
+		t.Errorf("ERROR: Set DurationMs failed. Should have a blank value. Got: %s", MockSystemDbUpdate.GetDurationMs())
+	}
+
+	MockSystemDbUpdate.SetDurationMs(testVal)
+
+	if !MockSystemDbUpdate.DurationMs.Valid {
+		t.Errorf("ERROR: DurationMs should be valid.\n")
+	}
+
+	if MockSystemDbUpdate.GetDurationMs() != testVal {
+		t.Errorf("ERROR: Set DurationMs failed. Expected: %s, Got: %s", testVal, MockSystemDbUpdate.GetDurationMs())
+	}
+
+	MockSystemDbUpdate.SetDurationMs(origVal)
+}
+
+//
+func TestSystemDbUpdateVersion(t *testing.T) {
+	InitMockSystemDbUpdate()
+	origVal := MockSystemDbUpdate.GetVersion()
+	testVal := "test"
+
+	MockSystemDbUpdate.SetVersion("")
+
+	if MockSystemDbUpdate.Version.Valid {
+		t.Errorf("ERROR: Version should be invalid.\n")
+	}
+
+	if MockSystemDbUpdate.GetVersion() != "" {
+		t.Errorf("ERROR: Set Version failed. Should have a blank value. Got: %s", MockSystemDbUpdate.GetVersion())
+	}
+
+	MockSystemDbUpdate.SetVersion(testVal)
+
+	if !MockSystemDbUpdate.Version.Valid {
+		t.Errorf("ERROR: Version should be valid.\n")
+	}
+
+	if MockSystemDbUpdate.GetVersion() != testVal {
+		t.Errorf("ERROR: Set Version failed. Expected: %s, Got: %s", testVal, MockSystemDbUpdate.GetVersion())
+	}
+
+	MockSystemDbUpdate.SetVersion(origVal)
+}
+
+//
+func TestSystemDbUpdateStatus(t *testing.T) {
+	InitMockSystemDbUpdate()
+	origVal := MockSystemDbUpdate.GetStatus()
+	testVal := "test"
+
+	MockSystemDbUpdate.SetStatus("")
+
+	if MockSystemDbUpdate.Status.Valid {
+		t.Errorf("ERROR: Status should be invalid.\n")
+	}
+
+	if MockSystemDbUpdate.GetStatus() != "" {
+		t.Errorf("ERROR: Set Status failed. Should have a blank value. Got: %s", MockSystemDbUpdate.GetStatus())
+	}
+
+	MockSystemDbUpdate.SetStatus(testVal)
+
+	if !MockSystemDbUpdate.Status.Valid {
+		t.Errorf("ERROR: Status should be valid.\n")
+	}
+
+	if MockSystemDbUpdate.GetStatus() != testVal {
+		t.Errorf("ERROR: Set Status failed. Expected: %s, Got: %s", testVal, MockSystemDbUpdate.GetStatus())
+	}
+
+	MockSystemDbUpdate.SetStatus(origVal)
+}
+
+//
+func TestSystemDbUpdateProgress(t *testing.T) {
+	InitMockSystemDbUpdate()
+	origVal := MockSystemDbUpdate.GetProgress()
+	testVal := "test"
+
+	MockSystemDbUpdate.SetProgress("")
+
+	if MockSystemDbUpdate.Progress.Valid {
+		t.Errorf("ERROR: Progress should be invalid.\n")
+	}
+
+	if MockSystemDbUpdate.GetProgress() != "" {
+		t.Errorf("ERROR: Set Progress failed. Should have a blank value. Got: %s", MockSystemDbUpdate.GetProgress())
+	}
+
+	MockSystemDbUpdate.SetProgress(testVal)
+
+	if !MockSystemDbUpdate.Progress.Valid {
+		t.Errorf("ERROR: Progress should be valid.\n")
+	}
+
+	if MockSystemDbUpdate.GetProgress() != testVal {
+		t.Errorf("ERROR: Set Progress failed. Expected: %s, Got: %s", testVal, MockSystemDbUpdate.GetProgress())
+	}
+
+	MockSystemDbUpdate.SetProgress(origVal)
+}
+
 //
 func TestSystemDbUpdateInsert(t *testing.T) {
 	InitMockSystemDbUpdate()
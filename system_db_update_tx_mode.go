@@ -0,0 +1,165 @@
+package jgoweb
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// How an update runs. batch = the shard's shared transaction, own = its own transaction,
+// none = no transaction (i.e., CREATE INDEX CONCURRENTLY, ALTER TYPE ... ADD VALUE, large backfills).
+const (
+	DbUpdateTxBatch = "batch"
+	DbUpdateTxOwn   = "own"
+	DbUpdateTxNone  = "none"
+)
+
+// Non-transactional updates are recorded as started before they run. Started updates are run again (resumed).
+const (
+	DbUpdateStatusStarted = "started"
+	DbUpdateStatusApplied = "applied"
+)
+
+// Optional. Updates that don't run in the shared batch transaction.
+type SystemDbUpdateTxModeInterface interface {
+	GetTxMode() string
+}
+
+// Optional. Non-transactional updates that record their progress so a failed run can resume.
+type SystemDbUpdateProgressInterface interface {
+	SetStarted() error
+	GetProgress() string
+	SaveProgress(val string) error
+}
+
+// Defaults to batch
+func GetDbUpdateTxMode(update SystemDbUpdateInterface) string {
+
+	if withMode, ok := update.(SystemDbUpdateTxModeInterface); ok && withMode.GetTxMode() != "" {
+		return withMode.GetTxMode()
+	}
+
+	return DbUpdateTxBatch
+}
+
+//
+func ValidateDbUpdateTxMode(mode string) error {
+
+	switch mode {
+	case "", DbUpdateTxBatch, DbUpdateTxOwn, DbUpdateTxNone:
+		return nil
+	}
+
+	return errors.New(fmt.Sprintf("Unknown DB update transaction mode: %s", mode))
+}
+
+// Split SQL into statements on semicolons outside of quotes, dollar quotes and comments.
+// Comment-only statements are dropped.
+func SplitSqlStatements(query string) []string {
+	var statements []string
+	var current strings.Builder
+
+	hasCode := false
+	flush := func() {
+		statement := strings.TrimSpace(current.String())
+
+		if hasCode && statement != "" {
+			statements = append(statements, statement)
+		}
+
+		current.Reset()
+		hasCode = false
+	}
+
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		rest := query[i:]
+
+		switch {
+		case strings.HasPrefix(rest, "--"):
+			end := strings.Index(rest, "\n")
+
+			if end == -1 {
+				end = len(rest) - 1
+			}
+
+			current.WriteString(rest[:end+1])
+			i += end
+		case strings.HasPrefix(rest, "/*"):
+			end := strings.Index(rest[2:], "*/")
+
+			if end == -1 {
+				end = len(rest) - 4
+			}
+
+			current.WriteString(rest[:end+4])
+			i += end + 3
+		case c == '\'' || c == '"':
+			end := sqlQuotedEnd(rest, c)
+
+			current.WriteString(rest[:end+1])
+			i += end
+			hasCode = true
+		case c == '$' && sqlDollarTag(rest) != "":
+			tag := sqlDollarTag(rest)
+			end := strings.Index(rest[len(tag):], tag)
+
+			if end == -1 {
+				end = len(rest) - 2*len(tag)
+			}
+
+			current.WriteString(rest[:end+2*len(tag)])
+			i += end + 2*len(tag) - 1
+			hasCode = true
+		case c == ';':
+			flush()
+		default:
+			current.WriteByte(c)
+
+			if c != ' ' && c != '\t' && c != '\n' && c != '\r' {
+				hasCode = true
+			}
+		}
+	}
+
+	flush()
+
+	return statements
+}
+
+// Index of the closing quote (doubled quotes are escapes)
+func sqlQuotedEnd(rest string, quote byte) int {
+
+	for i := 1; i < len(rest); i++ {
+		if rest[i] != quote {
+			continue
+		}
+
+		if i+1 < len(rest) && rest[i+1] == quote {
+			i++
+			continue
+		}
+
+		return i
+	}
+
+	return len(rest) - 1
+}
+
+// i.e., "$$" or "$body$". "" if rest doesn't start with a dollar quote tag.
+func sqlDollarTag(rest string) string {
+
+	for i := 1; i < len(rest); i++ {
+		c := rest[i]
+
+		if c == '$' {
+			return rest[:i+1]
+		}
+
+		if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 1 && c >= '0' && c <= '9') {
+			return ""
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,97 @@
+// +build unit
+
+package jgoweb
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+//
+func TestSplitSqlStatements(t *testing.T) {
+	query := `-- header
+CREATE INDEX CONCURRENTLY jobs_status ON queue.jobs (status);
+INSERT INTO t (a) VALUES ('semi;colon'), ('it''s');
+/* block; comment */
+CREATE FUNCTION f() RETURNS int AS $body$ SELECT 1; $body$ LANGUAGE sql;
+SELECT "odd;name" FROM t -- trailing; comment
+;
+-- only a comment;
+`
+
+	expected := []string{
+		"-- header\nCREATE INDEX CONCURRENTLY jobs_status ON queue.jobs (status)",
+		"INSERT INTO t (a) VALUES ('semi;colon'), ('it''s')",
+		"/* block; comment */\nCREATE FUNCTION f() RETURNS int AS $body$ SELECT 1; $body$ LANGUAGE sql",
+		"SELECT \"odd;name\" FROM t -- trailing; comment",
+	}
+
+	statements := SplitSqlStatements(query)
+
+	if len(statements) != len(expected) {
+		t.Errorf("\nERROR: Expected %d statements. Got: %q\n", len(expected), statements)
+		return
+	}
+
+	for i := range expected {
+		if statements[i] != expected[i] {
+			t.Errorf("\nERROR: Statement %d\nExpected: %q\nResult: %q\n", i, expected[i], statements[i])
+		}
+	}
+
+	statements = SplitSqlStatements("SELECT $1; SELECT $$;$$")
+
+	if len(statements) != 2 || statements[1] != "SELECT $$;$$" {
+		t.Errorf("\nERROR: Unexpected statements: %q\n", statements)
+	}
+}
+
+//
+func TestGetDbUpdateTxMode(t *testing.T) {
+	update := CreateSystemDbUpdateNoContext("0001_test", "test")
+
+	if GetDbUpdateTxMode(update) != DbUpdateTxBatch {
+		t.Errorf("\nERROR: Expected batch mode by default\n")
+	}
+
+	update.TxMode = DbUpdateTxNone
+
+	if GetDbUpdateTxMode(update.Clone()) != DbUpdateTxNone {
+		t.Errorf("\nERROR: Expected none mode\n")
+	}
+
+	if ValidateDbUpdateTxMode("sometimes") == nil {
+		t.Errorf("\nERROR: Expected unknown mode error\n")
+	}
+}
+
+//
+func TestLoadSqlDbUpdatesTxMode(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0001_index.up.sql": {Data: []byte("-- Jobs index\n-- jgoweb:tx none\nCREATE INDEX CONCURRENTLY ...;")},
+		"0002_plain.up.sql": {Data: []byte("SELECT 1;")},
+	}
+
+	updates, err := LoadSqlDbUpdates(fsys, ".")
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+		return
+	}
+
+	if GetDbUpdateTxMode(updates[0]) != DbUpdateTxNone || GetDbUpdateTxMode(updates[1]) != DbUpdateTxBatch {
+		t.Errorf("\nERROR: Unexpected modes: %s, %s\n", GetDbUpdateTxMode(updates[0]), GetDbUpdateTxMode(updates[1]))
+	}
+
+	if updates[0].GetDescription() != "Jobs index" {
+		t.Errorf("\nERROR: Mode comment should not be in the description: %s\n", updates[0].GetDescription())
+	}
+
+	fsys = fstest.MapFS{"0001_bad.up.sql": {Data: []byte("-- jgoweb:tx sometimes\nSELECT 1;")}}
+
+	_, err = LoadSqlDbUpdates(fsys, ".")
+
+	if err == nil {
+		t.Errorf("\nERROR: Expected unknown mode error\n")
+	}
+}
@@ -1,25 +1,41 @@
 package jgoweb
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/gocraft/dbr"
 	jgowebDb "github.com/jschneider98/jgoweb/db"
 	"github.com/jschneider98/jgoweb/util"
+	"os"
+	"strconv"
 	"sync"
 	"time"
 )
 
 //
+// Version is recorded with each applied update (i.e., the app's release).
+// SkipNonTxInDryRun skips non-transactional updates in dry run mode (instead of failing).
+// See RunCluster for shard selection, parallelism, canary and error options.
 type SystemDbUpdater struct {
-	Db        *jgowebDb.Collection
-	DbUpdates []SystemDbUpdateInterface
-	DryRun    bool
+	Db                *jgowebDb.Collection
+	DbUpdates         []SystemDbUpdateInterface
+	DryRun            bool
+	Version           string
+	SkipNonTxInDryRun bool
+	MaxParallelShards int
+	IncludeShards     []string
+	ExcludeShards     []string
+	Canary            bool
+	CanaryShard       string
+	CanaryCheck       func(ctx ContextInterface) error
+	ContinueOnError   bool
+	LockTimeout       time.Duration
 }
 
 //
 func NewSystemDbUpdater(db *jgowebDb.Collection, updates []SystemDbUpdateInterface, dryRun bool) *SystemDbUpdater {
-	sdu := &SystemDbUpdater{db, updates, dryRun}
+	sdu := &SystemDbUpdater{Db: db, DbUpdates: updates, DryRun: dryRun}
 
 	return sdu
 }
@@ -33,11 +49,8 @@ func (sdu *SystemDbUpdater) SetDebug(debug bool) {
 func (sdu *SystemDbUpdater) GetDbUpdateInfo() (map[string][]SystemDbUpdateInterface, error) {
 	info := make(map[string][]SystemDbUpdateInterface)
 
-	for dbName, dbConn := range sdu.Db.GetConns() {
-		ctx := NewContext(sdu.Db)
-		ctx.SetDbSession(dbConn.NewSession(nil))
-
-		info[dbName] = make([]SystemDbUpdateInterface, 0)
+	results := NewClusterExecutor(sdu.Db).Run(context.Background(), func(goCtx context.Context, ctx ContextInterface) (interface{}, error) {
+		updates := make([]SystemDbUpdateInterface, 0)
 
 		for _, update := range sdu.DbUpdates {
 			up, err := CreateSystemDbUpdateByUpdateName(ctx, update.GetUpdateName())
@@ -50,124 +63,446 @@ func (sdu *SystemDbUpdater) GetDbUpdateInfo() (map[string][]SystemDbUpdateInterf
 				up.SetDescription(update.GetDescription())
 			}
 
-			info[dbName] = append(info[dbName], up)
+			updates = append(updates, up)
 		}
+
+		return updates, nil
+	})
+
+	err := results.Err()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, result := range results {
+		info[result.ShardName] = result.Value.([]SystemDbUpdateInterface)
 	}
 
 	return info, nil
 }
 
-//
+// Run the updates on the selected shards. See RunCluster for the per shard summary.
 func (sdu *SystemDbUpdater) RunAll() error {
 	util.Debugln("Starting DB updater...")
 
-	var errcList []<-chan error
-
-	for dbName, dbConn := range sdu.Db.GetConns() {
-		util.Debugln("Applying updates for " + dbName)
+	summary, err := sdu.RunCluster()
 
-		errc := sdu.RunAllByDbSession(dbConn.NewSession(nil), dbName)
-		errcList = append(errcList, errc)
+	if err != nil {
+		return err
 	}
 
-	return sdu.WaitForPipeline(errcList...)
+	util.Debugln(summary.String())
+
+	return summary.Err()
 }
 
 //
 func (sdu *SystemDbUpdater) RunAllByDbSession(dbSess *dbr.Session, dbName string) <-chan error {
-	var err error
-	// var tx *dbr.Tx
 	errc := make(chan error, 1)
 
+	go func() {
+		defer close(errc)
+
+		err := sdu.RunByDbSession(dbSess, dbName)
+
+		if err != nil {
+			errc <- err
+		}
+	}()
+
+	return errc
+}
+
+// Run the updates in order. Batch updates share a transaction, which is committed before an update that
+// runs in its own transaction (or none). In dry run mode everything runs in one transaction that's rolled back,
+// so non-transactional updates are refused (or skipped with SkipNonTxInDryRun).
+func (sdu *SystemDbUpdater) RunByDbSession(dbSess *dbr.Session, dbName string) error {
+	_, err := sdu.runShard(dbSess, dbName)
+
+	return err
+}
+
+// Returns the names of the updates that ran
+func (sdu *SystemDbUpdater) runShard(dbSess *dbr.Session, dbName string) ([]string, error) {
 	ctx := NewContext(sdu.Db)
 	ctx.SetDbSession(dbSess)
 
-	_, err = ctx.Begin()
+	// one runner per DB
+	lock, err := ctx.TryAdvisoryLock(SystemDbUpdaterLock, sdu.LockTimeout)
 
 	if err != nil {
-		errc <- err
-		defer close(errc)
+		return nil, err
+	}
 
-		return errc
+	if lock == nil {
+		return nil, errors.New("ERROR: " + dbName + ": Another DB updater is running")
 	}
 
-	go func() {
-		defer close(errc)
+	defer lock.Unlock()
 
-		for _, update := range sdu.DbUpdates {
-			// Must clone/copy original update for goroutine to work
-			up := update.Clone()
-			up.SetContext(ctx)
+	if sdu.DryRun {
+		_, err = ctx.Begin()
+
+		if err != nil {
+			return nil, err
+		}
+	}
 
-			util.Debugf("Applying %s: '%s'\n", dbName, update.GetUpdateName())
+	err = EnsureSystemDbUpdateColumns(ctx)
 
-			err = sdu.Run(up, dbName)
+	if err != nil {
+		sdu.abortBatch(ctx)
+		return nil, err
+	}
 
-			if err != nil {
-				err = errors.New("ERROR: " + dbName + ": '" + update.GetUpdateName() + "': " + err.Error())
+	return sdu.runByTxMode(ctx, dbName, "Applying", sdu.DbUpdates, func(up SystemDbUpdateInterface) (bool, error) {
+		return sdu.apply(up, dbName)
+	})
+}
 
-				errc <- err
-				return
-			}
+// Runs step on each update using the update's tx mode (batch updates share a transaction). Returns the names of
+// the updates step ran.
+func (sdu *SystemDbUpdater) runByTxMode(ctx *WebContext, dbName string, action string, updates []SystemDbUpdateInterface, step func(SystemDbUpdateInterface) (bool, error)) ([]string, error) {
+	var err error
+	var applied []string
+	var pending []string
+
+	finishBatch := func() error {
+		err := sdu.finishBatch(ctx, dbName)
+
+		if err == nil {
+			applied = append(applied, pending...)
 		}
 
-		if sdu.DryRun {
-			util.Debugln(dbName + ": Dry Run. Rolling back changes.")
+		pending = nil
 
-			err = ctx.Rollback()
+		return err
+	}
 
-			if err != nil {
-				errc <- err
-				return
+	for _, update := range updates {
+		var ran bool
+
+		// Must clone/copy original update for goroutine to work
+		up := update.Clone()
+		up.SetContext(ctx)
+
+		mode := GetDbUpdateTxMode(up)
+
+		util.Debugf("%s %s: '%s' (%s)\n", action, dbName, update.GetUpdateName(), mode)
+
+		switch {
+		case mode == DbUpdateTxNone && sdu.DryRun && sdu.SkipNonTxInDryRun:
+			util.Debugf("%s: '%s' runs without a transaction. Skipping in dry run.\n", dbName, update.GetUpdateName())
+		case mode == DbUpdateTxNone && sdu.DryRun:
+			err = errors.New("runs without a transaction and can't be rolled back in dry run mode")
+		case mode == DbUpdateTxBatch || sdu.DryRun:
+			if ctx.Tx == nil {
+				_, err = ctx.Begin()
 			}
-		} else {
-			util.Debugln(dbName + ": Production run. Committing changes.")
 
-			err = ctx.Commit()
+			if err == nil {
+				ran, err = step(up)
+			}
 
-			if err != nil {
-				errc <- err
-				return
+			if ran && err == nil {
+				pending = append(pending, update.GetUpdateName())
+				ran = false
+			}
+		case mode == DbUpdateTxOwn:
+			err = finishBatch()
+
+			if err == nil {
+				err = ctx.WithTransaction(func(tx *dbr.Tx) error {
+					ran, err = step(up)
+					return err
+				}, nil)
 			}
+		case mode == DbUpdateTxNone:
+			err = finishBatch()
+
+			if err == nil {
+				ran, err = step(up)
+			}
+		default:
+			err = ValidateDbUpdateTxMode(mode)
 		}
-	}()
 
-	return errc
+		if err != nil {
+			sdu.abortBatch(ctx)
+			return applied, errors.New("ERROR: " + dbName + ": '" + update.GetUpdateName() + "': " + err.Error())
+		}
+
+		if ran {
+			applied = append(applied, update.GetUpdateName())
+		}
+	}
+
+	return applied, finishBatch()
+}
+
+// Commit (or roll back in dry run mode) the shared transaction
+func (sdu *SystemDbUpdater) finishBatch(ctx *WebContext, dbName string) error {
+
+	if ctx.Tx == nil {
+		return nil
+	}
+
+	if sdu.DryRun {
+		util.Debugln(dbName + ": Dry Run. Rolling back changes.")
+		return ctx.Rollback()
+	}
+
+	util.Debugln(dbName + ": Production run. Committing changes.")
+
+	return ctx.Commit()
+}
+
+//
+func (sdu *SystemDbUpdater) abortBatch(ctx *WebContext) {
+
+	if ctx.Tx != nil {
+		ctx.Rollback()
+	}
 }
 
 //
 func (sdu *SystemDbUpdater) Run(update SystemDbUpdateInterface, dbName string) error {
+	_, err := sdu.apply(update, dbName)
+
+	return err
+}
+
+// true if the update ran (i.e., it wasn't already applied)
+func (sdu *SystemDbUpdater) apply(update SystemDbUpdateInterface, dbName string) (bool, error) {
 	defer util.DebugTimeTrack(time.Now(), fmt.Sprintf("%s: %s", dbName, update.GetUpdateName()))
 
 	needsToRun, err := update.NeedsToRun()
 
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	if needsToRun {
+
+		if !sdu.DryRun && GetDbUpdateTxMode(update) == DbUpdateTxNone {
+			if progress, ok := update.(SystemDbUpdateProgressInterface); ok {
+				err = progress.SetStarted()
+
+				if err != nil {
+					return false, err
+				}
+			}
+		}
+
+		start := time.Now()
 		err := update.Run()
 
 		if err != nil {
-			return err
+			return false, err
+		}
+
+		if audited, ok := update.(SystemDbUpdateAuditInterface); ok {
+			hostname, _ := os.Hostname()
+
+			audited.SetAppliedBy(hostname)
+			audited.SetDurationMs(strconv.FormatInt(time.Since(start).Nanoseconds()/1000000, 10))
+			audited.SetVersion(sdu.Version)
 		}
 
 		if !sdu.DryRun {
 			err = update.SetComplete()
 
 			if err != nil {
-				return err
+				return false, err
 			}
 		}
 
 		util.Debugf("%s: '%s' done.\n", dbName, update.GetUpdateName())
-	} else {
-		util.Debugf("%s: '%s' already applied. Skipping.\n", dbName, update.GetUpdateName())
+
+		return true, nil
+	}
+
+	util.Debugf("%s: '%s' already applied. Skipping.\n", dbName, update.GetUpdateName())
+
+	return false, nil
+}
+
+// Roll back every update after target (target stays applied), newest first. shardName "" = every shard.
+// Nothing is changed if any shard has an applied update that's irreversible.
+func (sdu *SystemDbUpdater) RollbackTo(target string, shardName string) error {
+	var errcList []<-chan error
+
+	updates, err := sdu.GetRollbackUpdates(target)
+
+	if err != nil {
+		return err
+	}
+
+	shardNames := sdu.Db.GetNames()
+
+	if shardName != "" {
+		shardNames = []string{shardName}
+	}
+
+	for _, dbName := range shardNames {
+		dbSess, err := sdu.Db.GetSessionByName(dbName)
+
+		if err != nil {
+			return err
+		}
+
+		err = sdu.CheckReversible(dbSess, dbName, updates)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	util.Debugln("Starting DB rollback to '" + target + "'...")
+
+	for _, dbName := range shardNames {
+		dbSess, err := sdu.Db.GetSessionByName(dbName)
+
+		if err != nil {
+			return err
+		}
+
+		errc := sdu.RollbackByDbSession(dbSess, dbName, updates)
+		errcList = append(errcList, errc)
+	}
+
+	return sdu.WaitForPipeline(errcList...)
+}
+
+// Updates after target, newest first
+func (sdu *SystemDbUpdater) GetRollbackUpdates(target string) ([]SystemDbUpdateInterface, error) {
+	updates := make([]SystemDbUpdateInterface, 0)
+
+	for i := len(sdu.DbUpdates) - 1; i >= 0; i-- {
+
+		if sdu.DbUpdates[i].GetUpdateName() == target {
+			return updates, nil
+		}
+
+		updates = append(updates, sdu.DbUpdates[i])
+	}
+
+	return nil, errors.New(fmt.Sprintf("DB update %s does not exist", target))
+}
+
+// Error if an applied update is irreversible
+func (sdu *SystemDbUpdater) CheckReversible(dbSess *dbr.Session, dbName string, updates []SystemDbUpdateInterface) error {
+	ctx := NewContext(sdu.Db)
+	ctx.SetDbSession(dbSess)
+
+	for _, update := range updates {
+		up := update.Clone()
+		up.SetContext(ctx)
+
+		needsToRun, err := up.NeedsToRun()
+
+		if err != nil {
+			return errors.New("ERROR: " + dbName + ": '" + update.GetUpdateName() + "': " + err.Error())
+		}
+
+		if needsToRun {
+			continue
+		}
+
+		reversible, ok := up.(SystemDbUpdateRollbackInterface)
+
+		if !ok || !reversible.IsReversible() {
+			return errors.New("ERROR: " + dbName + ": '" + update.GetUpdateName() + "' is irreversible")
+		}
 	}
 
 	return nil
 }
 
+//
+func (sdu *SystemDbUpdater) RollbackByDbSession(dbSess *dbr.Session, dbName string, updates []SystemDbUpdateInterface) <-chan error {
+	errc := make(chan error, 1)
+
+	ctx := NewContext(sdu.Db)
+	ctx.SetDbSession(dbSess)
+
+	lock, err := ctx.TryAdvisoryLock(SystemDbUpdaterLock, sdu.LockTimeout)
+
+	if err == nil && lock == nil {
+		err = errors.New("ERROR: " + dbName + ": Another DB updater is running")
+	}
+
+	if err != nil {
+		errc <- err
+		defer close(errc)
+
+		return errc
+	}
+
+	go func() {
+		defer close(errc)
+		defer lock.Unlock()
+
+		_, err := sdu.runByTxMode(ctx, dbName, "Reverting", updates, func(up SystemDbUpdateInterface) (bool, error) {
+			return sdu.revert(up, dbName)
+		})
+
+		if err != nil {
+			errc <- err
+		}
+	}()
+
+	return errc
+}
+
+//
+func (sdu *SystemDbUpdater) Revert(update SystemDbUpdateInterface, dbName string) error {
+	_, err := sdu.revert(update, dbName)
+
+	return err
+}
+
+// true if the update was rolled back (i.e., it was applied)
+func (sdu *SystemDbUpdater) revert(update SystemDbUpdateInterface, dbName string) (bool, error) {
+	defer util.DebugTimeTrack(time.Now(), fmt.Sprintf("%s: revert %s", dbName, update.GetUpdateName()))
+
+	needsToRun, err := update.NeedsToRun()
+
+	if err != nil {
+		return false, err
+	}
+
+	if needsToRun {
+		util.Debugf("%s: '%s' not applied. Skipping.\n", dbName, update.GetUpdateName())
+		return false, nil
+	}
+
+	reversible, ok := update.(SystemDbUpdateRollbackInterface)
+
+	if !ok || !reversible.IsReversible() {
+		return false, errors.New("irreversible")
+	}
+
+	err = reversible.Rollback()
+
+	if err != nil {
+		return false, err
+	}
+
+	if !sdu.DryRun {
+		err = reversible.SetIncomplete()
+
+		if err != nil {
+			return false, err
+		}
+	}
+
+	util.Debugf("%s: '%s' rolled back.\n", dbName, update.GetUpdateName())
+
+	return true, nil
+}
+
 // MergeErrors merges multiple channels of errors.
 // Based on https://blog.golang.org/pipelines.
 // Based on https://medium.com/statuscode/pipeline-patterns-in-go-a37bb3a7e61d
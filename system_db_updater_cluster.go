@@ -0,0 +1,254 @@
+package jgoweb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// Advisory lock held on each DB while updates run, so concurrent deploys can't both run them
+const SystemDbUpdaterLock = "jgoweb.system_db_updater"
+
+const (
+	DbUpdaterShardOk      = "ok"
+	DbUpdaterShardFailed  = "failed"
+	DbUpdaterShardSkipped = "skipped"
+)
+
+var errDbUpdaterHalted = errors.New("Halted after an error on another shard")
+
+// Outcome of a run on one shard
+type SystemDbUpdaterShardResult struct {
+	ShardName  string   `json:"shardName"`
+	Status     string   `json:"status"`
+	Applied    []string `json:"applied"`
+	Error      string   `json:"error"`
+	DurationMs int64    `json:"durationMs"`
+}
+
+// Per shard results (sorted by shard name)
+type SystemDbUpdaterSummary []SystemDbUpdaterShardResult
+
+// Shards to update (sorted). IncludeShards/ExcludeShards are path.Match patterns (i.e., "shard_*").
+func (sdu *SystemDbUpdater) GetShardNames() []string {
+	shardNames := make([]string, 0)
+
+	for _, shardName := range sdu.Db.GetNames() {
+
+		if len(sdu.IncludeShards) > 0 && !MatchShardName(sdu.IncludeShards, shardName) {
+			continue
+		}
+
+		if MatchShardName(sdu.ExcludeShards, shardName) {
+			continue
+		}
+
+		shardNames = append(shardNames, shardName)
+	}
+
+	sort.Strings(shardNames)
+
+	return shardNames
+}
+
+//
+func MatchShardName(patterns []string, shardName string) bool {
+
+	for _, pattern := range patterns {
+		if match, _ := path.Match(pattern, shardName); match {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Run the updates on the selected shards, at most MaxParallelShards at a time (0 = all at once).
+// With Canary, CanaryShard (default: the first selected shard) is updated and verified before the rest.
+// Unless ContinueOnError is set, a failed shard stops shards that haven't started yet.
+// The error is for problems starting the run. Shard errors are in the summary (see Err).
+func (sdu *SystemDbUpdater) RunCluster() (SystemDbUpdaterSummary, error) {
+	summary := make(SystemDbUpdaterSummary, 0)
+	shardNames := sdu.GetShardNames()
+
+	if len(shardNames) == 0 {
+		return nil, errors.New("No shards selected")
+	}
+
+	if sdu.Canary {
+		canary := sdu.CanaryShard
+
+		if canary == "" {
+			canary = shardNames[0]
+		}
+
+		rest := make([]string, 0)
+
+		for _, shardName := range shardNames {
+			if shardName != canary {
+				rest = append(rest, shardName)
+			}
+		}
+
+		if len(rest) == len(shardNames) {
+			return nil, errors.New(fmt.Sprintf("Canary shard %s is not selected", canary))
+		}
+
+		result := sdu.runShards([]string{canary})[0]
+
+		if result.Status == DbUpdaterShardOk {
+			err := sdu.VerifyCanary(canary)
+
+			if err != nil {
+				result.Status = DbUpdaterShardFailed
+				result.Error = "Canary verification failed: " + err.Error()
+			}
+		}
+
+		summary = append(summary, result)
+
+		if result.Status != DbUpdaterShardOk {
+			for _, shardName := range rest {
+				summary = append(summary, SystemDbUpdaterShardResult{ShardName: shardName, Status: DbUpdaterShardSkipped, Error: "Canary failed"})
+			}
+
+			sort.Slice(summary, func(i, j int) bool { return summary[i].ShardName < summary[j].ShardName })
+
+			return summary, nil
+		}
+
+		shardNames = rest
+	}
+
+	summary = append(summary, sdu.runShards(shardNames)...)
+
+	sort.Slice(summary, func(i, j int) bool { return summary[i].ShardName < summary[j].ShardName })
+
+	return summary, nil
+}
+
+//
+func (sdu *SystemDbUpdater) runShards(shardNames []string) SystemDbUpdaterSummary {
+	var halted int32
+	summary := make(SystemDbUpdaterSummary, 0)
+
+	if len(shardNames) == 0 {
+		return summary
+	}
+
+	ce := NewClusterExecutor(sdu.Db).SetShardNames(shardNames...)
+	ce.MaxConcurrency = sdu.MaxParallelShards
+
+	results := ce.RunWithShardName(context.Background(), func(goCtx context.Context, shardName string, ctx ContextInterface) (interface{}, error) {
+
+		if !sdu.ContinueOnError && atomic.LoadInt32(&halted) == 1 {
+			return nil, errDbUpdaterHalted
+		}
+
+		// no statement timeout for updates
+		dbConn, err := sdu.Db.GetConnByName(shardName)
+
+		if err != nil {
+			return nil, err
+		}
+
+		applied, err := sdu.runShard(dbConn.NewSession(nil), shardName)
+
+		if err != nil {
+			atomic.StoreInt32(&halted, 1)
+		}
+
+		return applied, err
+	})
+
+	for _, result := range results {
+		row := SystemDbUpdaterShardResult{ShardName: result.ShardName, Status: DbUpdaterShardOk}
+		row.DurationMs = result.Duration.Nanoseconds() / 1000000
+
+		if applied, ok := result.Value.([]string); ok {
+			row.Applied = applied
+		}
+
+		if result.Err == errDbUpdaterHalted {
+			row.Status = DbUpdaterShardSkipped
+			row.Error = result.Err.Error()
+		} else if result.Err != nil {
+			row.Status = DbUpdaterShardFailed
+			row.Error = result.Err.Error()
+		}
+
+		summary = append(summary, row)
+	}
+
+	return summary
+}
+
+// Every update is applied on the canary (skipped in dry run mode) and CanaryCheck passes
+func (sdu *SystemDbUpdater) VerifyCanary(shardName string) error {
+	dbSess, err := sdu.Db.GetSessionByName(shardName)
+
+	if err != nil {
+		return err
+	}
+
+	ctx := NewContext(sdu.Db)
+	ctx.SetDbSession(dbSess)
+
+	if !sdu.DryRun {
+		for _, update := range sdu.DbUpdates {
+			up := update.Clone()
+			up.SetContext(ctx)
+
+			needsToRun, err := up.NeedsToRun()
+
+			if err != nil {
+				return err
+			}
+
+			if needsToRun {
+				return errors.New(fmt.Sprintf("'%s' is not applied", update.GetUpdateName()))
+			}
+		}
+	}
+
+	if sdu.CanaryCheck != nil {
+		return sdu.CanaryCheck(ctx)
+	}
+
+	return nil
+}
+
+// ******
+
+// nil if every shard is ok
+func (summary SystemDbUpdaterSummary) Err() error {
+
+	for _, result := range summary {
+		if result.Status != DbUpdaterShardOk {
+			return errors.New(summary.String())
+		}
+	}
+
+	return nil
+}
+
+// One line per shard
+func (summary SystemDbUpdaterSummary) String() string {
+	var lines []string
+
+	for _, result := range summary {
+		line := fmt.Sprintf("%s: %s (%d applied, %dms)", result.ShardName, result.Status, len(result.Applied), result.DurationMs)
+
+		if result.Error != "" {
+			line += ": " + result.Error
+		}
+
+		lines = append(lines, line)
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
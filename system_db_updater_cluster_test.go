@@ -0,0 +1,60 @@
+// +build unit
+
+package jgoweb
+
+import (
+	"github.com/jschneider98/jgoweb/config"
+	jgowebDb "github.com/jschneider98/jgoweb/db"
+	"strings"
+	"testing"
+)
+
+//
+func TestSystemDbUpdaterGetShardNames(t *testing.T) {
+	db := &jgowebDb.Collection{Config: []config.DbConnOptions{{ShardName: "shard_2"}, {ShardName: "shard_1"}, {ShardName: "audit"}}}
+	sdu := NewSystemDbUpdater(db, nil, true)
+
+	tests := []struct {
+		include  []string
+		exclude  []string
+		expected string
+	}{
+		{nil, nil, "audit,shard_1,shard_2"},
+		{[]string{"shard_*"}, nil, "shard_1,shard_2"},
+		{[]string{"shard_*"}, []string{"shard_2"}, "shard_1"},
+		{nil, []string{"shard_?"}, "audit"},
+		{[]string{"nope"}, nil, ""},
+	}
+
+	for _, test := range tests {
+		sdu.IncludeShards = test.include
+		sdu.ExcludeShards = test.exclude
+
+		result := strings.Join(sdu.GetShardNames(), ",")
+
+		if result != test.expected {
+			t.Errorf("\nERROR: include %v exclude %v\nExpected: %s\nResult: %s\n", test.include, test.exclude, test.expected, result)
+		}
+	}
+}
+
+//
+func TestSystemDbUpdaterSummary(t *testing.T) {
+	summary := SystemDbUpdaterSummary{
+		{ShardName: "shard_1", Status: DbUpdaterShardOk, Applied: []string{"a", "b"}, DurationMs: 5},
+	}
+
+	if summary.Err() != nil {
+		t.Errorf("\nERROR: Unexpected error: %v\n", summary.Err())
+	}
+
+	if summary.String() != "shard_1: ok (2 applied, 5ms)\n" {
+		t.Errorf("\nERROR: Unexpected summary: %s\n", summary.String())
+	}
+
+	summary = append(summary, SystemDbUpdaterShardResult{ShardName: "shard_2", Status: DbUpdaterShardSkipped, Error: "Canary failed"})
+
+	if summary.Err() == nil || !strings.Contains(summary.Err().Error(), "shard_2: skipped (0 applied, 0ms): Canary failed") {
+		t.Errorf("\nERROR: Expected error. Got: %v\n", summary.Err())
+	}
+}
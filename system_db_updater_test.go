@@ -3,6 +3,8 @@
 package jgoweb
 
 import (
+	"errors"
+	"strings"
 	"testing"
 )
 
@@ -48,3 +50,328 @@ func TestSystemDbUpdaterRunAll(t *testing.T) {
 		return
 	}
 }
+
+//
+func TestSystemDbUpdaterRollbackTo(t *testing.T) {
+	InitMockCtx()
+
+	reverted := false
+	shardName := appConfig.Integration.ShardName
+
+	target := CreateSystemDbUpdateNoContext("Test Rollback Target", "Test Rollback Target")
+	target.ApplyUpdate = func(ctx ContextInterface) error { return nil }
+
+	reversible := CreateSystemDbUpdateNoContext("Test Rollback Reversible", "Test Rollback Reversible")
+	reversible.ApplyUpdate = func(ctx ContextInterface) error { return nil }
+	reversible.RevertUpdate = func(ctx ContextInterface) error {
+		reverted = true
+		return nil
+	}
+
+	irreversible := CreateSystemDbUpdateNoContext("Test Rollback Irreversible", "Test Rollback Irreversible")
+	irreversible.ApplyUpdate = func(ctx ContextInterface) error { return nil }
+
+	defer func() {
+		for _, update := range []*SystemDbUpdate{target, reversible, irreversible} {
+			up := update.Clone()
+			up.SetContext(MockCtx)
+			up.(*SystemDbUpdate).SetIncomplete()
+		}
+	}()
+
+	updates := []SystemDbUpdateInterface{target, reversible}
+	sdu := NewSystemDbUpdater(MockCtx.Db, updates, false)
+
+	err := sdu.RunAll()
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+		return
+	}
+
+	err = sdu.RollbackTo("Test Rollback Target", shardName)
+
+	if err != nil || !reverted {
+		t.Errorf("\nERROR: Rollback failed. Reverted: %v Error: %v\n", reverted, err)
+		return
+	}
+
+	update, err := FetchSystemDbUpdateByUpdateName(MockCtx, "Test Rollback Reversible")
+
+	if err != nil || update != nil {
+		t.Errorf("\nERROR: Rolled back update is still recorded. Error: %v\n", err)
+	}
+
+	// irreversible updates block the whole rollback
+	updates = []SystemDbUpdateInterface{target, reversible, irreversible}
+	sdu = NewSystemDbUpdater(MockCtx.Db, updates, false)
+
+	err = sdu.RunAll()
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+		return
+	}
+
+	reverted = false
+	err = sdu.RollbackTo("Test Rollback Target", shardName)
+
+	if err == nil || reverted {
+		t.Errorf("\nERROR: Expected irreversible error. Reverted: %v\n", reverted)
+	}
+
+	_, err = sdu.GetRollbackUpdates("Unknown Update")
+
+	if err == nil {
+		t.Errorf("\nERROR: Expected unknown update error\n")
+	}
+}
+
+//
+func TestSystemDbUpdaterVerify(t *testing.T) {
+	InitMockCtx()
+
+	sdu := NewSystemDbUpdater(MockCtx.Db, GetJgowebDbUpdates(), true)
+
+	_, err := sdu.Verify()
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+	}
+
+	_, err = sdu.VerifySchema(appConfig.Integration.ShardName)
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+	}
+}
+
+//
+func TestSystemDbUpdaterTxModes(t *testing.T) {
+	InitMockCtx()
+
+	var steps []string
+	failOnce := true
+
+	own := CreateSystemDbUpdateNoContext("Test TxMode Own", "Test TxMode Own")
+	own.TxMode = DbUpdateTxOwn
+	own.ApplyUpdate = func(ctx ContextInterface) error {
+		steps = append(steps, "own")
+		return nil
+	}
+
+	// fails after the first step, then resumes from its progress
+	none := CreateSystemDbUpdateNoContext("Test TxMode None", "Test TxMode None")
+	none.TxMode = DbUpdateTxNone
+	none.ApplyWithProgress = func(ctx ContextInterface, sdu *SystemDbUpdate) error {
+
+		if sdu.GetProgress() == "" {
+			steps = append(steps, "step 1")
+
+			err := sdu.SaveProgress("1")
+
+			if err != nil {
+				return err
+			}
+		}
+
+		if failOnce {
+			failOnce = false
+			return errors.New("interrupted")
+		}
+
+		steps = append(steps, "step 2")
+
+		return nil
+	}
+
+	defer func() {
+		for _, update := range []*SystemDbUpdate{own, none} {
+			up := update.Clone()
+			up.SetContext(MockCtx)
+			up.(*SystemDbUpdate).SetIncomplete()
+		}
+	}()
+
+	updates := []SystemDbUpdateInterface{own, none}
+
+	// dry run refuses non-transactional updates
+	sdu := NewSystemDbUpdater(MockCtx.Db, updates, true)
+	err := sdu.RunByDbSession(MockCtx.DbSess, appConfig.Integration.ShardName)
+
+	if err == nil {
+		t.Errorf("\nERROR: Expected dry run to refuse a non-transactional update\n")
+	}
+
+	steps = nil
+	sdu = NewSystemDbUpdater(MockCtx.Db, updates, false)
+	err = sdu.RunByDbSession(MockCtx.DbSess, appConfig.Integration.ShardName)
+
+	if err == nil {
+		t.Errorf("\nERROR: Expected interrupted update\n")
+	}
+
+	update, err := FetchSystemDbUpdateByUpdateName(MockCtx, "Test TxMode None")
+
+	if err != nil || update == nil || update.GetStatus() != DbUpdateStatusStarted || update.GetProgress() != "1" {
+		t.Errorf("\nERROR: Expected started update with progress. Got: %v Error: %v\n", update, err)
+	}
+
+	err = sdu.RunByDbSession(MockCtx.DbSess, appConfig.Integration.ShardName)
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+	}
+
+	expected := "own,step 1,step 2"
+
+	if strings.Join(steps, ",") != expected {
+		t.Errorf("\nERROR: Unexpected steps\nExpected: %s\nResult: %s\n", expected, strings.Join(steps, ","))
+	}
+
+	update, err = FetchSystemDbUpdateByUpdateName(MockCtx, "Test TxMode None")
+
+	if err != nil || update == nil || update.GetStatus() != DbUpdateStatusApplied {
+		t.Errorf("\nERROR: Expected applied update. Got: %v Error: %v\n", update, err)
+	}
+}
+
+//
+func TestSystemDbUpdaterRollbackTxModes(t *testing.T) {
+	InitMockCtx()
+
+	var steps []string
+	shardName := appConfig.Integration.ShardName
+
+	inTx := func(ctx ContextInterface) string {
+		webCtx, ok := ctx.(*WebContext)
+
+		if ok && webCtx.Tx != nil {
+			return "tx"
+		}
+
+		return "no tx"
+	}
+
+	batch := CreateSystemDbUpdateNoContext("Test Rollback TxMode Batch", "Test Rollback TxMode Batch")
+	batch.ApplyUpdate = func(ctx ContextInterface) error { return nil }
+	batch.RevertUpdate = func(ctx ContextInterface) error {
+		steps = append(steps, "batch "+inTx(ctx))
+		return nil
+	}
+
+	none := CreateSystemDbUpdateNoContext("Test Rollback TxMode None", "Test Rollback TxMode None")
+	none.TxMode = DbUpdateTxNone
+	none.ApplyUpdate = func(ctx ContextInterface) error { return nil }
+	none.RevertUpdate = func(ctx ContextInterface) error {
+		steps = append(steps, "none "+inTx(ctx))
+		return nil
+	}
+
+	defer func() {
+		for _, update := range []*SystemDbUpdate{batch, none} {
+			up := update.Clone()
+			up.SetContext(MockCtx)
+			up.(*SystemDbUpdate).SetIncomplete()
+		}
+	}()
+
+	sdu := NewSystemDbUpdater(MockCtx.Db, []SystemDbUpdateInterface{batch, none}, false)
+	err := sdu.RunByDbSession(MockCtx.DbSess, shardName)
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+		return
+	}
+
+	err = sdu.WaitForPipeline(sdu.RollbackByDbSession(MockCtx.DbSess, shardName, []SystemDbUpdateInterface{none, batch}))
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+		return
+	}
+
+	expected := "none no tx,batch tx"
+
+	if strings.Join(steps, ",") != expected {
+		t.Errorf("\nERROR: Unexpected steps\nExpected: %s\nResult: %s\n", expected, strings.Join(steps, ","))
+	}
+}
+
+//
+func TestSystemDbUpdaterRunCluster(t *testing.T) {
+	InitMockCtx()
+
+	shardName := appConfig.Integration.ShardName
+
+	updates := []SystemDbUpdateInterface{GetTestSystemDbUpdate()}
+
+	sdu := NewSystemDbUpdater(MockCtx.Db, updates, true)
+	sdu.IncludeShards = []string{shardName}
+	sdu.Canary = true
+	sdu.CanaryCheck = func(ctx ContextInterface) error {
+		return errors.New("unhealthy")
+	}
+
+	summary, err := sdu.RunCluster()
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+		return
+	}
+
+	if len(summary) != 1 || summary[0].Status != DbUpdaterShardFailed || !strings.Contains(summary[0].Error, "unhealthy") {
+		t.Errorf("\nERROR: Expected failed canary. Got: %s\n", summary.String())
+	}
+
+	// another runner holds the lock
+	lock, err := MockCtx.TryAdvisoryLock(SystemDbUpdaterLock, 0)
+
+	if err != nil || lock == nil {
+		t.Errorf("\nERROR: Failed to take lock. Error: %v\n", err)
+		return
+	}
+
+	defer lock.Unlock()
+
+	sdu.Canary = false
+
+	summary, err = sdu.RunCluster()
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+		return
+	}
+
+	if summary.Err() == nil || !strings.Contains(summary[0].Error, "Another DB updater is running") {
+		t.Errorf("\nERROR: Expected lock error. Got: %s\n", summary.String())
+	}
+}
+
+//
+func TestSystemDbUpdaterStatusAndPlan(t *testing.T) {
+	InitMockCtx()
+
+	updates := []SystemDbUpdateInterface{GetTestSystemDbUpdate()}
+
+	sdu := NewSystemDbUpdater(MockCtx.Db, updates, true)
+	sdu.IncludeShards = []string{appConfig.Integration.ShardName}
+
+	report, err := sdu.Status()
+
+	if err != nil || len(report) == 0 {
+		t.Errorf("\nERROR: Expected status. Got: %v Error: %v\n", report, err)
+		return
+	}
+
+	plan, err := sdu.Plan()
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+		return
+	}
+
+	if (report[0].Status == DbUpdateStatusPending) != (len(plan) == 1) {
+		t.Errorf("\nERROR: Status and plan disagree\n%s\n%s\n", report.Table(), plan.Table())
+	}
+}
@@ -0,0 +1,248 @@
+package jgoweb
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gocraft/health"
+	"github.com/gocraft/web"
+	"github.com/jschneider98/jgoweb/util"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Returns the account id for a request or "" if the resolver doesn't apply
+type TenantResolverFunc func(ctx *WebContext, req *web.Request) (string, error)
+
+// Looks up the account id for an API token. Apps own their token storage.
+type TenantTokenLookupFunc func(ctx *WebContext, token string) (string, error)
+
+var tenantResolvers = map[string]TenantResolverFunc{
+	"domain":    TenantResolverDomain,
+	"subdomain": TenantResolverSubdomain,
+	"token":     TenantResolverToken,
+	"session":   TenantResolverSession,
+}
+
+var tenantTokenLookup TenantTokenLookupFunc
+var tenantMutex sync.RWMutex
+
+// Register (or replace) a named resolver. Use the name in config.Tenant.Resolvers to enable it.
+func SetTenantResolver(name string, fn TenantResolverFunc) {
+	tenantMutex.Lock()
+	defer tenantMutex.Unlock()
+
+	tenantResolvers[name] = fn
+}
+
+//
+func GetTenantResolver(name string) TenantResolverFunc {
+	tenantMutex.RLock()
+	defer tenantMutex.RUnlock()
+
+	return tenantResolvers[name]
+}
+
+// Required for the "token" resolver
+func SetTenantTokenLookup(fn TenantTokenLookupFunc) {
+	tenantMutex.Lock()
+	defer tenantMutex.Unlock()
+
+	tenantTokenLookup = fn
+}
+
+//
+func GetTenantTokenLookup() TenantTokenLookupFunc {
+	tenantMutex.RLock()
+	defer tenantMutex.RUnlock()
+
+	return tenantTokenLookup
+}
+
+// Request host without port, lower case
+func GetRequestHost(req *web.Request) string {
+	host := req.Host
+
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
+
+// i.e., "acme.example.com" w/ baseDomain "example.com" = "acme". "" if host isn't a direct subdomain.
+func GetSubdomain(host string, baseDomain string) string {
+	baseDomain = strings.ToLower(strings.Trim(baseDomain, "."))
+
+	if baseDomain == "" || !strings.HasSuffix(host, "."+baseDomain) {
+		return ""
+	}
+
+	sub := strings.TrimSuffix(host, "."+baseDomain)
+
+	if sub == "" || strings.Contains(sub, ".") {
+		return ""
+	}
+
+	return sub
+}
+
+// Custom domain. Host must match a shard_map domain. Hosts under the base domain are skipped.
+func TenantResolverDomain(ctx *WebContext, req *web.Request) (string, error) {
+	host := GetRequestHost(req)
+	baseDomain := strings.ToLower(strings.Trim(appConfig.Tenant.BaseDomain, "."))
+
+	if host == "" || net.ParseIP(host) != nil {
+		return "", nil
+	}
+
+	if baseDomain != "" && (host == baseDomain || strings.HasSuffix(host, "."+baseDomain)) {
+		return "", nil
+	}
+
+	route, err := FetchShardRouteByDomain(ctx, host)
+
+	if err != nil || route == nil {
+		return "", err
+	}
+
+	return route.AccountId, nil
+}
+
+// Subdomain of config.Tenant.BaseDomain. The subdomain must match a shard_map domain.
+func TenantResolverSubdomain(ctx *WebContext, req *web.Request) (string, error) {
+	sub := GetSubdomain(GetRequestHost(req), appConfig.Tenant.BaseDomain)
+
+	if sub == "" {
+		return "", nil
+	}
+
+	route, err := FetchShardRouteByDomain(ctx, sub)
+
+	if err != nil || route == nil {
+		return "", err
+	}
+
+	return route.AccountId, nil
+}
+
+// API token from config.Tenant.TokenHeader (or "Authorization: Bearer <token>")
+func TenantResolverToken(ctx *WebContext, req *web.Request) (string, error) {
+	lookup := GetTenantTokenLookup()
+
+	if lookup == nil {
+		return "", nil
+	}
+
+	token := req.Header.Get(appConfig.Tenant.TokenHeader)
+
+	if token == "" {
+		auth := req.Header.Get("Authorization")
+
+		if strings.HasPrefix(auth, "Bearer ") {
+			token = strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+		}
+	}
+
+	if token == "" {
+		return "", nil
+	}
+
+	return lookup(ctx, token)
+}
+
+// account_id in the session. Requires the LoadSession middleware.
+func TenantResolverSession(ctx *WebContext, req *web.Request) (string, error) {
+
+	if ctx.Session == nil {
+		return "", nil
+	}
+
+	accountId, err := ctx.SessionGetString("account_id")
+
+	if err != nil {
+		return "", err
+	}
+
+	return accountId, nil
+}
+
+// Run the configured resolvers in order. Binds the db session to the tenant's shard. nil = unknown tenant.
+func ResolveTenant(ctx *WebContext, req *web.Request) (*Account, error) {
+	InitConfig()
+
+	for _, name := range appConfig.Tenant.Resolvers {
+		resolver := GetTenantResolver(name)
+
+		if resolver == nil {
+			return nil, errors.New(fmt.Sprintf("Unknown tenant resolver: %s", name))
+		}
+
+		accountId, err := resolver(ctx, req)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if accountId == "" {
+			continue
+		}
+
+		shard, err := FetchShardByAccountId(ctx, accountId)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if shard == nil {
+			continue
+		}
+
+		account, err := FetchAccountById(ctx, accountId)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if account != nil {
+			return account, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// **** Middleware ****
+
+// Tenant middleware. Requires LoadDi (and LoadSession for the "session" resolver).
+// Resolver errors get a 500 and unknown tenants get a 404.
+func (ctx *WebContext) LoadTenant(rw web.ResponseWriter, req *web.Request, next web.NextMiddlewareFunc) {
+	account, err := ResolveTenant(ctx, req)
+
+	if err != nil {
+		http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+
+		if ctx.Job != nil {
+			ctx.Job.EventErr(util.WhereAmI(), err)
+			ctx.Job.Complete(health.Error)
+			ctx.UpdateWebMetrics("500")
+		}
+
+		return
+	}
+
+	if account == nil {
+		http.Error(rw, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+
+		if ctx.Job != nil {
+			ctx.JobSuccess("404")
+		}
+
+		return
+	}
+
+	ctx.SetAccount(account)
+
+	next(rw, req)
+}
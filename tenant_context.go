@@ -0,0 +1,439 @@
+package jgoweb
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/gocraft/dbr"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Add to raw SQL that references tenant tables to assert it's already filtered by account
+const TenantScopedSql = "/* tenant-scoped */"
+
+var tenantTables = map[string]string{
+	"public.accounts": "id",
+	"public.users":    "account_id",
+	"queue.jobs":      "account_id",
+}
+
+var tenantTablesMutex sync.RWMutex
+
+// Register a table (schema.table) that holds tenant data and the column holding the account id
+func RegisterTenantTable(table string, column string) {
+	tenantTablesMutex.Lock()
+	defer tenantTablesMutex.Unlock()
+
+	tenantTables[normalizeTenantTable(table)] = column
+}
+
+//
+func UnregisterTenantTable(table string) {
+	tenantTablesMutex.Lock()
+	defer tenantTablesMutex.Unlock()
+
+	delete(tenantTables, normalizeTenantTable(table))
+}
+
+// Returns the account column for a tenant table ("" if the table isn't a tenant table)
+func GetTenantTableColumn(table string) string {
+	tenantTablesMutex.RLock()
+	defer tenantTablesMutex.RUnlock()
+
+	return tenantTables[normalizeTenantTable(table)]
+}
+
+//
+func normalizeTenantTable(table string) string {
+	table = strings.ToLower(strings.Replace(table, `"`, "", -1))
+
+	if !strings.Contains(table, ".") {
+		table = "public." + table
+	}
+
+	return table
+}
+
+// Tenant tables referenced by a raw query
+func GetSqlTenantTables(query string) []string {
+	var tables []string
+
+	tenantTablesMutex.RLock()
+	defer tenantTablesMutex.RUnlock()
+
+	query = strings.ToLower(strings.Replace(query, `"`, "", -1))
+
+	for table := range tenantTables {
+		pattern := regexp.QuoteMeta(table)
+
+		// public is the default schema, so it's optional
+		if strings.HasPrefix(table, "public.") {
+			pattern = `(public\.)?` + regexp.QuoteMeta(strings.TrimPrefix(table, "public."))
+		}
+
+		if regexp.MustCompile(`(^|[^\w.])` + pattern + `($|[^\w.])`).MatchString(query) {
+			tables = append(tables, table)
+		}
+	}
+
+	return tables
+}
+
+// ******
+
+// Opt-in context that limits Select (FROM and joined tables)/Update/DeleteFrom on tenant tables to a single account.
+// Raw SQL that references tenant tables is rejected unless marked with TenantScopedSql (plain INSERTs are allowed, upserts are not).
+// Transactions wait while the account is being moved to another shard (see AccountWriteLockKey), so write in a transaction.
+type TenantContext struct {
+	ContextInterface
+	AccountId   string
+	RlsVariable string
+}
+
+// rlsVariable is optional (i.e., "jgoweb.account_id"). If set, it's set local to every transaction for RLS policies.
+func NewTenantContext(ctx ContextInterface, accountId string, rlsVariable string) (*TenantContext, error) {
+
+	if accountId == "" {
+		return nil, errors.New("Tenant context requires an account id.")
+	}
+
+	return &TenantContext{ContextInterface: ctx, AccountId: accountId, RlsVariable: rlsVariable}, nil
+}
+
+// Tenant context for the web context's resolved account (see LoadTenant). Uses config.Tenant.RlsVariable.
+func (ctx *WebContext) GetTenantContext() (*TenantContext, error) {
+	InitConfig()
+
+	if ctx.Account == nil {
+		return nil, errors.New("Tenant context requires a resolved account.")
+	}
+
+	return NewTenantContext(ctx, ctx.Account.GetId(), appConfig.Tenant.RlsVariable)
+}
+
+// Escape hatch for admin/cluster code
+func (tc *TenantContext) Unscoped() ContextInterface {
+	return tc.ContextInterface
+}
+
+//
+func (tc *TenantContext) Begin() (*dbr.Tx, error) {
+	tx, err := tc.ContextInterface.Begin()
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = tc.initTx(tx)
+
+	if err != nil {
+		tc.ContextInterface.Rollback()
+		return nil, err
+	}
+
+	return tx, nil
+}
+
+//
+func (tc *TenantContext) OptionalBegin() (*dbr.Tx, error) {
+	tx, err := tc.ContextInterface.OptionalBegin()
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = tc.initTx(tx)
+
+	if err != nil {
+		tc.ContextInterface.OptionalRollback(tx)
+		return nil, err
+	}
+
+	return tx, nil
+}
+
+//
+func (tc *TenantContext) WithTransaction(fn func(tx *dbr.Tx) error, opts *TxOptions) error {
+	return tc.ContextInterface.WithTransaction(func(tx *dbr.Tx) error {
+		err := tc.initTx(tx)
+
+		if err != nil {
+			return err
+		}
+
+		return fn(tx)
+	}, opts)
+}
+
+// Every transaction takes the account's write lock and sets the RLS variable
+func (tc *TenantContext) initTx(tx *dbr.Tx) error {
+	err := tc.lockAccount(tx)
+
+	if err != nil {
+		return err
+	}
+
+	return tc.setRlsVariable(tx)
+}
+
+// Shared account write lock. Waits while an account move is cutting over, then fails if the account
+// was moved off this shard (its rows are gone, so writing would leave orphans behind).
+func (tc *TenantContext) lockAccount(tx *dbr.Tx) error {
+	var count int64
+
+	_, err := tx.Exec("SELECT pg_advisory_xact_lock_shared($1)", AdvisoryLockId(AccountWriteLockKey(tc.AccountId)))
+
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRow("SELECT count(*) FROM public.accounts WHERE id = $1", tc.AccountId).Scan(&count)
+
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return errors.New(fmt.Sprintf("Account %s is not on this shard (it may have been moved). Retry.", tc.AccountId))
+	}
+
+	return nil
+}
+
+// Session variables don't survive connection pooling, so the variable is only set within transactions
+func (tc *TenantContext) setRlsVariable(tx *dbr.Tx) error {
+	var result []string
+
+	if tc.RlsVariable == "" {
+		return nil
+	}
+
+	_, err := tx.SelectBySql("SELECT set_config(?, ?, true)", tc.RlsVariable, tc.AccountId).Load(&result)
+
+	return err
+}
+
+//
+func (tc *TenantContext) Select(column ...string) *dbr.SelectBuilder {
+	stmt := tc.ContextInterface.Select(column...)
+
+	// From() hasn't been called yet, so the table is checked when the query is built
+	return stmt.Where(&tenantCondition{stmt: stmt, accountId: tc.AccountId})
+}
+
+//
+func (tc *TenantContext) Update(table string) *dbr.UpdateStmt {
+	stmt := tc.ContextInterface.Update(table)
+	column := GetTenantTableColumn(table)
+
+	if column != "" {
+		stmt.Where(dbr.Eq(column, tc.AccountId))
+	}
+
+	return stmt
+}
+
+//
+func (tc *TenantContext) DeleteFrom(table string) *dbr.DeleteStmt {
+	stmt := tc.ContextInterface.DeleteFrom(table)
+	column := GetTenantTableColumn(table)
+
+	if column != "" {
+		stmt.Where(dbr.Eq(column, tc.AccountId))
+	}
+
+	return stmt
+}
+
+//
+func (tc *TenantContext) SelectBySql(query string, value ...interface{}) *dbr.SelectBuilder {
+	err := tc.CheckSql(query)
+
+	if err != nil {
+		return tc.ContextInterface.SelectBySql("?", tenantGuardError{err})
+	}
+
+	return tc.ContextInterface.SelectBySql(query, value...)
+}
+
+//
+func (tc *TenantContext) UpdateBySql(query string, value ...interface{}) *dbr.UpdateStmt {
+	err := tc.CheckSql(query)
+
+	if err != nil {
+		return tc.ContextInterface.UpdateBySql("?", tenantGuardError{err})
+	}
+
+	return tc.ContextInterface.UpdateBySql(query, value...)
+}
+
+//
+func (tc *TenantContext) InsertBySql(query string, value ...interface{}) *dbr.InsertStmt {
+	err := tc.CheckSql(query)
+
+	if err != nil {
+		return tc.ContextInterface.InsertBySql("?", tenantGuardError{err})
+	}
+
+	return tc.ContextInterface.InsertBySql(query, value...)
+}
+
+//
+func (tc *TenantContext) Prepare(query string) (*sql.Stmt, error) {
+	err := tc.CheckSql(query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return tc.ContextInterface.Prepare(query)
+}
+
+// ON CONFLICT ... DO UPDATE (DO NOTHING can't change existing rows)
+var tenantUpsertRegexp = regexp.MustCompile(`(?s)\bON\s+CONFLICT\b.*\bDO\s+UPDATE\b`)
+
+// Error if raw SQL references tenant tables and isn't marked as scoped
+func (tc *TenantContext) CheckSql(query string) error {
+
+	if strings.Contains(query, TenantScopedSql) {
+		return nil
+	}
+
+	tables := GetSqlTenantTables(query)
+
+	if len(tables) == 0 {
+		return nil
+	}
+
+	// Plain inserts can't read or change another tenant's rows. Upserts can (through a key collision).
+	normalized := strings.ToUpper(strings.TrimSpace(query))
+
+	if strings.HasPrefix(normalized, "INSERT") && !strings.Contains(normalized, "SELECT") && !tenantUpsertRegexp.MatchString(normalized) {
+		return nil
+	}
+
+	return errors.New(fmt.Sprintf("Unscoped raw SQL references tenant table(s): %s. Filter by account and add %s.", strings.Join(tables, ", "), TenantScopedSql))
+}
+
+// ******
+
+// Where condition that filters the select's FROM and joined tables (if they're tenant tables).
+// Fails closed: tables that can't be checked (subqueries, etc.) are an error.
+type tenantCondition struct {
+	stmt      *dbr.SelectStmt
+	accountId string
+}
+
+//
+func (tc *tenantCondition) Build(d dbr.Dialect, buf dbr.Buffer) error {
+	var conds []string
+
+	// no FROM (e.g., SELECT now())
+	if tc.stmt.Table != nil {
+		table, ok := tc.stmt.Table.(string)
+
+		if !ok {
+			return errors.New(fmt.Sprintf("Tenant context can't check FROM %T. Use Unscoped() or raw SQL marked with %s.", tc.stmt.Table, TenantScopedSql))
+		}
+
+		column, err := getTenantTableColumnRef(d, table)
+
+		if err != nil {
+			return err
+		}
+
+		if column != "" {
+			conds = append(conds, column+" = ?")
+		}
+	}
+
+	for _, join := range tc.stmt.JoinTable {
+		joinBuf := dbr.NewBuffer()
+		err := join.Build(d, joinBuf)
+
+		if err != nil {
+			return err
+		}
+
+		matches := tenantJoinRegexp.FindStringSubmatch(joinBuf.String())
+
+		if matches == nil {
+			return errors.New(fmt.Sprintf("Tenant context can't check join: %s. Use Unscoped() or raw SQL marked with %s.", joinBuf.String(), TenantScopedSql))
+		}
+
+		column, err := getTenantTableColumnRef(d, strings.Replace(matches[2], `"`, "", -1))
+
+		if err != nil {
+			return err
+		}
+
+		if column == "" {
+			continue
+		}
+
+		// unmatched left joined rows are NULL, not another account's
+		if matches[1] == "LEFT " {
+			conds = append(conds, "("+column+" = ? OR "+column+" IS NULL)")
+		} else {
+			conds = append(conds, column+" = ?")
+		}
+	}
+
+	if len(conds) == 0 {
+		buf.WriteString("TRUE")
+		return nil
+	}
+
+	buf.WriteString(strings.Join(conds, " AND "))
+
+	for range conds {
+		err := buf.WriteValue(tc.accountId)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Join type and table (and alias) from a built join. Non-string join tables are written as a placeholder, so they don't match.
+var tenantJoinRegexp = regexp.MustCompile(`^\s*((?:LEFT|RIGHT|FULL) )?JOIN ([^?]+?) ON `)
+
+// Qualified account column ("alias.column") for a tenant table spec ("schema.table [[AS] alias]"), "" for other tables
+func getTenantTableColumnRef(d dbr.Dialect, table string) (string, error) {
+	fields := strings.Fields(table)
+
+	if len(fields) == 0 {
+		return "", errors.New("Tenant context can't check an empty table name.")
+	}
+
+	column := GetTenantTableColumn(fields[0])
+
+	if column == "" {
+		return "", nil
+	}
+
+	// qualify with the alias (or table) in case of joins
+	qualifier := fields[0]
+
+	if len(fields) >= 3 && strings.ToLower(fields[1]) == "as" {
+		qualifier = fields[2]
+	} else if len(fields) == 2 {
+		qualifier = fields[1]
+	}
+
+	return qualifier + "." + d.QuoteIdent(column), nil
+}
+
+// Fails the query when it's built
+type tenantGuardError struct {
+	err error
+}
+
+//
+func (tge tenantGuardError) Build(d dbr.Dialect, buf dbr.Buffer) error {
+	return tge.err
+}
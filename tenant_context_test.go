@@ -0,0 +1,260 @@
+// +build unit
+
+package jgoweb
+
+import (
+	"github.com/gocraft/dbr"
+	"github.com/gocraft/dbr/dialect"
+	"strings"
+	"testing"
+)
+
+// Builds SQL only. Never connects.
+func getTestTenantContext(t *testing.T) *TenantContext {
+	conn, err := dbr.Open("postgres", "postgres://localhost/jgoweb_unit?sslmode=disable", nil)
+
+	if err != nil {
+		t.Fatalf("\nERROR: %v\n", err)
+	}
+
+	ctx := &WebContext{DbSess: conn.NewSession(nil)}
+
+	tc, err := NewTenantContext(ctx, "42", "")
+
+	if err != nil {
+		t.Fatalf("\nERROR: %v\n", err)
+	}
+
+	return tc
+}
+
+//
+func buildTestTenantSql(builder dbr.Builder) (string, error) {
+	buf := dbr.NewBuffer()
+	err := builder.Build(dialect.PostgreSQL, buf)
+
+	if err != nil {
+		return "", err
+	}
+
+	return dbr.InterpolateForDialect(buf.String(), buf.Value(), dialect.PostgreSQL)
+}
+
+//
+func TestNewTenantContext(t *testing.T) {
+	_, err := NewTenantContext(&WebContext{}, "", "")
+
+	if err == nil {
+		t.Errorf("\nERROR: Tenant context without an account id should fail.\n")
+	}
+}
+
+//
+func TestTenantContextSelect(t *testing.T) {
+	tc := getTestTenantContext(t)
+
+	tests := map[string]string{
+		"public.users":         `public.users."account_id" = '42'`,
+		"users":                `users."account_id" = '42'`,
+		"public.users u":       `u."account_id" = '42'`,
+		"public.accounts AS a": `a."id" = '42'`,
+		"system.shards":        "WHERE (TRUE) AND",
+	}
+
+	for table, expected := range tests {
+		query, err := buildTestTenantSql(tc.Select("*").From(table).Where("id = ?", 1))
+
+		if err != nil {
+			t.Errorf("\nERROR: %v\n", err)
+			continue
+		}
+
+		if !strings.Contains(query, expected) {
+			t.Errorf("\nERROR: Expected %s in query: %s\n", expected, query)
+		}
+	}
+}
+
+//
+func TestTenantContextSelectJoin(t *testing.T) {
+	tc := getTestTenantContext(t)
+
+	tests := []struct {
+		stmt     *dbr.SelectStmt
+		expected string
+	}{
+		{
+			tc.Select("*").From("system.shards s").Join("public.users u", "u.shard_id = s.id"),
+			`WHERE (u."account_id" = '42')`,
+		},
+		{
+			tc.Select("*").From("public.accounts a").Join("public.users u", "u.account_id = a.id"),
+			`WHERE (a."id" = '42' AND u."account_id" = '42')`,
+		},
+		{
+			tc.Select("*").From("public.accounts a").LeftJoin("queue.jobs AS j", "j.account_id = a.id"),
+			`WHERE (a."id" = '42' AND (j."account_id" = '42' OR j."account_id" IS NULL))`,
+		},
+		{
+			tc.Select("*").From("system.shards s").Join("system.shard_map sm", "sm.shard_id = s.id"),
+			"WHERE (TRUE)",
+		},
+		{
+			tc.Select("now()"),
+			"WHERE (TRUE)",
+		},
+	}
+
+	for _, test := range tests {
+		query, err := buildTestTenantSql(test.stmt)
+
+		if err != nil {
+			t.Errorf("\nERROR: %v\n", err)
+			continue
+		}
+
+		if !strings.Contains(query, test.expected) {
+			t.Errorf("\nERROR: Expected %s in query: %s\n", test.expected, query)
+		}
+	}
+}
+
+// Tables that can't be checked are rejected instead of left unfiltered
+func TestTenantContextSelectFailsClosed(t *testing.T) {
+	tc := getTestTenantContext(t)
+	sub := dbr.Select("*").From("public.users").As("u")
+
+	stmts := []*dbr.SelectStmt{
+		tc.Select("*").From(sub),
+		tc.Select("*").From("system.shards s").Join(sub, "u.shard_id = s.id"),
+		tc.Select("*").From(""),
+	}
+
+	for _, stmt := range stmts {
+		query, err := buildTestTenantSql(stmt)
+
+		if err == nil {
+			t.Errorf("\nERROR: Expected an error for: %s\n", query)
+		}
+	}
+}
+
+//
+func TestTenantContextUpdateDelete(t *testing.T) {
+	tc := getTestTenantContext(t)
+
+	query, err := buildTestTenantSql(tc.Update("public.users").Set("first_name", "test").Where("id = ?", 1))
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+	}
+
+	if !strings.Contains(query, `"account_id" = '42'`) {
+		t.Errorf("\nERROR: Update should be scoped: %s\n", query)
+	}
+
+	query, err = buildTestTenantSql(tc.DeleteFrom("queue.jobs").Where("id = ?", 1))
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+	}
+
+	if !strings.Contains(query, `"account_id" = '42'`) {
+		t.Errorf("\nERROR: Delete should be scoped: %s\n", query)
+	}
+
+	query, err = buildTestTenantSql(tc.DeleteFrom("system.shards").Where("id = ?", 1))
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+	}
+
+	if strings.Contains(query, "account_id") {
+		t.Errorf("\nERROR: Non-tenant table should not be scoped: %s\n", query)
+	}
+}
+
+//
+func TestTenantContextRawSql(t *testing.T) {
+	tc := getTestTenantContext(t)
+
+	_, err := buildTestTenantSql(tc.SelectBySql("SELECT * FROM users WHERE id = ?", 1))
+
+	if err == nil {
+		t.Errorf("\nERROR: Unscoped raw SQL should be rejected.\n")
+	}
+
+	_, err = buildTestTenantSql(tc.SelectBySql("SELECT * FROM users WHERE account_id = ? "+TenantScopedSql, 42))
+
+	if err != nil {
+		t.Errorf("\nERROR: Marked raw SQL should be allowed. %v\n", err)
+	}
+
+	_, err = buildTestTenantSql(tc.SelectBySql("SELECT * FROM system.shards WHERE id = ?", 1))
+
+	if err != nil {
+		t.Errorf("\nERROR: Non-tenant raw SQL should be allowed. %v\n", err)
+	}
+
+	_, err = buildTestTenantSql(tc.UpdateBySql("UPDATE queue.jobs SET status = 'x'"))
+
+	if err == nil {
+		t.Errorf("\nERROR: Unscoped raw update should be rejected.\n")
+	}
+
+	err = tc.CheckSql("INSERT INTO public.users (account_id) VALUES ($1) RETURNING id")
+
+	if err != nil {
+		t.Errorf("\nERROR: Plain insert should be allowed. %v\n", err)
+	}
+
+	err = tc.CheckSql("INSERT INTO public.users (id, account_id) VALUES ($1, $2) ON CONFLICT (id) DO UPDATE SET account_id = EXCLUDED.account_id")
+
+	if err == nil {
+		t.Errorf("\nERROR: Unscoped upsert should be rejected.\n")
+	}
+
+	err = tc.CheckSql("INSERT INTO public.users (id, account_id) VALUES ($1, $2)\nON CONFLICT (id)\nDO UPDATE SET first_name = 'x' WHERE users.account_id = $2 " + TenantScopedSql)
+
+	if err != nil {
+		t.Errorf("\nERROR: Marked upsert should be allowed. %v\n", err)
+	}
+
+	err = tc.CheckSql("INSERT INTO public.users (id, account_id) VALUES ($1, $2) ON CONFLICT DO NOTHING")
+
+	if err != nil {
+		t.Errorf("\nERROR: Insert that ignores conflicts should be allowed. %v\n", err)
+	}
+
+	err = tc.CheckSql("INSERT INTO public.users SELECT * FROM public.users")
+
+	if err == nil {
+		t.Errorf("\nERROR: Insert/select should be rejected.\n")
+	}
+
+	_, err = tc.Prepare("DELETE FROM public.users")
+
+	if err == nil {
+		t.Errorf("\nERROR: Unscoped prepare should be rejected.\n")
+	}
+}
+
+//
+func TestGetSqlTenantTables(t *testing.T) {
+	tests := map[string]int{
+		"SELECT * FROM users":                               1,
+		`SELECT * FROM "public"."users"`:                    1,
+		"SELECT * FROM public.users_archive":                0,
+		"SELECT * FROM other.users":                         0,
+		"SELECT * FROM queue.jobs j JOIN public.accounts a": 2,
+		"SELECT 1": 0,
+	}
+
+	for query, expected := range tests {
+		tables := GetSqlTenantTables(query)
+
+		if len(tables) != expected {
+			t.Errorf("\nERROR: %s. Expected %d tenant tables. Got: %v\n", query, expected, tables)
+		}
+	}
+}
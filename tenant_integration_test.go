@@ -0,0 +1,144 @@
+// +build integration
+
+package jgoweb
+
+import (
+	"errors"
+	"github.com/gocraft/web"
+	"testing"
+)
+
+//
+func setTestTenantTokenLookup() {
+	SetTenantTokenLookup(func(ctx *WebContext, token string) (string, error) {
+
+		if token == "test-token" {
+			return MockAccount.GetId(), nil
+		}
+
+		if token == "error-token" {
+			return "", errors.New("Token lookup failed")
+		}
+
+		return "", nil
+	})
+}
+
+//
+func TestResolveTenant(t *testing.T) {
+	InitMockAccount()
+	setTestTenantTokenLookup()
+	defer SetTenantTokenLookup(nil)
+
+	_, httpReq := NewTestRequest("GET", "/", nil)
+	httpReq.Header.Set(appConfig.Tenant.TokenHeader, "test-token")
+
+	ctx := &WebContext{Db: GetDbCollection()}
+	ctx.InitDbSession()
+
+	account, err := ResolveTenant(ctx, &web.Request{Request: httpReq})
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+		return
+	}
+
+	if account == nil || account.GetId() != MockAccount.GetId() {
+		t.Errorf("\nERROR: Failed to resolve tenant. Expected: %v Got: %v\n", MockAccount.GetId(), account)
+	}
+
+	// unknown token
+	httpReq.Header.Set(appConfig.Tenant.TokenHeader, "bad-token")
+	account, err = ResolveTenant(ctx, &web.Request{Request: httpReq})
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+		return
+	}
+
+	if account != nil {
+		t.Errorf("\nERROR: Should not have resolved tenant: %v\n", account.GetId())
+	}
+}
+
+//
+func TestLoadTenant(t *testing.T) {
+	InitMockAccount()
+	setTestTenantTokenLookup()
+	defer SetTenantTokenLookup(nil)
+
+	router := web.New(WebContext{}).
+		Middleware(web.ShowErrorsMiddleware).
+		Middleware((*WebContext).LoadDi).
+		Middleware((*WebContext).LoadTenant).
+		Get("/index", (*WebContext).testRouteJsonOk)
+
+	rw, req := NewTestRequest("GET", "http://unknown.invalid/index", nil)
+	router.ServeHTTP(rw, req)
+	AssertResponse(t, rw, 404)
+
+	rw, req = NewTestRequest("GET", "http://unknown.invalid/index", nil)
+	req.Header.Set("Authorization", "Bearer test-token")
+	router.ServeHTTP(rw, req)
+	AssertResponse(t, rw, 200)
+
+	// resolver errors aren't unknown tenants
+	rw, req = NewTestRequest("GET", "http://unknown.invalid/index", nil)
+	req.Header.Set("Authorization", "Bearer error-token")
+	router.ServeHTTP(rw, req)
+	AssertResponse(t, rw, 500)
+}
+
+//
+func TestTenantContextRlsVariable(t *testing.T) {
+	var result []string
+
+	InitMockAccount()
+	InitMockShard()
+
+	// the account has to be on the context's shard
+	ctx, err := MockShard.NewWebContext()
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+		return
+	}
+
+	tc, err := NewTenantContext(ctx, MockAccount.GetId(), "jgoweb.account_id")
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+		return
+	}
+
+	_, err = tc.Begin()
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+		return
+	}
+
+	defer tc.Rollback()
+
+	_, err = tc.SelectBySql("SELECT current_setting('jgoweb.account_id', true)").Load(&result)
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+		return
+	}
+
+	if len(result) != 1 || result[0] != MockAccount.GetId() {
+		t.Errorf("\nERROR: RLS variable mismatch. Expected: %v Got: %v\n", MockAccount.GetId(), result)
+	}
+
+	users, err := FetchAllUserByAccountId(tc, MockAccount.GetId())
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+		return
+	}
+
+	if len(users) == 0 {
+		t.Errorf("\nERROR: Tenant context should return users for its own account.\n")
+	}
+}
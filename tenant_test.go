@@ -0,0 +1,55 @@
+// +build unit
+
+package jgoweb
+
+import (
+	"github.com/gocraft/web"
+	"net/http"
+	"testing"
+)
+
+//
+func TestGetRequestHost(t *testing.T) {
+	tests := map[string]string{
+		"example.com":       "example.com",
+		"Example.COM:8080":  "example.com",
+		"acme.example.com.": "acme.example.com",
+		"127.0.0.1:80":      "127.0.0.1",
+	}
+
+	for host, expected := range tests {
+		httpReq, _ := http.NewRequest("GET", "http://example.com", nil)
+		httpReq.Host = host
+
+		req := &web.Request{Request: httpReq}
+
+		if GetRequestHost(req) != expected {
+			t.Errorf("\nERROR: Host mismatch for %s. Expected: %s Got: %s\n", host, expected, GetRequestHost(req))
+		}
+	}
+}
+
+//
+func TestGetSubdomain(t *testing.T) {
+	tests := []struct {
+		host       string
+		baseDomain string
+		expected   string
+	}{
+		{"acme.example.com", "example.com", "acme"},
+		{"acme.example.com", ".example.com.", "acme"},
+		{"example.com", "example.com", ""},
+		{"a.b.example.com", "example.com", ""},
+		{"acme.other.com", "example.com", ""},
+		{"acmeexample.com", "example.com", ""},
+		{"acme.example.com", "", ""},
+	}
+
+	for _, test := range tests {
+		sub := GetSubdomain(test.host, test.baseDomain)
+
+		if sub != test.expected {
+			t.Errorf("\nERROR: Subdomain mismatch for %s (%s). Expected: %s Got: %s\n", test.host, test.baseDomain, test.expected, sub)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package jgoweb
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/gocraft/dbr"
+	"github.com/lib/pq"
+	"math/rand"
+	"time"
+)
+
+// Serialization failure and deadlock
+var RetryableSqlStates = map[string]bool{
+	"40001": true,
+	"40P01": true,
+}
+
+// Options for WithTransaction. nil = DefaultTxOptions.
+// Isolation and ReadOnly only apply to the outermost transaction.
+type TxOptions struct {
+	Isolation  sql.IsolationLevel
+	ReadOnly   bool
+	MaxRetries int
+	Backoff    time.Duration
+}
+
+var DefaultTxOptions = TxOptions{MaxRetries: 3, Backoff: 20 * time.Millisecond}
+
+// Does the error come from a retryable Postgres state?
+func IsRetryableTxError(err error) bool {
+
+	switch pqErr := err.(type) {
+	case *pq.Error:
+		return RetryableSqlStates[string(pqErr.Code)]
+	case pq.Error:
+		return RetryableSqlStates[string(pqErr.Code)]
+	}
+
+	return false
+}
+
+// Exponential backoff w/ jitter
+func getTxBackoff(base time.Duration, attempt int) time.Duration {
+
+	if base <= 0 {
+		return 0
+	}
+
+	backoff := base << uint(attempt)
+
+	return backoff + time.Duration(rand.Int63n(int64(base)))
+}
+
+// Run fn in a transaction. The context's queries use the transaction while fn runs.
+// Retryable errors (see RetryableSqlStates) re-run fn, so fn must be safe to repeat.
+// If a transaction is already open, fn runs in a savepoint and errors are left to the outer transaction to retry.
+func (ctx *WebContext) WithTransaction(fn func(tx *dbr.Tx) error, opts *TxOptions) error {
+
+	if opts == nil {
+		opts = &DefaultTxOptions
+	}
+
+	if ctx.Tx != nil {
+		return ctx.withSavepoint(fn)
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := ctx.runTransaction(fn, opts)
+
+		if err == nil || !IsRetryableTxError(err) || attempt >= opts.MaxRetries {
+			return err
+		}
+
+		time.Sleep(getTxBackoff(opts.Backoff, attempt))
+	}
+}
+
+// Insert or update a record in a transaction. Whether to insert is decided up front: a retried attempt has to insert
+// again (the rolled back insert set Id). idValid is the record's Id.Valid.
+func SaveInTransaction(ctx ContextInterface, idValid *bool, insert func() error, update func() error) error {
+	isInsert := !*idValid
+
+	err := ctx.WithTransaction(func(tx *dbr.Tx) error {
+
+		if isInsert {
+			*idValid = false
+			return insert()
+		}
+
+		return update()
+	}, nil)
+
+	if err != nil && isInsert {
+		*idValid = false
+	}
+
+	return err
+}
+
+//
+func (ctx *WebContext) runTransaction(fn func(tx *dbr.Tx) error, opts *TxOptions) error {
+	var err error
+
+	if ctx.DbSess == nil {
+		return errors.New("Cannot begin transaction. No DB session set in context.")
+	}
+
+	ctx.PrimaryOnly = true
+
+	ctx.Tx, err = ctx.DbSess.BeginTx(ctx.GetGoContext(), &sql.TxOptions{Isolation: opts.Isolation, ReadOnly: opts.ReadOnly})
+
+	if err != nil {
+		ctx.Tx = nil
+		return err
+	}
+
+	err = fn(ctx.Tx)
+
+	if err != nil {
+		ctx.Rollback()
+		return err
+	}
+
+	return ctx.Commit()
+}
+
+//
+func (ctx *WebContext) withSavepoint(fn func(tx *dbr.Tx) error) error {
+	tx := ctx.Tx
+	ctx.txDepth++
+	defer func() { ctx.txDepth-- }()
+
+	savepoint := fmt.Sprintf("jgoweb_sp_%d", ctx.txDepth)
+
+	_, err := tx.Exec("SAVEPOINT " + savepoint)
+
+	if err != nil {
+		return err
+	}
+
+	err = fn(tx)
+
+	if err != nil {
+		tx.Exec("ROLLBACK TO SAVEPOINT " + savepoint)
+		return err
+	}
+
+	_, err = tx.Exec("RELEASE SAVEPOINT " + savepoint)
+
+	return err
+}
@@ -0,0 +1,96 @@
+// +build integration
+
+package jgoweb
+
+import (
+	"errors"
+	"github.com/gocraft/dbr"
+	"github.com/lib/pq"
+	"testing"
+	"time"
+)
+
+//
+func TestWithTransactionRetry(t *testing.T) {
+	InitMockCtx()
+
+	attempts := 0
+	opts := &TxOptions{MaxRetries: 2, Backoff: time.Millisecond}
+
+	err := MockCtx.WithTransaction(func(tx *dbr.Tx) error {
+		attempts++
+
+		if attempts < 3 {
+			return &pq.Error{Code: "40001"}
+		}
+
+		return nil
+	}, opts)
+
+	if err != nil || attempts != 3 {
+		t.Errorf("\nERROR: Expected success after 3 attempts. Attempts: %d Error: %v\n", attempts, err)
+	}
+
+	attempts = 0
+
+	err = MockCtx.WithTransaction(func(tx *dbr.Tx) error {
+		attempts++
+		return errors.New("not retryable")
+	}, opts)
+
+	if err == nil || attempts != 1 {
+		t.Errorf("\nERROR: Expected 1 attempt and an error. Attempts: %d Error: %v\n", attempts, err)
+	}
+
+	if MockCtx.Tx != nil {
+		t.Errorf("\nERROR: Transaction was not cleared\n")
+	}
+}
+
+//
+func TestWithTransactionSavepoint(t *testing.T) {
+	var count []int
+	InitMockCtx()
+
+	err := MockCtx.WithTransaction(func(tx *dbr.Tx) error {
+		_, err := tx.Exec("CREATE TEMP TABLE tx_test (id int) ON COMMIT DROP")
+
+		if err != nil {
+			return err
+		}
+
+		_, err = MockCtx.InsertInto("tx_test").Pair("id", 1).Exec()
+
+		if err != nil {
+			return err
+		}
+
+		// failed nested tx only rolls back its own work
+		nestedErr := MockCtx.WithTransaction(func(tx *dbr.Tx) error {
+			_, err := MockCtx.InsertInto("tx_test").Pair("id", 2).Exec()
+
+			if err != nil {
+				return err
+			}
+
+			return errors.New("nested failure")
+		}, nil)
+
+		if nestedErr == nil {
+			return errors.New("Expected nested error")
+		}
+
+		_, err = MockCtx.SelectBySql("SELECT count(*) FROM tx_test").Load(&count)
+
+		return err
+	}, nil)
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+		return
+	}
+
+	if len(count) != 1 || count[0] != 1 {
+		t.Errorf("\nERROR: Expected 1 row after savepoint rollback. Got: %v\n", count)
+	}
+}
@@ -0,0 +1,131 @@
+// +build unit
+
+package jgoweb
+
+import (
+	"errors"
+	"github.com/gocraft/dbr"
+	"github.com/lib/pq"
+	"testing"
+	"time"
+)
+
+//
+func TestIsRetryableTxError(t *testing.T) {
+	tests := map[error]bool{
+		&pq.Error{Code: "40001"}: true,
+		&pq.Error{Code: "40P01"}: true,
+		&pq.Error{Code: "23505"}: false,
+		errors.New("40001"):      false,
+	}
+
+	for err, expected := range tests {
+		result := IsRetryableTxError(err)
+
+		if result != expected {
+			t.Errorf("\nERROR: IsRetryableTxError(%v)\nExpected: %v\nResult: %v\n", err, expected, result)
+		}
+	}
+
+	if IsRetryableTxError(nil) {
+		t.Errorf("\nERROR: nil error should not be retryable\n")
+	}
+}
+
+//
+func TestGetTxBackoff(t *testing.T) {
+	base := 10 * time.Millisecond
+
+	for attempt := 0; attempt < 4; attempt++ {
+		backoff := getTxBackoff(base, attempt)
+		min := base << uint(attempt)
+
+		if backoff < min || backoff >= min+base {
+			t.Errorf("\nERROR: Backoff out of range for attempt %d: %v\n", attempt, backoff)
+		}
+	}
+
+	if getTxBackoff(0, 3) != 0 {
+		t.Errorf("\nERROR: Expected no backoff\n")
+	}
+}
+
+//
+func TestWithTransactionNoSession(t *testing.T) {
+	ctx := &WebContext{}
+	err := ctx.WithTransaction(nil, nil)
+
+	if err == nil {
+		t.Errorf("\nERROR: Expected an error without a DB session\n")
+	}
+
+	if ctx.Tx != nil {
+		t.Errorf("\nERROR: Transaction should not be set\n")
+	}
+}
+
+// Runs fn once per attempt (i.e., a retry after a rolled back attempt) without a DB
+type testRetryCtx struct {
+	ContextInterface
+	attempts int
+}
+
+//
+func (ctx *testRetryCtx) WithTransaction(fn func(tx *dbr.Tx) error, opts *TxOptions) error {
+	var err error
+
+	for i := 0; i < ctx.attempts; i++ {
+		err = fn(nil)
+	}
+
+	return err
+}
+
+//
+func TestSaveInTransaction(t *testing.T) {
+	var inserts int
+	var updates int
+	var idValid bool
+
+	insert := func() error {
+		inserts++
+
+		if idValid {
+			return errors.New("insert with Id set")
+		}
+
+		idValid = true
+
+		return nil
+	}
+
+	update := func() error {
+		updates++
+		return nil
+	}
+
+	// the retry inserts again
+	err := SaveInTransaction(&testRetryCtx{attempts: 2}, &idValid, insert, update)
+
+	if err != nil || inserts != 2 || updates != 0 || !idValid {
+		t.Errorf("\nERROR: Expected a retried insert. Inserts: %v Updates: %v Error: %v\n", inserts, updates, err)
+	}
+
+	err = SaveInTransaction(&testRetryCtx{attempts: 1}, &idValid, insert, update)
+
+	if err != nil || inserts != 2 || updates != 1 {
+		t.Errorf("\nERROR: Expected an update. Inserts: %v Updates: %v Error: %v\n", inserts, updates, err)
+	}
+
+	// a failed insert leaves Id unset
+	idValid = false
+
+	err = SaveInTransaction(&testRetryCtx{attempts: 1}, &idValid, func() error {
+		idValid = true
+		return errors.New("failed")
+	}, update)
+
+	if err == nil || idValid {
+		t.Errorf("\nERROR: Expected Id to be unset after a failed insert. Error: %v\n", err)
+	}
+}
@@ -164,11 +164,7 @@ func (u *User) Save() error {
 		return err
 	}
 
-	if !u.Id.Valid {
-		return u.Insert()
-	} else {
-		return u.Update()
-	}
+	return SaveInTransaction(u.Ctx, &u.Id.Valid, u.Insert, u.Update)
 }
 
 // Insert a new record
@@ -718,3 +714,75 @@ func (u *User) VerifyEmail(token string) (bool, error) {
 
 	return true, nil
 }
+
+// Create a user on the account's shard and map the email to the account on every shard (atomically)
+func CreateUser(ctx ContextInterface, accountId string, email string, firstName string, lastName string, roleId string, password string) (*User, error) {
+	shard, err := GetShardByAccountId(ctx, accountId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	dbConn, err := ctx.GetDb().GetConnByName(shard.GetName())
+
+	if err != nil {
+		return nil, err
+	}
+
+	curCtx := NewContext(ctx.GetDb())
+	curCtx.SetDbSession(dbConn.NewSession(nil))
+
+	user, err := NewUser(curCtx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	user.SetAccountId(accountId)
+	user.SetEmail(email)
+	user.SetFirstName(firstName)
+	user.SetLastName(lastName)
+	user.SetRoleId(roleId)
+	user.SetPassword(password, password)
+
+	if user.RawPasswordError != "" {
+		return nil, errors.New(user.RawPasswordError)
+	}
+
+	err = user.IsValid()
+
+	if err != nil {
+		return nil, err
+	}
+
+	ct := NewClusterTransaction(ctx.GetDb())
+	ct.NotifyShardRouteChange(accountId)
+
+	err = ct.Run(func(dbName string, shardCtx ContextInterface) error {
+
+		if dbName == shard.GetName() {
+			user.Ctx = shardCtx
+			err := user.Save()
+
+			if err != nil {
+				return err
+			}
+		}
+
+		shardMap, err := CreateShardMap(shardCtx, shard.GetId(), email, accountId)
+
+		if err != nil {
+			return err
+		}
+
+		return shardMap.Save()
+	})
+
+	user.Ctx = curCtx
+
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"strings"
 	"testing"
+	"time"
 )
 
 //
@@ -595,3 +596,25 @@ func TestFetchAllUserByAccountId(t *testing.T) {
 		return
 	}
 }
+
+// Nothing is saved when the password is rejected
+func TestCreateUserInvalidPassword(t *testing.T) {
+	email := fmt.Sprintf("create_user_%d@example.com", time.Now().UnixNano())
+
+	_, err := CreateUser(MockCtx, MockUser.GetAccountId(), email, "Test", "User", MockUser.GetRoleId(), "")
+
+	if err == nil {
+		t.Errorf("\nERROR: Expected a password error\n")
+	}
+
+	user, err := FetchUserByEmail(MockCtx, email)
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+		return
+	}
+
+	if user != nil {
+		t.Errorf("\nERROR: User should not have been created: %v\n", user.GetId())
+	}
+}
@@ -0,0 +1,210 @@
+package util
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Time formats accepted from forms (datetime-local inputs don't send seconds or a zone)
+var FormTimeFormats = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// Nullable json/jsonb column. Works like the sql.Null* types. json.RawMessage can't scan NULL and dbr
+// interpolates []byte as bytea, so the value is written as a string.
+type NullRawMessage struct {
+	RawMessage json.RawMessage
+	Valid      bool
+}
+
+// Scan implements the sql.Scanner interface
+func (n *NullRawMessage) Scan(value interface{}) error {
+
+	switch val := value.(type) {
+	case nil:
+		n.RawMessage, n.Valid = nil, false
+	case []byte:
+		// the driver may reuse the buffer
+		n.RawMessage, n.Valid = append(json.RawMessage(nil), val...), true
+	case string:
+		n.RawMessage, n.Valid = json.RawMessage(val), true
+	default:
+		return errors.New(fmt.Sprintf("Cannot scan %T into NullRawMessage", value))
+	}
+
+	return nil
+}
+
+// Value implements the driver.Valuer interface
+func (n NullRawMessage) Value() (driver.Value, error) {
+
+	if !n.Valid {
+		return nil, nil
+	}
+
+	return string(n.RawMessage), nil
+}
+
+// ******
+
+// "" = null
+func ParseNullInt64(val string) (sql.NullInt64, error) {
+
+	if val == "" {
+		return sql.NullInt64{}, nil
+	}
+
+	num, err := strconv.ParseInt(strings.TrimSpace(val), 10, 64)
+
+	if err != nil {
+		return sql.NullInt64{}, errors.New(fmt.Sprintf("Invalid integer: %s", val))
+	}
+
+	return sql.NullInt64{Int64: num, Valid: true}, nil
+}
+
+// "" = null
+func ParseNullFloat64(val string) (sql.NullFloat64, error) {
+
+	if val == "" {
+		return sql.NullFloat64{}, nil
+	}
+
+	num, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+
+	if err != nil {
+		return sql.NullFloat64{}, errors.New(fmt.Sprintf("Invalid number: %s", val))
+	}
+
+	return sql.NullFloat64{Float64: num, Valid: true}, nil
+}
+
+// "" = null. Also accepts "on" (checkbox default value).
+func ParseNullBool(val string) (sql.NullBool, error) {
+
+	if val == "" {
+		return sql.NullBool{}, nil
+	}
+
+	if val == "on" {
+		return sql.NullBool{Bool: true, Valid: true}, nil
+	}
+
+	b, err := strconv.ParseBool(val)
+
+	if err != nil {
+		return sql.NullBool{}, errors.New(fmt.Sprintf("Invalid boolean: %s", val))
+	}
+
+	return sql.NullBool{Bool: b, Valid: true}, nil
+}
+
+// "" = zero time. See FormTimeFormats.
+func ParseTime(val string) (time.Time, error) {
+
+	if val == "" {
+		return time.Time{}, nil
+	}
+
+	for _, format := range FormTimeFormats {
+		t, err := time.Parse(format, val)
+
+		if err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, errors.New(fmt.Sprintf("Invalid date/time: %s", val))
+}
+
+// "" (or a zero time, e.g., a null posted back from a view) = null
+func ParseNullTime(val string) (sql.NullTime, error) {
+	t, err := ParseTime(val)
+
+	if err != nil {
+		return sql.NullTime{}, err
+	}
+
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}, nil
+}
+
+// "" = null
+func ParseNullRawMessage(val string) (NullRawMessage, error) {
+
+	if val == "" {
+		return NullRawMessage{}, nil
+	}
+
+	if !json.Valid([]byte(val)) {
+		return NullRawMessage{}, errors.New(fmt.Sprintf("Invalid JSON: %s", val))
+	}
+
+	return NullRawMessage{RawMessage: json.RawMessage(val), Valid: true}, nil
+}
+
+// Multi-value form fields. Blank values are skipped.
+func ParseInt64Array(vals []string) ([]int64, error) {
+	var nums []int64
+
+	for _, val := range vals {
+		num, err := ParseNullInt64(val)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if num.Valid {
+			nums = append(nums, num.Int64)
+		}
+	}
+
+	return nums, nil
+}
+
+//
+func ParseFloat64Array(vals []string) ([]float64, error) {
+	var nums []float64
+
+	for _, val := range vals {
+		num, err := ParseNullFloat64(val)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if num.Valid {
+			nums = append(nums, num.Float64)
+		}
+	}
+
+	return nums, nil
+}
+
+//
+func ParseBoolArray(vals []string) ([]bool, error) {
+	var bools []bool
+
+	for _, val := range vals {
+		b, err := ParseNullBool(val)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if b.Valid {
+			bools = append(bools, b.Bool)
+		}
+	}
+
+	return bools, nil
+}
@@ -0,0 +1,121 @@
+// +build unit
+
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+//
+func TestNullRawMessage(t *testing.T) {
+	var n NullRawMessage
+	buf := []byte(`{"a":1}`)
+
+	err := n.Scan(buf)
+
+	if err != nil || !n.Valid || string(n.RawMessage) != `{"a":1}` {
+		t.Errorf("\nERROR: Scan failed: %v %v %s\n", err, n.Valid, n.RawMessage)
+	}
+
+	// must not share the driver's buffer
+	buf[0] = 'x'
+
+	if string(n.RawMessage) != `{"a":1}` {
+		t.Errorf("\nERROR: Scan should copy the value. Got: %s\n", n.RawMessage)
+	}
+
+	val, err := n.Value()
+
+	if err != nil || val != `{"a":1}` {
+		t.Errorf("\nERROR: Unexpected value: %v %v\n", val, err)
+	}
+
+	err = n.Scan(nil)
+
+	if err != nil || n.Valid || n.RawMessage != nil {
+		t.Errorf("\nERROR: Scan nil failed: %v\n", err)
+	}
+
+	val, err = n.Value()
+
+	if err != nil || val != nil {
+		t.Errorf("\nERROR: Expected nil value. Got: %v %v\n", val, err)
+	}
+
+	_, err = ParseNullRawMessage("{bogus")
+
+	if err == nil {
+		t.Errorf("\nERROR: Expected invalid JSON error\n")
+	}
+}
+
+//
+func TestParseNull(t *testing.T) {
+	num, err := ParseNullInt64("42")
+
+	if err != nil || !num.Valid || num.Int64 != 42 {
+		t.Errorf("\nERROR: ParseNullInt64 failed: %v %v\n", num, err)
+	}
+
+	num, err = ParseNullInt64("")
+
+	if err != nil || num.Valid {
+		t.Errorf("\nERROR: Blank should be null: %v %v\n", num, err)
+	}
+
+	_, err = ParseNullInt64("4.2")
+
+	if err == nil {
+		t.Errorf("\nERROR: Expected invalid integer error\n")
+	}
+
+	f, err := ParseNullFloat64("4.5")
+
+	if err != nil || !f.Valid || f.Float64 != 4.5 {
+		t.Errorf("\nERROR: ParseNullFloat64 failed: %v %v\n", f, err)
+	}
+
+	b, err := ParseNullBool("on")
+
+	if err != nil || !b.Valid || !b.Bool {
+		t.Errorf("\nERROR: ParseNullBool failed: %v %v\n", b, err)
+	}
+
+	b, err = ParseNullBool("false")
+
+	if err != nil || !b.Valid || b.Bool {
+		t.Errorf("\nERROR: ParseNullBool failed: %v %v\n", b, err)
+	}
+
+	nums, err := ParseInt64Array([]string{"1", "", "3"})
+
+	if err != nil || len(nums) != 2 || nums[1] != 3 {
+		t.Errorf("\nERROR: ParseInt64Array failed: %v %v\n", nums, err)
+	}
+}
+
+//
+func TestParseTime(t *testing.T) {
+	expected := time.Date(2020, 1, 2, 3, 4, 0, 0, time.UTC)
+
+	for _, val := range []string{"2020-01-02T03:04:00Z", "2020-01-02T03:04"} {
+		tm, err := ParseNullTime(val)
+
+		if err != nil || !tm.Valid || !tm.Time.Equal(expected) {
+			t.Errorf("\nERROR: ParseNullTime(%s) failed: %v %v\n", val, tm, err)
+		}
+	}
+
+	tm, err := ParseNullTime("")
+
+	if err != nil || tm.Valid {
+		t.Errorf("\nERROR: Blank should be null: %v %v\n", tm, err)
+	}
+
+	_, err = ParseTime("01/02/2020")
+
+	if err == nil {
+		t.Errorf("\nERROR: Expected invalid time error\n")
+	}
+}
@@ -83,6 +83,10 @@ func InitSession() {
 // Init metrics
 func InitMetrics() {
 	prometheus.Register(webReqHistogram)
+	prometheus.Register(shardRouteCacheCounter)
+	prometheus.Register(dbConnUpGauge)
+	prometheus.Register(dbConnPingGauge)
+	prometheus.Register(dbQueryHistogram)
 }
 
 //
@@ -95,6 +99,10 @@ func Start(router *web.Router) {
 func StartAll(router *web.Router) {
 	InitConfig()
 	InitDbCollection()
+	InitDbHealthChecks()
+	InitDbReloader()
+	InitClusterTransactionRecovery()
+	InitShardRouteCache()
 	InitSession()
 	InitMetrics()
 	StartHealthSink(appConfig.Server.HealthHost)
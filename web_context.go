@@ -1,6 +1,7 @@
 package jgoweb
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -19,13 +20,16 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 var db *jgoWebDb.Collection
+var validatorOnce sync.Once
 
 type WebContext struct {
 	User                *User
+	Account             *Account
 	Session             *scs.Session
 	Template            *template.Template
 	Job                 *health.Job
@@ -35,8 +39,14 @@ type WebContext struct {
 	Validate            *validator.Validate
 	Db                  *jgoWebDb.Collection
 	DbSess              *dbr.Session
+	GoCtx               context.Context
 	Tx                  *dbr.Tx
 	RollbackTransaction bool
+	txDepth             int
+	UseReplicas         bool
+	PrimaryOnly         bool
+	replicaSess         *dbr.Session
+	replicaFor          *dbr.Session
 }
 
 // Init Db
@@ -49,6 +59,10 @@ func InitDbCollection() {
 		return
 	}
 
+	if jgoWebDb.NewEventReceiver == nil {
+		jgoWebDb.NewEventReceiver = NewQueryEventReceiver
+	}
+
 	db, err = jgoWebDb.NewDb(appConfig.DbConns)
 
 	if err != nil {
@@ -64,7 +78,18 @@ func GetDbCollection() *jgoWebDb.Collection {
 }
 
 func NewContext(db *jgoWebDb.Collection) *WebContext {
-	return &WebContext{Db: db, Validate: jgovalidator.GetValidator()}
+	return &WebContext{Db: db, Validate: NewValidator()}
+}
+
+// jgovalidator's singleton plus the nullable types used by generated models
+func NewValidator() *validator.Validate {
+	validate := jgovalidator.GetValidator()
+
+	validatorOnce.Do(func() {
+		validate.RegisterCustomTypeFunc(jgovalidator.ValidateValuer, sql.NullTime{}, util.NullRawMessage{})
+	})
+
+	return validate
 }
 
 // *** Getters/Setters ***
@@ -73,6 +98,14 @@ func (ctx *WebContext) SetUser(user *User) {
 	ctx.User = user
 }
 
+func (ctx *WebContext) SetAccount(account *Account) {
+	ctx.Account = account
+}
+
+func (ctx *WebContext) GetAccount() *Account {
+	return ctx.Account
+}
+
 func (ctx *WebContext) SessionGetString(key string) (string, error) {
 	return ctx.Session.GetString(key)
 }
@@ -92,14 +125,29 @@ func (ctx *WebContext) GetDbSession() *dbr.Session {
 func (ctx *WebContext) GetValidator() *validator.Validate {
 
 	if ctx.Validate == nil {
-		ctx.Validate = jgovalidator.GetValidator()
+		ctx.Validate = NewValidator()
 	}
 
 	return ctx.Validate
 }
 
 func (ctx *WebContext) SetDbSession(dbSess *dbr.Session) {
-	ctx.DbSess = dbSess
+	ctx.DbSess = ctx.instrumentSession(dbSess)
+}
+
+// Cancelling it rolls back the context's transaction (e.g., cluster executor timeouts)
+func (ctx *WebContext) SetGoContext(goCtx context.Context) {
+	ctx.GoCtx = goCtx
+}
+
+//
+func (ctx *WebContext) GetGoContext() context.Context {
+
+	if ctx.GoCtx == nil {
+		return context.Background()
+	}
+
+	return ctx.GoCtx
 }
 
 // ******* Db Methods *******
@@ -107,7 +155,9 @@ func (ctx *WebContext) SetDbSession(dbSess *dbr.Session) {
 func (ctx *WebContext) Begin() (*dbr.Tx, error) {
 	var err error
 
-	ctx.Tx, err = ctx.DbSess.Begin()
+	ctx.PrimaryOnly = true
+
+	ctx.Tx, err = ctx.DbSess.BeginTx(ctx.GetGoContext(), nil)
 
 	return ctx.Tx, err
 }
@@ -142,7 +192,7 @@ func (ctx *WebContext) Select(column ...string) *dbr.SelectBuilder {
 	if ctx.Tx != nil {
 		stmt = ctx.Tx.Select(column...)
 	} else {
-		stmt = ctx.DbSess.Select(column...)
+		stmt = ctx.GetReadSession().Select(column...)
 	}
 
 	return stmt
@@ -154,13 +204,14 @@ func (ctx *WebContext) SelectBySql(query string, value ...interface{}) *dbr.Sele
 	if ctx.Tx != nil {
 		stmt = ctx.Tx.SelectBySql(query, value...)
 	} else {
-		stmt = ctx.DbSess.SelectBySql(query, value...)
+		stmt = ctx.GetReadSession().SelectBySql(query, value...)
 	}
 
 	return stmt
 }
 
 func (ctx *WebContext) Prepare(query string) (*sql.Stmt, error) {
+	ctx.PrimaryOnly = true
 
 	if ctx.Tx != nil {
 		return ctx.Tx.Prepare(query)
@@ -172,6 +223,8 @@ func (ctx *WebContext) Prepare(query string) (*sql.Stmt, error) {
 func (ctx *WebContext) InsertBySql(query string, value ...interface{}) *dbr.InsertStmt {
 	var stmt *dbr.InsertStmt
 
+	ctx.PrimaryOnly = true
+
 	if ctx.Tx != nil {
 		stmt = ctx.Tx.InsertBySql(query, value...)
 	} else {
@@ -184,6 +237,8 @@ func (ctx *WebContext) InsertBySql(query string, value ...interface{}) *dbr.Inse
 func (ctx *WebContext) InsertInto(table string) *dbr.InsertStmt {
 	var stmt *dbr.InsertStmt
 
+	ctx.PrimaryOnly = true
+
 	if ctx.Tx != nil {
 		stmt = ctx.Tx.InsertInto(table)
 	} else {
@@ -196,6 +251,8 @@ func (ctx *WebContext) InsertInto(table string) *dbr.InsertStmt {
 func (ctx *WebContext) UpdateBySql(query string, value ...interface{}) *dbr.UpdateStmt {
 	var stmt *dbr.UpdateStmt
 
+	ctx.PrimaryOnly = true
+
 	if ctx.Tx != nil {
 		stmt = ctx.Tx.UpdateBySql(query, value...)
 	} else {
@@ -208,6 +265,8 @@ func (ctx *WebContext) UpdateBySql(query string, value ...interface{}) *dbr.Upda
 func (ctx *WebContext) Update(table string) *dbr.UpdateStmt {
 	var stmt *dbr.UpdateStmt
 
+	ctx.PrimaryOnly = true
+
 	if ctx.Tx != nil {
 		stmt = ctx.Tx.Update(table)
 	} else {
@@ -220,6 +279,8 @@ func (ctx *WebContext) Update(table string) *dbr.UpdateStmt {
 func (ctx *WebContext) DeleteFrom(table string) *dbr.DeleteStmt {
 	var stmt *dbr.DeleteStmt
 
+	ctx.PrimaryOnly = true
+
 	if ctx.Tx != nil {
 		stmt = ctx.Tx.DeleteFrom(table)
 	} else {
@@ -229,8 +290,45 @@ func (ctx *WebContext) DeleteFrom(table string) *dbr.DeleteStmt {
 	return stmt
 }
 
-// Only start a transaction if one hasn't been started yet
+// Session for reads outside of a transaction. A replica of the current shard if UseReplicas is set
+// and nothing has been written yet (read your writes). Otherwise the primary.
+func (ctx *WebContext) GetReadSession() *dbr.Session {
+
+	if !ctx.UseReplicas || ctx.PrimaryOnly || ctx.Db == nil || ctx.DbSess == nil {
+		return ctx.DbSess
+	}
+
+	// one replica per shard session for the rest of the request
+	if ctx.replicaFor == ctx.DbSess && ctx.replicaSess != nil {
+		return ctx.replicaSess
+	}
+
+	name, ok := ctx.Db.GetConnName(ctx.DbSess.Connection)
+
+	if !ok {
+		return ctx.DbSess
+	}
+
+	dbSess, err := ctx.Db.GetReplicaSessionByName(name)
+
+	if err != nil {
+		return ctx.DbSess
+	}
+
+	ctx.replicaFor = ctx.DbSess
+	ctx.replicaSess = ctx.instrumentSession(dbSess)
+
+	return ctx.replicaSess
+}
+
+// Read from the primary for the rest of the request
+func (ctx *WebContext) ForcePrimary() {
+	ctx.PrimaryOnly = true
+}
+
+// Only start a transaction if one hasn't been started yet. Prefer WithTransaction.
 func (ctx *WebContext) OptionalBegin() (*dbr.Tx, error) {
+	ctx.PrimaryOnly = true
 
 	if ctx.Tx != nil {
 		return ctx.Tx, nil
@@ -247,7 +345,7 @@ func (ctx *WebContext) OptionalRollback(tx *dbr.Tx) error {
 		return nil
 	}
 
-	return tx.Commit()
+	return tx.Rollback()
 }
 
 // Commit if there's no tx in the context
@@ -391,7 +489,7 @@ func (ctx *WebContext) InitDbSession() {
 		panic(err)
 	}
 
-	ctx.DbSess = dbConn.NewSession(nil)
+	ctx.DbSess = ctx.instrumentSession(dbConn.NewSession(nil))
 }
 
 // **** Middleware ****
@@ -428,6 +526,13 @@ func (ctx *WebContext) LoadDi(rw web.ResponseWriter, req *web.Request, next web.
 	next(rw, req)
 }
 
+// Route reads to shard replicas (until the request writes)
+func (ctx *WebContext) UseReadReplicas(rw web.ResponseWriter, req *web.Request, next web.NextMiddlewareFunc) {
+	ctx.UseReplicas = true
+
+	next(rw, req)
+}
+
 // Have the context manage the DB transaction. Typically don't do this. Each model handles it's own transaction,
 // which is much faster/safer than having a single transaction for an entire web request.
 // This middleware is useful for testing routes though.
@@ -37,3 +37,41 @@ func TestTransactions(t *testing.T) {
 	// 	dbSess := MockCtx.GetDbSession()
 	// 	dbSess.SelectBySql("SELECT 1")
 }
+
+//
+func TestGetReadSession(t *testing.T) {
+	ctx := NewContext(GetDbCollection())
+
+	dbSess, err := ctx.Db.GetSessionByName(appConfig.Integration.ShardName)
+
+	if err != nil {
+		t.Errorf("\nERROR: %v\n", err)
+		return
+	}
+
+	ctx.SetDbSession(dbSess)
+
+	if ctx.GetReadSession() != ctx.DbSess {
+		t.Errorf("\nERROR: Reads should use the primary unless replicas are enabled.\n")
+	}
+
+	ctx.UseReplicas = true
+
+	readSess := ctx.GetReadSession()
+
+	if readSess == nil {
+		t.Errorf("\nERROR: nil read session\n")
+		return
+	}
+
+	if ctx.GetReadSession() != readSess {
+		t.Errorf("\nERROR: Read session should be reused for the rest of the request.\n")
+	}
+
+	// read your writes
+	ctx.Update("public.users")
+
+	if ctx.GetReadSession() != ctx.DbSess {
+		t.Errorf("\nERROR: Reads should use the primary after a write.\n")
+	}
+}